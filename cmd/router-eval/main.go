@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"workspace-engine/internal/llm-router/api"
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/eval"
+	"workspace-engine/internal/llm-router/service"
+	"workspace-engine/pkg/logger"
+)
+
+func main() {
+	suitePath := flag.String("suite", "", "suite to run, a YAML file or a JSONL file with one case per line")
+	modelList := flag.String("models", "", "comma separated models to evaluate")
+	judge := flag.String("judge", "", "model grading judge assertions, they are skipped without one")
+	mock := flag.Bool("mock", false, "answer with the mock outputs of the suite instead of calling the vendors")
+	jsonPath := flag.String("json", "eval-report.json", "path of the JSON report, empty to skip it")
+	htmlPath := flag.String("html", "eval-report.html", "path of the HTML report, empty to skip it")
+	minScore := flag.Float64("min-score", 0, "exit with status 1 when a model scores below this, from 0 to 1")
+	flag.Parse()
+
+	if *suitePath == "" || *modelList == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	var modelNames []string
+	for _, model := range strings.Split(*modelList, ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			modelNames = append(modelNames, model)
+		}
+	}
+	if *mock && *judge != "" {
+		log.Fatal("A judge model cannot be used with mock outputs")
+	}
+
+	suite, err := eval.LoadSuite(*suitePath)
+	if err != nil {
+		log.Fatalf("Failed to load suite: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var router *service.RouterService
+	if *mock {
+		router = eval.NewMockRouter(suite, modelNames)
+	} else {
+		router, err = newRouter(ctx)
+		if err != nil {
+			log.Fatalf("Failed to initialize router: %v", err)
+		}
+	}
+
+	report, err := eval.NewRunner(router, *judge).Run(ctx, suite, modelNames)
+	if err != nil {
+		log.Fatalf("Failed to run suite: %v", err)
+	}
+
+	if err := writeReport(*jsonPath, report.WriteJSON); err != nil {
+		log.Fatalf("Failed to write JSON report: %v", err)
+	}
+	if err := writeReport(*htmlPath, report.WriteHTML); err != nil {
+		log.Fatalf("Failed to write HTML report: %v", err)
+	}
+
+	failed := false
+	for _, model := range report.Models {
+		fmt.Printf(
+			"%s: score %.1f%%, %d passed, %d failed, %d errors\n",
+			model.Model, model.Score*100, model.Passed, model.Failed, model.Errors,
+		)
+		if model.Score < *minScore {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// newRouter creates a router service on the configured vendors. It keeps no usage and runs no
+// batches, so an evaluation does not need the storage of the server.
+func newRouter(ctx context.Context) (*service.RouterService, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := logger.NewLogger(cfg.Logging); err != nil {
+		return nil, err
+	}
+
+	providers, listers, err := api.NewProviders(cfg)
+	if err != nil {
+		return nil, err
+	}
+	catalog := service.NewCatalogService(providers, listers, cfg)
+	catalog.Refresh(ctx)
+
+	// The routing rules and upgrades are left out, they would send cases to other models than the
+	// one being evaluated
+	return service.NewRouterService(providers, catalog, nil, nil), nil
+}
+
+func writeReport(path string, write func(w io.Writer) error) error {
+	if path == "" {
+		return nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -1,12 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"workspace-engine/internal/llm-router/api"
 	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/rpc"
+	"workspace-engine/internal/llm-router/telemetry"
 	"workspace-engine/pkg/logger"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
@@ -17,15 +29,118 @@ func main() {
 	}
 
 	// Initialize logger
-	logger.NewLogger()
+	if err := logger.NewLogger(cfg.Logging); err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	// Initialize tracing
+	shutdownTracing, err := telemetry.InitTracing(cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// Initialize services
+	services, err := api.NewServices(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize services: %v", err)
+	}
+	server, err := api.NewServer(cfg.Server, api.NewRouter(cfg, services))
+	if err != nil {
+		log.Fatalf("Failed to initialize server: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Apply changes of the configuration file without a restart
+	if err := services.Reloader.Watch(ctx); err != nil {
+		logger.Warn("Configuration changes are only applied after a restart", "error", err)
+	}
 
-	// Initialize router
-	router := api.NewRouter(cfg)
+	serveErrors := make(chan error, 2)
+
+	// Start the gRPC server next to the HTTP server
+	var grpcServer *grpc.Server
+	if cfg.Server.GRPCPort > 0 {
+		grpcAddr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.GRPCPort)
+		listener, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			log.Fatalf("Failed to listen for gRPC: %v", err)
+		}
+		var opts []grpc.ServerOption
+		if server.TLSConfig != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(server.TLSConfig)))
+		}
+		tenants := func() []config.TenantConfig { return services.Config().Tenants }
+		grpcServer = rpc.NewServer(services.Router, services.Keys, tenants, opts...)
+
+		logger.Info("Starting gRPC server", "address", grpcAddr, "tls", server.TLSConfig != nil)
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				serveErrors <- fmt.Errorf("gRPC server failed: %w", err)
+			}
+		}()
+	}
 
 	// Start server
-	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
-	logger.Info("Starting server", "address", addr)
-	if err := router.Run(addr); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	logger.Info("Starting server", "address", server.Addr, "tls", server.TLSConfig != nil)
+	go func() {
+		var err error
+		if server.TLSConfig != nil {
+			// The certificate is already part of the TLS config
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			serveErrors <- fmt.Errorf("server failed: %w", err)
+		}
+	}()
+
+	select {
+	case err := <-serveErrors:
+		log.Fatal(err)
+	case <-ctx.Done():
+		stop()
+	}
+	shutdown(cfg.Server, services, server, grpcServer)
+}
+
+// shutdown drains the router: it reports that it is not ready for the drain delay, stops accepting
+// requests and waits for those in flight, including streams, up to the shutdown timeout before it
+// closes the remaining connections
+func shutdown(cfg config.ServerConfig, services *api.Services, server *http.Server, grpcServer *grpc.Server) {
+	timeout := api.ShutdownTimeout(cfg)
+	logger.Info("Shutting down", "drain_delay", cfg.DrainDelay, "timeout", timeout)
+	services.Lifecycle.Drain()
+	time.Sleep(cfg.DrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	grpcStopped := make(chan struct{})
+	go func() {
+		if grpcServer != nil {
+			grpcServer.GracefulStop()
+		}
+		close(grpcStopped)
+	}()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Warn("Closing connections of unfinished requests", "error", err)
+		server.Close()
+	}
+	select {
+	case <-grpcStopped:
+	case <-ctx.Done():
+		if grpcServer != nil {
+			logger.Warn("Closing unfinished gRPC calls", "error", ctx.Err())
+			grpcServer.Stop()
+		}
 	}
+
+	// Shadow requests run in the background and are bounded by their own timeout
+	services.Shadows.Wait()
+	logger.Info("Shutdown complete")
 }
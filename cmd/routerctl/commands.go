@@ -0,0 +1,443 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"workspace-engine/internal/llm-router/client"
+	"workspace-engine/internal/llm-router/models"
+)
+
+func runPrompt(ctx context.Context, c *client.Client, args []string) error {
+	flags := flag.NewFlagSet("prompt", flag.ExitOnError)
+	model := flags.String("model", "", "preferred model, the routing rules decide without one")
+	noStream := flags.Bool("no-stream", false, "wait for the complete response and show its usage")
+	temperature := flags.Float64("temperature", -1, "sampling temperature, the model default when negative")
+	maxTokens := flags.Int("max-tokens", 0, "maximum number of generated tokens, the router default when 0")
+	_ = flags.Parse(args)
+
+	prompt, err := readPrompt(flags.Args())
+	if err != nil {
+		return err
+	}
+	req := models.RouteRequest{Prompt: prompt, PreferredModel: *model, Parameters: map[string]interface{}{}}
+	if *temperature >= 0 {
+		req.Parameters["temperature"] = *temperature
+	}
+	if *maxTokens > 0 {
+		req.Parameters["maxTokens"] = *maxTokens
+	}
+
+	if *noStream {
+		resp, err := c.Route(ctx, req)
+		if err != nil {
+			return err
+		}
+		fmt.Println(resp.Result)
+		fmt.Fprintf(
+			os.Stderr, "\nmodel %s, %d prompt tokens, %d completion tokens\n",
+			resp.Model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens,
+		)
+		return nil
+	}
+
+	err = c.Stream(ctx, req, func(content string) { fmt.Print(content) })
+	fmt.Println()
+	return err
+}
+
+// readPrompt joins the arguments to the prompt, a single "-" reads the prompt from stdin
+func readPrompt(args []string) (string, error) {
+	if len(args) == 1 && args[0] == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", err
+		}
+		args = []string{string(data)}
+	}
+	prompt := strings.TrimSpace(strings.Join(args, " "))
+	if prompt == "" {
+		return "", fmt.Errorf("%w: prompt is required", errUsage)
+	}
+	return prompt, nil
+}
+
+func runModels(ctx context.Context, c *client.Client, args []string) error {
+	flags := flag.NewFlagSet("models", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print JSON")
+	_ = flags.Parse(args)
+
+	list, err := c.Models(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(list)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return printTable(
+		[]string{"MODEL", "PROVIDER", "CONTEXT", "INPUT/1K", "OUTPUT/1K", "AVAILABLE", "CAPABILITIES"},
+		func(row func(values ...interface{})) {
+			for _, model := range list {
+				row(
+					model.ID, model.Provider, model.MaxTokens, model.Pricing.InputPrice, model.Pricing.OutputPrice,
+					model.Available, strings.Join(model.Capabilities, ","),
+				)
+			}
+		},
+	)
+}
+
+func runHealth(ctx context.Context, c *client.Client, args []string) error {
+	flags := flag.NewFlagSet("health", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print JSON")
+	_ = flags.Parse(args)
+
+	health, err := c.Health(ctx)
+	if err != nil {
+		return err
+	}
+	ready, err := c.Ready(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(struct {
+			*models.HealthStatus
+			Ready bool `json:"ready"`
+		}{health, ready})
+	}
+
+	fmt.Printf("status %s, ready %t, at %s\n\n", health.Status, ready, health.Timestamp)
+	names := make([]string, 0, len(health.Models))
+	for name := range health.Models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return printTable(
+		[]string{"MODEL", "STATUS", "LATENCY"},
+		func(row func(values ...interface{})) {
+			for _, name := range names {
+				row(name, health.Models[name].Status, health.Models[name].Latency)
+			}
+		},
+	)
+}
+
+func runUsage(ctx context.Context, c *client.Client, args []string) error {
+	flags := flag.NewFlagSet("usage", flag.ExitOnError)
+	var filter models.UsageFilter
+	flags.StringVar(&filter.Model, "model", "", "only records of this model")
+	flags.StringVar(&filter.TemplateName, "template", "", "only records of this template")
+	flags.IntVar(&filter.TemplateVersion, "template-version", 0, "only records of this template version")
+	flags.IntVar(&filter.Limit, "limit", 50, "maximum number of records")
+	asJSON := flags.Bool("json", false, "print JSON")
+	_ = flags.Parse(args)
+
+	records, err := c.Usage(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(records)
+	}
+
+	var total models.Usage
+	err = printTable(
+		[]string{"TIME", "MODEL", "PROVIDER", "TEMPLATE", "PROMPT", "COMPLETION", "REQUEST"},
+		func(row func(values ...interface{})) {
+			for _, record := range records {
+				template := record.TemplateName
+				if template != "" && record.TemplateVersion > 0 {
+					template = fmt.Sprintf("%s@%d", template, record.TemplateVersion)
+				}
+				row(
+					record.CreatedAt, record.Model, record.Provider, template, record.Usage.PromptTokens,
+					record.Usage.CompletionTokens, record.RequestID,
+				)
+				total.PromptTokens += record.Usage.PromptTokens
+				total.CompletionTokens += record.Usage.CompletionTokens
+			}
+		},
+	)
+	if err != nil {
+		return err
+	}
+	fmt.Printf(
+		"\n%d requests, %d prompt tokens, %d completion tokens\n",
+		len(records), total.PromptTokens, total.CompletionTokens,
+	)
+	return nil
+}
+
+func runBatch(ctx context.Context, c *client.Client, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: batch needs a subcommand", errUsage)
+	}
+
+	switch args[0] {
+	case "run":
+		flags := flag.NewFlagSet("batch run", flag.ExitOnError)
+		out := flags.String("out", "", "file the results are written to, stdout when empty")
+		wait := flags.Bool("wait", true, "wait for the batch to finish and print its results")
+		interval := flags.Duration("interval", 2*time.Second, "how often the progress is checked")
+		_ = flags.Parse(args[1:])
+		if flags.NArg() != 1 {
+			return fmt.Errorf("%w: batch run needs a file", errUsage)
+		}
+		return runBatchFile(ctx, c, flags.Arg(0), *out, *wait, *interval)
+
+	case "status":
+		if len(args) != 2 {
+			return fmt.Errorf("%w: batch status needs a batch id", errUsage)
+		}
+		job, err := c.Batch(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		return printJSON(job)
+
+	case "results":
+		flags := flag.NewFlagSet("batch results", flag.ExitOnError)
+		out := flags.String("out", "", "file the results are written to, stdout when empty")
+		_ = flags.Parse(args[1:])
+		if flags.NArg() != 1 {
+			return fmt.Errorf("%w: batch results needs a batch id", errUsage)
+		}
+		return writeBatchResults(ctx, c, flags.Arg(0), *out)
+
+	default:
+		return fmt.Errorf("%w: unknown batch subcommand %q", errUsage, args[0])
+	}
+}
+
+// runBatchFile submits a batch file and, when asked to wait, reports its progress on stderr until
+// it finishes and writes its results
+func runBatchFile(ctx context.Context, c *client.Client, path, out string, wait bool, interval time.Duration) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	job, err := c.CreateBatch(ctx, file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "batch %s submitted with %d requests\n", job.ID, job.Total)
+	if !wait {
+		fmt.Println(job.ID)
+		return nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for !batchFinished(job.Status) {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("stopped waiting for batch %s, it keeps running: %w", job.ID, ctx.Err())
+		case <-ticker.C:
+		}
+		if job, err = c.Batch(ctx, job.ID); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%s: %d of %d done, %d failed\n", job.Status, job.Completed, job.Total, job.Failed)
+	}
+
+	if job.Status != models.BatchStatusCompleted {
+		message := fmt.Sprintf("batch %s %s", job.ID, job.Status)
+		if job.Error != "" {
+			message += ": " + job.Error
+		}
+		fmt.Fprintln(os.Stderr, message)
+	}
+	return writeBatchResults(ctx, c, job.ID, out)
+}
+
+func batchFinished(status string) bool {
+	switch status {
+	case models.BatchStatusCompleted, models.BatchStatusFailed, models.BatchStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func writeBatchResults(ctx context.Context, c *client.Client, id, out string) error {
+	if out == "" {
+		return c.BatchResults(ctx, id, os.Stdout)
+	}
+	file, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := c.BatchResults(ctx, id, file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func runKeys(ctx context.Context, c *client.Client, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: keys needs a subcommand", errUsage)
+	}
+
+	flags := flag.NewFlagSet("keys "+args[0], flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print JSON")
+	_ = flags.Parse(args[1:])
+
+	switch args[0] {
+	case "list":
+		keys, err := c.APIKeys(ctx)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return printJSON(keys)
+		}
+		return printTable(
+			[]string{"ID", "NAME", "PREFIX", "CREATED", "REVOKED"},
+			func(row func(values ...interface{})) {
+				for _, key := range keys {
+					row(key.ID, key.Name, key.Prefix, key.CreatedAt, key.RevokedAt)
+				}
+			},
+		)
+
+	case "create":
+		if flags.NArg() != 1 {
+			return fmt.Errorf("%w: keys create needs a name", errUsage)
+		}
+		key, err := c.CreateAPIKey(ctx, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return printJSON(key)
+		}
+		fmt.Printf("created API key %s (%s), it is not shown again:\n%s\n", key.ID, key.Name, key.Key)
+		return nil
+
+	case "revoke":
+		if flags.NArg() != 1 {
+			return fmt.Errorf("%w: keys revoke needs a key id", errUsage)
+		}
+		key, err := c.RevokeAPIKey(ctx, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return printJSON(key)
+		}
+		fmt.Printf("revoked API key %s (%s)\n", key.ID, key.Name)
+		return nil
+
+	default:
+		return fmt.Errorf("%w: unknown keys subcommand %q", errUsage, args[0])
+	}
+}
+
+func runProviderKeys(ctx context.Context, c *client.Client, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: provider-keys needs a subcommand", errUsage)
+	}
+
+	flags := flag.NewFlagSet("provider-keys "+args[0], flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print JSON")
+	_ = flags.Parse(args[1:])
+
+	switch args[0] {
+	case "list":
+		statuses, err := c.Providers(ctx)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return printJSON(statuses)
+		}
+		return printTable(
+			[]string{"INSTANCE", "KEY", "WEIGHT", "REQUESTS", "RATE LIMITED", "FAILURES", "TOKENS", "COOLING UNTIL"},
+			func(row func(values ...interface{})) {
+				for _, status := range statuses {
+					for _, key := range status.Keys {
+						row(
+							status.Key, key.Name, key.Weight, key.Requests, key.RateLimited, key.Failures,
+							key.PromptTokens+key.CompletionTokens, key.CoolingDownUntil,
+						)
+					}
+				}
+			},
+		)
+
+	case "policies":
+		policies, err := c.CredentialPolicies(ctx)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return printJSON(policies)
+		}
+		return printTable(
+			[]string{"KEY HASH", "PROVIDER KEYS", "UPDATED"},
+			func(row func(values ...interface{})) {
+				for _, policy := range policies {
+					row(policy.KeyHash, allowedText(policy.Allowed), policy.UpdatedAt)
+				}
+			},
+		)
+
+	case "allow", "deny":
+		if flags.NArg() != 1 {
+			return fmt.Errorf("%w: provider-keys %s needs an API key", errUsage, args[0])
+		}
+		policy, err := c.SetCredentialPolicy(ctx, flags.Arg(0), args[0] == "allow")
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return printJSON(policy)
+		}
+		fmt.Printf("provider keys %s for %s\n", allowedText(policy.Allowed), policy.KeyHash)
+		return nil
+
+	default:
+		return fmt.Errorf("%w: unknown provider-keys subcommand %q", errUsage, args[0])
+	}
+}
+
+func allowedText(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+	return "denied"
+}
+
+func printJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// printTable prints the rows added by the callback in aligned columns
+func printTable(header []string, rows func(row func(values ...interface{}))) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	rows(
+		func(values ...interface{}) {
+			cells := make([]string, len(values))
+			for i, value := range values {
+				cells[i] = fmt.Sprint(value)
+			}
+			fmt.Fprintln(w, strings.Join(cells, "\t"))
+		},
+	)
+	return w.Flush()
+}
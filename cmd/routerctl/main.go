@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"workspace-engine/internal/llm-router/client"
+)
+
+const usage = `routerctl talks to a running LLM router.
+
+Usage:
+  routerctl [-config file] [-endpoint url] <command> [arguments]
+
+Commands:
+  prompt [flags] <prompt>             send a prompt and stream the response, "-" reads it from stdin
+  models [-json]                      list the models of the catalog
+  health [-json]                      show the health of the router and its models
+  usage [flags]                       list usage records
+  batch run [flags] <file>            submit a batch file and wait for its results
+  batch status <id>                   show the progress of a batch
+  batch results <id>                  print the results of a batch
+  keys list [-json]                   list the API keys issued by the router (admin)
+  keys create [-json] <name>          issue an API key, it is only shown once (admin)
+  keys revoke [-json] <id>            stop accepting an issued API key (admin)
+  provider-keys list [-json]          show the usage of the provider API keys (admin)
+  provider-keys policies [-json]      list which API keys may send provider keys (admin)
+  provider-keys allow|deny <api-key>  allow or deny provider keys for an API key (admin)
+
+The endpoint, API key and admin token are read from the config file and the environment
+variables ROUTER_ENDPOINT, ROUTER_API_KEY and ROUTER_ADMIN_TOKEN, which take precedence.
+The config file defaults to routerctl/config.yaml in the user configuration directory or
+the file named by ROUTERCTL_CONFIG:
+
+  endpoint: https://router.example.com
+  api_key: ${ROUTER_API_KEY}
+  admin_token: file:/etc/routerctl/admin-token
+  timeout: 1m
+`
+
+// errUsage reports a command line that cannot be run, the usage is printed for it
+var errUsage = errors.New("invalid arguments")
+
+type command func(ctx context.Context, c *client.Client, args []string) error
+
+var commands = map[string]command{
+	"prompt": runPrompt,
+	"models": runModels,
+	"health": runHealth,
+	"usage":  runUsage,
+	"batch":  runBatch,
+	"keys":   runKeys,
+	// The API keys of the vendors the router sends requests to
+	"provider-keys": runProviderKeys,
+}
+
+func main() {
+	flags := flag.NewFlagSet("routerctl", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(flags.Output(), usage) }
+	configPath := flags.String("config", "", "config file of routerctl")
+	endpoint := flags.String("endpoint", "", "URL of the router, overrides the config")
+	_ = flags.Parse(os.Args[1:])
+
+	args := flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	run, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg, err := client.LoadConfig(ctx, *configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "routerctl: %v\n", err)
+		os.Exit(1)
+	}
+	if *endpoint != "" {
+		cfg.Endpoint = *endpoint
+	}
+
+	if err := run(ctx, client.NewClient(cfg), args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "routerctl: %v\n", err)
+		if errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr)
+			flags.Usage()
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
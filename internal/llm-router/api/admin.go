@@ -0,0 +1,255 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) ListProviders(c *gin.Context) {
+	SuccessResponse(c, http.StatusOK, h.admin.ListProviders())
+}
+
+func (h *Handler) GetProvider(c *gin.Context) {
+	status, err := h.admin.GetProvider(c.Param("key"))
+	if err != nil {
+		providerErrorResponse(c, "Failed to get provider", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, status)
+}
+
+func (h *Handler) UpdateProvider(c *gin.Context) {
+	var update models.ProviderUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		ErrorResponse(
+			c, http.StatusBadRequest, models.NewErrorResponse(
+				"INVALID_REQUEST",
+				"Invalid request body",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	status, err := h.admin.UpdateProvider(c.GetString(adminActorKey), c.Param("key"), update)
+	if err != nil {
+		providerErrorResponse(c, "Failed to update provider", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, status)
+}
+
+func (h *Handler) ProbeProvider(c *gin.Context) {
+	status, err := h.admin.ProbeProvider(c.GetString(adminActorKey), c.Param("key"))
+	if err != nil {
+		providerErrorResponse(c, "Failed to probe provider", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, status)
+}
+
+func (h *Handler) GetAudit(c *gin.Context) {
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			ErrorResponse(
+				c, http.StatusBadRequest, models.NewErrorResponse(
+					"INVALID_REQUEST",
+					"Invalid query parameters",
+					"limit must be a non-negative number",
+				),
+			)
+			return
+		}
+		limit = parsed
+	}
+
+	records, err := h.admin.Audit(limit)
+	if err != nil {
+		ErrorResponse(
+			c, http.StatusInternalServerError, models.NewErrorResponse(
+				"AUDIT_ERROR",
+				"Failed to list audit records",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, records)
+}
+
+// ListShadowResults returns the stored responses of shadow requests next to the primary responses
+func (h *Handler) ListShadowResults(c *gin.Context) {
+	var filter models.ShadowFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		ErrorResponse(
+			c, http.StatusBadRequest, models.NewErrorResponse(
+				"INVALID_REQUEST",
+				"Invalid query parameters",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	results, err := h.shadows.List(filter)
+	if err != nil {
+		ErrorResponse(
+			c, http.StatusInternalServerError, models.NewErrorResponse(
+				"SHADOW_ERROR",
+				"Failed to list shadow results",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, results)
+}
+
+// ListCredentialPolicies returns which API keys may send their own provider API keys
+func (h *Handler) ListCredentialPolicies(c *gin.Context) {
+	policies, err := h.admin.ListCredentialPolicies()
+	if err != nil {
+		ErrorResponse(
+			c, http.StatusInternalServerError, models.NewErrorResponse(
+				"CREDENTIALS_ERROR",
+				"Failed to list credential policies",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, policies)
+}
+
+// SetCredentialPolicy allows or denies provider API keys for an API key of the router
+func (h *Handler) SetCredentialPolicy(c *gin.Context) {
+	var update models.CredentialPolicyUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		ErrorResponse(
+			c, http.StatusBadRequest, models.NewErrorResponse(
+				"INVALID_REQUEST",
+				"Invalid request body",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	policy, err := h.admin.SetCredentialPolicy(c.GetString(adminActorKey), update)
+	if err != nil {
+		ErrorResponse(
+			c, http.StatusInternalServerError, models.NewErrorResponse(
+				"CREDENTIALS_ERROR",
+				"Failed to update credential policy",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, policy)
+}
+
+// ListAPIKeys returns the API keys issued by the router
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.admin.ListAPIKeys()
+	if err != nil {
+		apiKeyErrorResponse(c, "Failed to list API keys", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, keys)
+}
+
+// CreateAPIKey issues a new API key of the router. The response is the only one carrying the key.
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var req models.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(
+			c, http.StatusBadRequest, models.NewErrorResponse(
+				"INVALID_REQUEST",
+				"Invalid request body",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	key, err := h.admin.CreateAPIKey(c.GetString(adminActorKey), req)
+	if err != nil {
+		apiKeyErrorResponse(c, "Failed to create API key", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusCreated, key)
+}
+
+// RevokeAPIKey stops accepting an API key issued by the router
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	key, err := h.admin.RevokeAPIKey(c.GetString(adminActorKey), c.Param("id"))
+	if err != nil {
+		apiKeyErrorResponse(c, "Failed to revoke API key", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, key)
+}
+
+// GetReloadStatus returns the outcome of the last configuration reload
+func (h *Handler) GetReloadStatus(c *gin.Context) {
+	SuccessResponse(c, http.StatusOK, h.reloader.Status())
+}
+
+// ReloadConfig reads the configuration file again and applies it
+func (h *Handler) ReloadConfig(c *gin.Context) {
+	status, err := h.reloader.Reload(reloadTriggerAdmin)
+	h.admin.AuditReload(c.GetString(adminActorKey), status)
+	if err != nil {
+		ErrorResponse(
+			c, http.StatusUnprocessableEntity, models.NewErrorResponse(
+				"RELOAD_FAILED",
+				"Failed to reload configuration, the previous configuration stays in effect",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, status)
+}
+
+func providerErrorResponse(c *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	code := "PROVIDER_ERROR"
+	if errors.Is(err, service.ErrProviderNotFound) {
+		status = http.StatusNotFound
+		code = "PROVIDER_NOT_FOUND"
+	}
+
+	ErrorResponse(c, status, models.NewErrorResponse(code, message, err.Error()))
+}
+
+func apiKeyErrorResponse(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	code := "API_KEY_ERROR"
+	if errors.Is(err, service.ErrAPIKeyNotFound) {
+		status = http.StatusNotFound
+		code = "API_KEY_NOT_FOUND"
+	}
+
+	ErrorResponse(c, status, models.NewErrorResponse(code, message, err.Error()))
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBatchUploadSize limits the size of an uploaded batch file
+const maxBatchUploadSize = 100 << 20
+
+func (h *Handler) CreateBatch(c *gin.Context) {
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxBatchUploadSize)
+
+	job, err := h.batches.Create(service.CallerFromContext(c.Request.Context()), body, c.Query("callbackUrl"))
+	if err != nil {
+		ErrorResponse(
+			c, http.StatusBadRequest, models.NewErrorResponse(
+				"INVALID_REQUEST",
+				"Invalid batch",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	SuccessResponse(c, http.StatusAccepted, job)
+}
+
+func (h *Handler) ListBatches(c *gin.Context) {
+	jobs, err := h.batches.List(callerAPIKey(c))
+	if err != nil {
+		ErrorResponse(
+			c, http.StatusInternalServerError, models.NewErrorResponse(
+				"BATCH_ERROR",
+				"Failed to list batches",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, jobs)
+}
+
+func (h *Handler) GetBatch(c *gin.Context) {
+	job, err := h.batches.Get(callerAPIKey(c), c.Param("id"))
+	if err != nil {
+		batchErrorResponse(c, "Failed to get batch", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, job)
+}
+
+func (h *Handler) CancelBatch(c *gin.Context) {
+	job, err := h.batches.Cancel(callerAPIKey(c), c.Param("id"))
+	if err != nil {
+		batchErrorResponse(c, "Failed to cancel batch", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, job)
+}
+
+func (h *Handler) GetBatchResults(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := h.batches.Get(callerAPIKey(c), id); err != nil {
+		batchErrorResponse(c, "Failed to get batch results", err)
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", "attachment; filename=\""+id+".jsonl\"")
+	c.Status(http.StatusOK)
+	if err := h.batches.WriteResults(callerAPIKey(c), id, c.Writer); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+func batchErrorResponse(c *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	code := "BATCH_ERROR"
+	if errors.Is(err, service.ErrBatchNotFound) {
+		status = http.StatusNotFound
+		code = "BATCH_NOT_FOUND"
+	}
+
+	ErrorResponse(c, status, models.NewErrorResponse(code, message, err.Error()))
+}
@@ -1,8 +1,10 @@
 package api
 
 import (
+	"errors"
 	"io"
 	"net/http"
+	"time"
 
 	"workspace-engine/internal/llm-router/models"
 	"workspace-engine/internal/llm-router/service"
@@ -11,12 +13,38 @@ import (
 )
 
 type Handler struct {
-	router *service.RouterService
+	router    *service.RouterService
+	templates *service.TemplateService
+	usage     *service.UsageService
+	batches   *service.BatchService
+	admin     *service.AdminService
+	shadows   *service.ShadowService
+	sessions  *service.SessionService
+	reloader  *Reloader
+	lifecycle *Lifecycle
 }
 
-func NewHandler(router *service.RouterService) *Handler {
+func NewHandler(
+	router *service.RouterService,
+	templates *service.TemplateService,
+	usage *service.UsageService,
+	batches *service.BatchService,
+	admin *service.AdminService,
+	shadows *service.ShadowService,
+	sessions *service.SessionService,
+	reloader *Reloader,
+	lifecycle *Lifecycle,
+) *Handler {
 	return &Handler{
-		router: router,
+		router:    router,
+		templates: templates,
+		usage:     usage,
+		batches:   batches,
+		admin:     admin,
+		shadows:   shadows,
+		sessions:  sessions,
+		reloader:  reloader,
+		lifecycle: lifecycle,
 	}
 }
 
@@ -34,6 +62,18 @@ func (h *Handler) RoutePrompt(c *gin.Context) {
 	}
 
 	resp, err := h.router.Route(c.Request.Context(), req)
+	if errors.Is(err, service.ErrTemplateNotFound) {
+		templateErrorResponse(c, "Failed to route request", err)
+		return
+	}
+	if errors.Is(err, service.ErrCredentialsNotAllowed) {
+		credentialErrorResponse(c, "Failed to route request", err)
+		return
+	}
+	if errors.Is(err, service.ErrInvalidContent) || errors.Is(err, service.ErrUnsupportedContent) {
+		contentErrorResponse(c, "Failed to route request", err)
+		return
+	}
 	if err != nil {
 		ErrorResponse(
 			c, http.StatusInternalServerError, models.NewErrorResponse(
@@ -48,6 +88,102 @@ func (h *Handler) RoutePrompt(c *gin.Context) {
 	SuccessResponse(c, http.StatusOK, resp)
 }
 
+func (h *Handler) EstimateRoute(c *gin.Context) {
+	var req models.RouteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(
+			c, http.StatusBadRequest, models.NewErrorResponse(
+				"INVALID_REQUEST",
+				"Invalid request body",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	estimate, err := h.router.Estimate(c.Request.Context(), req)
+	if errors.Is(err, service.ErrTemplateNotFound) {
+		templateErrorResponse(c, "Failed to estimate request", err)
+		return
+	}
+	if errors.Is(err, service.ErrInvalidContent) || errors.Is(err, service.ErrUnsupportedContent) {
+		contentErrorResponse(c, "Failed to estimate request", err)
+		return
+	}
+	if err != nil {
+		ErrorResponse(
+			c, http.StatusInternalServerError, models.NewErrorResponse(
+				"ESTIMATE_ERROR",
+				"Failed to estimate request",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, estimate)
+}
+
+// DryRunRoute shows which routing rule and provider a request would be routed to, without sending it
+func (h *Handler) DryRunRoute(c *gin.Context) {
+	var req models.RouteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(
+			c, http.StatusBadRequest, models.NewErrorResponse(
+				"INVALID_REQUEST",
+				"Invalid request body",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	plan, err := h.router.DryRun(c.Request.Context(), req)
+	if errors.Is(err, service.ErrTemplateNotFound) {
+		templateErrorResponse(c, "Failed to plan request", err)
+		return
+	}
+	if errors.Is(err, service.ErrInvalidContent) || errors.Is(err, service.ErrUnsupportedContent) {
+		contentErrorResponse(c, "Failed to plan request", err)
+		return
+	}
+	if err != nil {
+		ErrorResponse(
+			c, http.StatusInternalServerError, models.NewErrorResponse(
+				"ROUTING_ERROR",
+				"Failed to plan request",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, plan)
+}
+
+// contentErrorResponse rejects requests with malformed content parts or parts the model cannot read
+func contentErrorResponse(c *gin.Context, message string, err error) {
+	code := "INVALID_CONTENT"
+	if errors.Is(err, service.ErrUnsupportedContent) {
+		code = "UNSUPPORTED_CONTENT"
+	}
+
+	ErrorResponse(c, http.StatusBadRequest, models.NewErrorResponse(code, message, err.Error()))
+}
+
+// credentialErrorResponse rejects requests with malformed provider API keys or keys their API key
+// may not send
+func credentialErrorResponse(c *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	code := "INVALID_CREDENTIALS"
+	if errors.Is(err, service.ErrCredentialsNotAllowed) {
+		status = http.StatusForbidden
+		code = "CREDENTIALS_NOT_ALLOWED"
+	}
+
+	ErrorResponse(c, status, models.NewErrorResponse(code, message, err.Error()))
+}
+
 func (h *Handler) GetModels(c *gin.Context) {
 	availableModels := h.router.GetAvailableModels()
 	c.JSON(http.StatusOK, availableModels)
@@ -58,6 +194,25 @@ func (h *Handler) GetHealth(c *gin.Context) {
 	c.JSON(http.StatusOK, health)
 }
 
+// GetLiveness reports that the process serves requests. It stays successful while the router
+// drains, so orchestrators do not restart it before the requests in flight have finished.
+func (h *Handler) GetLiveness(c *gin.Context) {
+	status := "alive"
+	if h.lifecycle.Draining() {
+		status = "draining"
+	}
+	c.JSON(http.StatusOK, gin.H{"status": status})
+}
+
+// GetReadiness reports whether the router accepts new requests, it fails once a shutdown started
+func (h *Handler) GetReadiness(c *gin.Context) {
+	if h.lifecycle.Draining() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
 func (h *Handler) StreamRoutePrompt(c *gin.Context) {
 	var req models.RouteRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -71,11 +226,22 @@ func (h *Handler) StreamRoutePrompt(c *gin.Context) {
 	c.Header("Connection", "keep-alive")
 
 	streamChan, err := h.router.RouteStream(c.Request.Context(), req)
+	if errors.Is(err, service.ErrCredentialsNotAllowed) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrInvalidContent) || errors.Is(err, service.ErrUnsupportedContent) {
+		contentErrorResponse(c, "Failed to stream request", err)
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	// A stream lasts as long as the model generates, the write timeout of the server does not apply
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
 	c.Stream(
 		func(w io.Writer) bool {
 			if msg, ok := <-streamChan; ok {
@@ -1,14 +1,42 @@
 package api
 
 import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service"
 	"workspace-engine/pkg/logger"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+const (
+	// RequestIDHeader carries the id used to correlate log records of a request
+	RequestIDHeader = "X-Request-ID"
+	// TenantHeader names the tenant a request is sent on behalf of, used by the routing rules. It
+	// must be one of the tenants the API key is bound to.
+	TenantHeader = "X-Tenant-ID"
+	// ProviderKeyHeader carries provider API keys of the caller in the form vendor=key
+	ProviderKeyHeader = "X-Provider-Key"
+)
+
+// AuthMiddleware accepts requests with a valid API key and attaches the caller to the request. The
+// tenants are looked up on every request, so the bindings of API keys to tenants follow
+// configuration reloads.
+func AuthMiddleware(keys *service.APIKeyService, tenants func() []config.TenantConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		apiKey := c.GetHeader("X-API-Key")
 		if apiKey == "" {
@@ -21,34 +49,172 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		//TODO: Implement API key validation
+		if err := keys.Validate(apiKey); err != nil {
+			status, code, message := http.StatusInternalServerError, "API_KEY_ERROR", "Failed to check API key"
+			if errors.Is(err, service.ErrInvalidAPIKey) {
+				status, code, message = http.StatusUnauthorized, "INVALID_API_KEY", "Invalid API key"
+			}
+			ErrorResponse(c, status, models.NewErrorResponse(code, message, err.Error()))
+			c.Abort()
+			return
+		}
+
+		credentials, err := service.ParseCredentials(c.Request.Header.Values(ProviderKeyHeader))
+		if err != nil {
+			credentialErrorResponse(c, "Invalid provider credentials", err)
+			c.Abort()
+			return
+		}
+		// Provider keys are only kept on the caller, so they cannot leak through the headers
+		c.Request.Header.Del(ProviderKeyHeader)
+
+		tenant, err := service.ResolveTenant(tenants(), apiKey, c.GetHeader(TenantHeader))
+		if err != nil {
+			ErrorResponse(
+				c, http.StatusForbidden, models.NewErrorResponse(
+					"TENANT_NOT_ALLOWED",
+					"Invalid tenant",
+					err.Error(),
+				),
+			)
+			c.Abort()
+			return
+		}
+
+		ctx := service.WithCaller(
+			c.Request.Context(), service.Caller{
+				APIKey:      apiKey,
+				Tenant:      tenant,
+				Headers:     c.Request.Header,
+				Credentials: credentials,
+			},
+		)
+		c.Request = c.Request.WithContext(ctx)
 
 		c.Next()
 	}
 }
 
+// adminActorKey is the context key of the name of the admin token a request was authenticated with
+const adminActorKey = "adminActor"
+
+// AdminAuthMiddleware accepts requests with one of the configured admin tokens as bearer token. The
+// tokens are looked up on every request, so they follow configuration reloads, and every request is
+// refused while none are configured.
+func AdminAuthMiddleware(tokens func() []config.AdminToken) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		admins := tokens()
+		if len(admins) == 0 {
+			ErrorResponse(
+				c, http.StatusForbidden, models.NewErrorResponse(
+					"ADMIN_DISABLED",
+					"The admin API is disabled, no admin tokens are configured",
+					nil,
+				),
+			)
+			c.Abort()
+			return
+		}
+
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if ok && token != "" {
+			for _, admin := range admins {
+				if admin.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(admin.Token.Value())) == 1 {
+					c.Set(adminActorKey, admin.Name)
+					c.Next()
+					return
+				}
+			}
+		}
+
+		ErrorResponse(
+			c, http.StatusUnauthorized, models.NewErrorResponse(
+				"UNAUTHORIZED",
+				"Invalid or missing admin token",
+				nil,
+			),
+		)
+		c.Abort()
+	}
+}
+
+// LoggingMiddleware assigns a request id to every request, taken from the X-Request-ID header when
+// the caller sends one, and logs the request with it
 func LoggingMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" {
+			requestID = uuid.New().String()
+		}
+		c.Header(RequestIDHeader, requestID)
+
+		ctx := logger.WithRequestID(c.Request.Context(), requestID)
+		c.Request = c.Request.WithContext(ctx)
+
 		// Log before request
-		logger.Info(
+		logger.InfoContext(
+			ctx,
 			"Incoming request",
 			"method", c.Request.Method,
 			"path", c.Request.URL.Path,
 			"client_ip", c.ClientIP(),
 		)
 
+		start := time.Now()
 		c.Next()
 
 		// Log after request
-		logger.Info(
+		logger.InfoContext(
+			ctx,
 			"Request completed",
 			"method", c.Request.Method,
 			"path", c.Request.URL.Path,
 			"status", c.Writer.Status(),
+			"duration_ms", time.Since(start).Milliseconds(),
 		)
 	}
 }
 
+// TracingMiddleware starts a server span for every request, continuing the W3C trace context
+// sent by the caller
+func TracingMiddleware() gin.HandlerFunc {
+	tracer := otel.Tracer("workspace-engine/internal/llm-router/api")
+
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(
+			c.Request.Context(), propagation.HeaderCarrier(c.Request.Header),
+		)
+
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+
+		ctx, span := tracer.Start(
+			ctx, fmt.Sprintf("%s %s", c.Request.Method, route),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("client.address", c.ClientIP()),
+			),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+	}
+}
+
 // Error handling middleware
 func ErrorMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -64,18 +230,138 @@ func ErrorMiddleware() gin.HandlerFunc {
 	}
 }
 
-// CORS middleware
-func CORSMiddleware() gin.HandlerFunc {
+var (
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	defaultCORSHeaders = []string{
+		"Content-Type", "Authorization", "X-API-Key", TenantHeader, ProviderKeyHeader, RequestIDHeader,
+	}
+)
+
+// CORSMiddleware lets browsers call the API from the allowed origins. Requests from other origins
+// get no CORS headers and their preflight requests are rejected. Nothing is added when CORS is
+// disabled.
+func CORSMiddleware(cfg config.CORSConfig) gin.HandlerFunc {
+	if !cfg.Enabled {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	configured := cfg.AllowedMethods
+	if len(configured) == 0 {
+		configured = defaultCORSMethods
+	}
+	methods := make([]string, 0, len(configured))
+	allowedMethods := make(map[string]bool, len(configured))
+	for _, method := range configured {
+		method = strings.ToUpper(method)
+		methods = append(methods, method)
+		allowedMethods[method] = true
+	}
+	headers := cfg.AllowedHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+
+	origins := newOriginMatcher(cfg.AllowedOrigins)
+	anyOrigin := origins.any && !cfg.AllowCredentials
+
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
+		header := c.Writer.Header()
+		if !anyOrigin {
+			header.Add("Vary", "Origin")
+		}
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if !origins.matches(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
 			return
 		}
 
-		c.Next()
+		if anyOrigin {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if len(cfg.ExposedHeaders) > 0 {
+				header.Set("Access-Control-Expose-Headers", strings.Join(cfg.ExposedHeaders, ", "))
+			}
+			c.Next()
+			return
+		}
+
+		if !allowedMethods[strings.ToUpper(c.GetHeader("Access-Control-Request-Method"))] {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		header.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+		if cfg.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// originMatcher matches origins against exact origins and origins with a wildcard subdomain
+type originMatcher struct {
+	any       bool
+	exact     map[string]bool
+	wildcards []wildcardOrigin
+}
+
+// wildcardOrigin is an origin such as https://*.example.com split around its wildcard
+type wildcardOrigin struct {
+	prefix string
+	suffix string
+}
+
+func newOriginMatcher(origins []string) *originMatcher {
+	m := &originMatcher{exact: make(map[string]bool, len(origins))}
+	for _, origin := range origins {
+		origin = strings.ToLower(origin)
+		if origin == "*" {
+			m.any = true
+			continue
+		}
+		if prefix, suffix, ok := strings.Cut(origin, "*."); ok {
+			m.wildcards = append(m.wildcards, wildcardOrigin{prefix: prefix, suffix: "." + suffix})
+			continue
+		}
+		m.exact[origin] = true
+	}
+	return m
+}
+
+// matches tells whether an origin is allowed. A wildcard matches subdomains at any depth but not
+// the domain itself, and the scheme and port must be the same.
+func (m *originMatcher) matches(origin string) bool {
+	origin = strings.ToLower(origin)
+	if m.any || m.exact[origin] {
+		return true
+	}
+	for _, wildcard := range m.wildcards {
+		if !strings.HasPrefix(origin, wildcard.prefix) || !strings.HasSuffix(origin, wildcard.suffix) {
+			continue
+		}
+		subdomain := strings.TrimSuffix(strings.TrimPrefix(origin, wildcard.prefix), wildcard.suffix)
+		if subdomain != "" && !strings.ContainsAny(subdomain, "/:@?#") {
+			return true
+		}
 	}
+	return false
 }
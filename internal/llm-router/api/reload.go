@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/pkg/logger"
+)
+
+const (
+	reloadTriggerStartup = "startup"
+	reloadTriggerWatch   = "watch"
+	reloadTriggerAdmin   = "admin"
+)
+
+// Reloader applies changes of the configuration file to the running services. The providers, the
+// model catalog, the routing rules, the tenants and the admin tokens follow the file, the server,
+// storage, logging, tracing, batch and shadow settings only change with a restart.
+type Reloader struct {
+	services *Services
+
+	// mu serializes reloads and guards the status
+	mu     sync.Mutex
+	status models.ReloadStatus
+}
+
+func NewReloader(services *Services) *Reloader {
+	now := time.Now().UTC().Format(time.RFC3339)
+	return &Reloader{
+		services: services,
+		status: models.ReloadStatus{
+			Generation:  1,
+			Status:      models.ReloadSucceeded,
+			Trigger:     reloadTriggerStartup,
+			LastAttempt: now,
+			LastSuccess: now,
+		},
+	}
+}
+
+// Watch reloads the configuration whenever its file changes, until the context is done
+func (r *Reloader) Watch(ctx context.Context) error {
+	return config.Watch(
+		ctx, func() {
+			_, _ = r.Reload(reloadTriggerWatch)
+		},
+	)
+}
+
+// Reload reads the configuration file and applies it when it is valid. The previous configuration
+// stays in effect when it is not.
+func (r *Reloader) Reload(trigger string) (models.ReloadStatus, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := config.Reload()
+	if err == nil {
+		err = r.services.apply(cfg)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	r.status.Trigger = trigger
+	r.status.LastAttempt = now
+	if err != nil {
+		r.status.Status = models.ReloadFailed
+		r.status.Error = err.Error()
+		logger.Error("Failed to reload configuration", "trigger", trigger, "error", err)
+		return r.status, err
+	}
+
+	r.status.Generation++
+	r.status.Status = models.ReloadSucceeded
+	r.status.LastSuccess = now
+	r.status.Error = ""
+	logger.Info("Reloaded configuration", "trigger", trigger, "generation", r.status.Generation)
+	return r.status, nil
+}
+
+// Status returns the outcome of the last reload
+func (r *Reloader) Status() models.ReloadStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
@@ -1,88 +1,331 @@
 package api
 
 import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
 	"workspace-engine/internal/llm-router/config"
 	"workspace-engine/internal/llm-router/service"
 	"workspace-engine/internal/llm-router/service/llm"
+	"workspace-engine/internal/llm-router/store"
 
 	"github.com/gin-gonic/gin"
 )
 
-func NewRouter(cfg *config.Config) *gin.Engine {
-	// Set Gin mode
-	if cfg.Server.Environment == "production" {
-		gin.SetMode(gin.ReleaseMode)
-	}
+// Services are the services shared by the HTTP and the gRPC API
+type Services struct {
+	Router    *service.RouterService
+	Templates *service.TemplateService
+	Usage     *service.UsageService
+	Batches   *service.BatchService
+	Admin     *service.AdminService
+	Shadows   *service.ShadowService
+	Sessions  *service.SessionService
+	Keys      *service.APIKeyService
+	Reloader  *Reloader
+	Lifecycle *Lifecycle
 
-	// Initialize router
-	router := gin.New()
+	// current is the configuration last applied, the provider factories create their instances
+	// from it
+	current *atomic.Pointer[config.Config]
+}
 
-	// Add middleware
-	router.Use(gin.Recovery())
-	router.Use(LoggingMiddleware())
-	router.Use(ErrorMiddleware())
-	router.Use(CORSMiddleware())
+// Config returns the configuration the services run with
+func (s *Services) Config() *config.Config {
+	return s.current.Load()
+}
 
-	// Initialize providers
+// apply replaces the providers, the model catalog and the routing rules by those of a new
+// configuration
+func (s *Services) apply(cfg *config.Config) error {
+	providers, listers, err := NewProviders(cfg)
+	if err != nil {
+		return err
+	}
+	if err := s.Router.Reload(providers, listers, cfg); err != nil {
+		return fmt.Errorf("invalid routing rules: %w", err)
+	}
+	s.current.Store(cfg)
+	return nil
+}
+
+// NewProviders creates the providers of the enabled vendors, keyed "<vendor>_<model>" and
+// "<vendor>_default", and the model listers used to discover the models of every vendor
+func NewProviders(cfg *config.Config) (map[string]llm.Provider, map[string]llm.ModelLister, error) {
 	providers := map[string]llm.Provider{}
+	for _, vendor := range []string{"openai", "anthropic", "openrouter", "groq"} {
+		providerConfig, _ := cfg.GetProviderConfig(vendor)
+		if !providerConfig.Enabled {
+			continue
+		}
+
+		// The models of a vendor share the state of its API keys
+		var pool *llm.KeyPoolProvider
 
-	openAI := cfg.Providers.OpenAI
-	if openAI.Enabled {
-		providers["openai_default"] = llm.NewOpenAIProvider(openAI.APIKey, openAI.DefaultModel)
-		for _, model := range openAI.Models {
-			providers["openai_"+model.Name] = llm.NewOpenAIProvider(openAI.APIKey, model.Name)
+		modelNames := []string{providerConfig.DefaultModel}
+		for _, model := range providerConfig.Models {
+			modelNames = append(modelNames, model.Name)
+		}
+		for i, model := range modelNames {
+			key := vendor + "_" + model
+			if i == 0 {
+				key = vendor + "_default"
+			}
+			provider, err := newProvider(cfg, vendor, model, pool)
+			if err != nil {
+				return nil, nil, err
+			}
+			if pool == nil {
+				pool, _ = provider.(*llm.KeyPoolProvider)
+			}
+			providers[key] = provider
 		}
 	}
 
-	anthropic := cfg.Providers.Anthropic
-	if anthropic.Enabled {
-		providers["anthropic_default"] = llm.NewAnthropicProvider(anthropic.APIKey, anthropic.DefaultModel)
-		for _, model := range anthropic.Models {
-			providers["anthropic_"+model.Name] = llm.NewAnthropicProvider(anthropic.APIKey, model.Name)
+	// Use the default instance of every vendor to discover its models
+	listers := map[string]llm.ModelLister{}
+	for key, provider := range providers {
+		vendor, model, _ := strings.Cut(key, "_")
+		if lister, ok := provider.(llm.ModelLister); ok && model == "default" {
+			listers[vendor] = lister
 		}
 	}
 
-	openRouter := cfg.Providers.OpenRouter
-	if openRouter.Enabled {
-		httpHeaders := map[string]string{
-			"HTTP-Referer": "officekube.io",
-			"X-Title":      "LLM Router",
-		}
-		providers["openrouter_default"] = llm.NewOpenRouterProvider(
-			openRouter.APIKey, openRouter.DefaultModel, httpHeaders,
-		)
-		for _, model := range openRouter.Models {
-			providers["openrouter_"+model.Name] = llm.NewOpenRouterProvider(openRouter.APIKey, model.Name, httpHeaders)
-		}
+	for key, provider := range providers {
+		vendor, _, _ := strings.Cut(key, "_")
+		providers[key] = wrapProvider(cfg, vendor, provider)
 	}
+	return providers, listers, nil
+}
 
-	groq := cfg.Providers.Groq
-	if groq.Enabled {
-		providers["groq_default"] = llm.NewGroqProvider(groq.APIKey, groq.DefaultModel)
-		for _, model := range groq.Models {
-			providers["groq_"+model.Name] = llm.NewGroqProvider(groq.APIKey, model.Name)
-		}
+// NewServices creates the providers of the enabled vendors and the services built on them
+func NewServices(cfg *config.Config) (*Services, error) {
+	providers, listers, err := NewProviders(cfg)
+	if err != nil {
+		return nil, err
+	}
+	current := &atomic.Pointer[config.Config]{}
+	current.Store(cfg)
+
+	// Initialize storage
+	db, err := store.Open(cfg.Storage.Path)
+	if err != nil {
+		return nil, err
 	}
 
 	// Initialize services
-	routerService := service.NewRouterService(providers)
-	handler := NewHandler(routerService)
+	templateService := service.NewTemplateService(db)
+	usageService := service.NewUsageService(db)
+	catalogService := service.NewCatalogService(providers, listers, cfg)
+	catalogService.Start(context.Background())
+	routerService := service.NewRouterService(providers, catalogService, templateService, usageService)
+	rules, err := service.NewRuleEngine(cfg.Routing)
+	if err != nil {
+		return nil, err
+	}
+	routerService.SetRules(rules)
+	routerService.SetCoalescing(cfg.Routing.Coalesce)
+	shadowService := service.NewShadowService(db, cfg.Routing.Shadow)
+	shadowService.Start(context.Background())
+	routerService.SetShadows(shadowService)
+	credentialService := service.NewCredentialService(
+		db, func(vendor, model, apiKey string) (llm.Provider, error) {
+			provider, err := newVendorProvider(vendor, model, apiKey)
+			if err != nil {
+				return nil, err
+			}
+			return wrapProvider(current.Load(), vendor, provider), nil
+		},
+	)
+	routerService.SetCredentials(credentialService)
+	keyService := service.NewAPIKeyService(db, func() []config.TenantConfig { return current.Load().Tenants })
+	batchService := service.NewBatchService(db, routerService, cfg.Batch)
+	if err := batchService.Resume(); err != nil {
+		return nil, err
+	}
+	adminService := service.NewAdminService(
+		db, routerService.Providers(), func(vendor, model string) (llm.Provider, error) {
+			cfg := current.Load()
+			// The new model shares the state of the API keys with the other models of the vendor
+			var pool *llm.KeyPoolProvider
+			if existing, ok := routerService.Providers().Get(vendor + "_default"); ok {
+				pool, _ = llm.KeyPoolOf(existing)
+			}
+			provider, err := newProvider(cfg, vendor, model, pool)
+			if err != nil {
+				return nil, err
+			}
+			return wrapProvider(cfg, vendor, provider), nil
+		},
+		credentialService, keyService,
+	)
+
+	services := &Services{
+		Router:    routerService,
+		Templates: templateService,
+		Usage:     usageService,
+		Batches:   batchService,
+		Admin:     adminService,
+		Shadows:   shadowService,
+		Sessions:  service.NewSessionService(db, routerService),
+		Keys:      keyService,
+		Lifecycle: NewLifecycle(),
+		current:   current,
+	}
+	services.Reloader = NewReloader(services)
+	return services, nil
+}
+
+// NewRouter creates the HTTP API on top of the services
+func NewRouter(cfg *config.Config, services *Services) *gin.Engine {
+	// Set Gin mode
+	if cfg.Server.Environment == "production" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	// Initialize router
+	router := gin.New()
+
+	// Add middleware
+	router.Use(gin.Recovery())
+	router.Use(TracingMiddleware())
+	router.Use(LoggingMiddleware())
+	router.Use(ErrorMiddleware())
+	router.Use(CORSMiddleware(cfg.Server.CORS))
+
+	handler := NewHandler(
+		services.Router, services.Templates, services.Usage, services.Batches, services.Admin, services.Shadows,
+		services.Sessions, services.Reloader, services.Lifecycle,
+	)
 
 	// API routes
 	api := router.Group("/api/v1")
 	{
 		// Public endpoints
 		api.GET("/health", handler.GetHealth)
+		api.GET("/health/live", handler.GetLiveness)
+		api.GET("/health/ready", handler.GetReadiness)
 
 		// Protected endpoints
 		protected := api.Group("")
-		protected.Use(AuthMiddleware())
+		protected.Use(AuthMiddleware(services.Keys, func() []config.TenantConfig { return services.Config().Tenants }))
 		{
 			protected.POST("/route", handler.RoutePrompt)
 			protected.GET("/route/stream", handler.StreamRoutePrompt)
+			protected.POST("/route/dry-run", handler.DryRunRoute)
+			protected.POST("/estimate", handler.EstimateRoute)
 			protected.GET("/models", handler.GetModels)
+			protected.GET("/usage", handler.GetUsage)
+
+			// Templates are shared by every API key, so only admins change them
+			protected.GET("/templates", handler.ListTemplates)
+			protected.GET("/templates/:name", handler.GetTemplate)
+			protected.GET("/templates/:name/versions", handler.GetTemplateVersions)
+
+			protected.GET("/batches", handler.ListBatches)
+			protected.POST("/batches", handler.CreateBatch)
+			protected.GET("/batches/:id", handler.GetBatch)
+			protected.POST("/batches/:id/cancel", handler.CancelBatch)
+			protected.GET("/batches/:id/results", handler.GetBatchResults)
+
+			protected.GET("/sessions", handler.ListSessions)
+			protected.POST("/sessions", handler.CreateSession)
+			protected.GET("/sessions/:id", handler.GetSession)
+			protected.DELETE("/sessions/:id", handler.DeleteSession)
+			protected.GET("/sessions/:id/export", handler.ExportSession)
+			protected.POST("/sessions/:id/messages", handler.SendSessionMessage)
+		}
+
+		// Admin endpoints, refused while no admin tokens are configured. They are mounted even then,
+		// so tokens added by a reload take effect.
+		admin := api.Group("/admin")
+		admin.Use(
+			AdminAuthMiddleware(
+				func() []config.AdminToken {
+					return services.Config().Admin.Tokens
+				},
+			),
+		)
+		{
+			admin.GET("/providers", handler.ListProviders)
+			admin.GET("/providers/:key", handler.GetProvider)
+			admin.PATCH("/providers/:key", handler.UpdateProvider)
+			admin.POST("/providers/:key/probe", handler.ProbeProvider)
+			admin.POST("/templates", handler.CreateTemplate)
+			admin.PUT("/templates/:name", handler.UpdateTemplate)
+			admin.DELETE("/templates/:name", handler.DeleteTemplate)
+			admin.GET("/audit", handler.GetAudit)
+			admin.GET("/shadows", handler.ListShadowResults)
+			admin.GET("/credentials", handler.ListCredentialPolicies)
+			admin.PUT("/credentials", handler.SetCredentialPolicy)
+			admin.GET("/api-keys", handler.ListAPIKeys)
+			admin.POST("/api-keys", handler.CreateAPIKey)
+			admin.POST("/api-keys/:id/revoke", handler.RevokeAPIKey)
+			admin.GET("/reload", handler.GetReloadStatus)
+			admin.POST("/reload", handler.ReloadConfig)
 		}
 	}
 
 	return router
 }
+
+// newProvider creates the client of a vendor for a single model. A vendor with a pool of API keys
+// gets a client per key that the calls are spread over. A given pool of another model of the vendor
+// shares the state of its keys with the new one.
+func newProvider(cfg *config.Config, vendor, model string, pool *llm.KeyPoolProvider) (llm.Provider, error) {
+	providerConfig, err := cfg.GetProviderConfig(vendor)
+	if err != nil {
+		return nil, err
+	}
+	if len(providerConfig.APIKeys) == 0 {
+		return newVendorProvider(vendor, model, providerConfig.APIKey.Value())
+	}
+
+	keys := make([]llm.PooledKey, 0, len(providerConfig.APIKeys))
+	clients := make([]llm.Provider, 0, len(providerConfig.APIKeys))
+	for _, key := range providerConfig.APIKeys {
+		provider, err := newVendorProvider(vendor, model, key.Key.Value())
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, llm.PooledKey{Name: key.Name, Weight: key.Weight, Provider: provider})
+		clients = append(clients, provider)
+	}
+	if pool != nil {
+		return pool.ForModel(clients)
+	}
+	return llm.NewKeyPoolProvider(keys, providerConfig.KeyRotation)
+}
+
+// newVendorProvider creates the client of a vendor for a single model that authenticates with the
+// API key
+func newVendorProvider(vendor, model, apiKey string) (llm.Provider, error) {
+	switch vendor {
+	case "openai":
+		return llm.NewOpenAIProvider(apiKey, model), nil
+	case "anthropic":
+		return llm.NewAnthropicProvider(apiKey, model), nil
+	case "openrouter":
+		httpHeaders := map[string]string{
+			"HTTP-Referer": "officekube.io",
+			"X-Title":      "LLM Router",
+		}
+		return llm.NewOpenRouterProvider(apiKey, model, httpHeaders), nil
+	case "groq":
+		return llm.NewGroqProvider(apiKey, model), nil
+	default:
+		return nil, fmt.Errorf("unknown provider: %s", vendor)
+	}
+}
+
+// wrapProvider retries failed calls with the policy of the vendor and records a span for every
+// provider call
+func wrapProvider(cfg *config.Config, vendor string, provider llm.Provider) llm.Provider {
+	var retry config.RetryConfig
+	if providerConfig, err := cfg.GetProviderConfig(vendor); err == nil {
+		retry = providerConfig.Retry
+	}
+	return llm.NewTracedProvider(llm.NewRetryProvider(provider, retry))
+}
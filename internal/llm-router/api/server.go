@@ -0,0 +1,105 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"workspace-engine/internal/llm-router/config"
+)
+
+const (
+	defaultServerTimeout     = 30 * time.Second
+	defaultWriteTimeout      = 5 * time.Minute
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultIdleTimeout       = 2 * time.Minute
+	defaultShutdownTimeout   = 30 * time.Second
+)
+
+// NewServer creates the HTTP server of the router from the server configuration
+func NewServer(cfg config.ServerConfig, handler http.Handler) (*http.Server, error) {
+	tlsConfig, err := NewTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Server{
+		Addr:              fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadTimeout:       withDefault(cfg.Timeout, defaultServerTimeout),
+		ReadHeaderTimeout: withDefault(cfg.ReadHeaderTimeout, defaultReadHeaderTimeout),
+		WriteTimeout:      withDefault(cfg.WriteTimeout, defaultWriteTimeout),
+		IdleTimeout:       withDefault(cfg.IdleTimeout, defaultIdleTimeout),
+	}, nil
+}
+
+// NewTLSConfig loads the certificate of the server and the CA client certificates are verified
+// with. It returns nil when TLS is disabled.
+func NewTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("client CA file contains no certificates")
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		if cfg.ClientAuth == config.ClientAuthVerifyIfGiven {
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	return tlsConfig, nil
+}
+
+// ShutdownTimeout is how long requests in flight may take to finish after a shutdown signal
+func ShutdownTimeout(cfg config.ServerConfig) time.Duration {
+	return withDefault(cfg.ShutdownTimeout, defaultShutdownTimeout)
+}
+
+func withDefault(value, fallback time.Duration) time.Duration {
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// Lifecycle tracks whether the router is draining for a shutdown. A draining router reports that
+// it is not ready, so load balancers stop sending it requests while those in flight finish.
+type Lifecycle struct {
+	draining atomic.Bool
+}
+
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{}
+}
+
+// Drain marks the router as shutting down
+func (l *Lifecycle) Drain() {
+	l.draining.Store(true)
+}
+
+func (l *Lifecycle) Draining() bool {
+	return l.draining.Load()
+}
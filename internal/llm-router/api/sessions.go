@@ -0,0 +1,136 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) CreateSession(c *gin.Context) {
+	var req models.SessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(
+			c, http.StatusBadRequest, models.NewErrorResponse(
+				"INVALID_REQUEST",
+				"Invalid request body",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	session, err := h.sessions.Create(callerAPIKey(c), req)
+	if err != nil {
+		sessionErrorResponse(c, "Failed to create session", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusCreated, session)
+}
+
+func (h *Handler) ListSessions(c *gin.Context) {
+	sessions, err := h.sessions.List(callerAPIKey(c))
+	if err != nil {
+		sessionErrorResponse(c, "Failed to list sessions", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, sessions)
+}
+
+func (h *Handler) GetSession(c *gin.Context) {
+	session, err := h.sessions.Get(callerAPIKey(c), c.Param("id"))
+	if err != nil {
+		sessionErrorResponse(c, "Failed to get session", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, session)
+}
+
+// ExportSession returns the session with its whole transcript as a JSON download
+func (h *Handler) ExportSession(c *gin.Context) {
+	session, err := h.sessions.Export(callerAPIKey(c), c.Param("id"))
+	if err != nil {
+		sessionErrorResponse(c, "Failed to export session", err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"session-%s.json\"", session.ID))
+	SuccessResponse(c, http.StatusOK, session)
+}
+
+func (h *Handler) DeleteSession(c *gin.Context) {
+	if err := h.sessions.Delete(callerAPIKey(c), c.Param("id")); err != nil {
+		sessionErrorResponse(c, "Failed to delete session", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SendSessionMessage routes a user message with the transcript of the session and returns the reply
+func (h *Handler) SendSessionMessage(c *gin.Context) {
+	var turn models.SessionTurn
+	if err := c.ShouldBindJSON(&turn); err != nil {
+		ErrorResponse(
+			c, http.StatusBadRequest, models.NewErrorResponse(
+				"INVALID_REQUEST",
+				"Invalid request body",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	resp, err := h.sessions.Send(c.Request.Context(), callerAPIKey(c), c.Param("id"), turn)
+	if errors.Is(err, service.ErrSessionNotFound) || errors.Is(err, service.ErrSessionConflict) {
+		sessionErrorResponse(c, "Failed to send message", err)
+		return
+	}
+	if errors.Is(err, service.ErrCredentialsNotAllowed) {
+		credentialErrorResponse(c, "Failed to send message", err)
+		return
+	}
+	if errors.Is(err, service.ErrInvalidContent) || errors.Is(err, service.ErrUnsupportedContent) {
+		contentErrorResponse(c, "Failed to send message", err)
+		return
+	}
+	if err != nil {
+		ErrorResponse(
+			c, http.StatusInternalServerError, models.NewErrorResponse(
+				"ROUTING_ERROR",
+				"Failed to send message",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, resp)
+}
+
+// callerAPIKey returns the API key the request was authenticated with
+func callerAPIKey(c *gin.Context) string {
+	return service.CallerFromContext(c.Request.Context()).APIKey
+}
+
+func sessionErrorResponse(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	code := "SESSION_ERROR"
+	switch {
+	case errors.Is(err, service.ErrSessionNotFound):
+		status = http.StatusNotFound
+		code = "SESSION_NOT_FOUND"
+	case errors.Is(err, service.ErrSessionConflict):
+		status = http.StatusConflict
+		code = "SESSION_CONFLICT"
+	}
+
+	ErrorResponse(c, status, models.NewErrorResponse(code, message, err.Error()))
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) ListTemplates(c *gin.Context) {
+	templates, err := h.templates.List()
+	if err != nil {
+		ErrorResponse(
+			c, http.StatusInternalServerError, models.NewErrorResponse(
+				"TEMPLATE_ERROR",
+				"Failed to list templates",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, templates)
+}
+
+func (h *Handler) CreateTemplate(c *gin.Context) {
+	var req models.TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(
+			c, http.StatusBadRequest, models.NewErrorResponse(
+				"INVALID_REQUEST",
+				"Invalid request body",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	tmpl, err := h.templates.Create(req)
+	if err != nil {
+		ErrorResponse(
+			c, http.StatusBadRequest, models.NewErrorResponse(
+				"TEMPLATE_ERROR",
+				"Failed to create template",
+				err.Error(),
+			),
+		)
+		return
+	}
+	h.admin.AuditTemplate(c.GetString(adminActorKey), service.AuditActionTemplateCreate, tmpl.Name, tmpl.Version)
+
+	SuccessResponse(c, http.StatusCreated, tmpl)
+}
+
+func (h *Handler) GetTemplate(c *gin.Context) {
+	version, ok := templateVersion(c)
+	if !ok {
+		return
+	}
+
+	tmpl, err := h.templates.Get(c.Param("name"), version)
+	if err != nil {
+		templateErrorResponse(c, "Failed to get template", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, tmpl)
+}
+
+func (h *Handler) GetTemplateVersions(c *gin.Context) {
+	templates, err := h.templates.Versions(c.Param("name"))
+	if err != nil {
+		templateErrorResponse(c, "Failed to list template versions", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, templates)
+}
+
+func (h *Handler) UpdateTemplate(c *gin.Context) {
+	var req models.TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(
+			c, http.StatusBadRequest, models.NewErrorResponse(
+				"INVALID_REQUEST",
+				"Invalid request body",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	tmpl, err := h.templates.Update(c.Param("name"), req)
+	if err != nil {
+		templateErrorResponse(c, "Failed to update template", err)
+		return
+	}
+	h.admin.AuditTemplate(c.GetString(adminActorKey), service.AuditActionTemplateUpdate, tmpl.Name, tmpl.Version)
+
+	SuccessResponse(c, http.StatusOK, tmpl)
+}
+
+func (h *Handler) DeleteTemplate(c *gin.Context) {
+	version, ok := templateVersion(c)
+	if !ok {
+		return
+	}
+
+	if err := h.templates.Delete(c.Param("name"), version); err != nil {
+		templateErrorResponse(c, "Failed to delete template", err)
+		return
+	}
+	h.admin.AuditTemplate(c.GetString(adminActorKey), service.AuditActionTemplateDelete, c.Param("name"), version)
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) GetUsage(c *gin.Context) {
+	var filter models.UsageFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		ErrorResponse(
+			c, http.StatusBadRequest, models.NewErrorResponse(
+				"INVALID_REQUEST",
+				"Invalid query parameters",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	records, err := h.usage.List(callerAPIKey(c), filter)
+	if err != nil {
+		ErrorResponse(
+			c, http.StatusInternalServerError, models.NewErrorResponse(
+				"USAGE_ERROR",
+				"Failed to list usage",
+				err.Error(),
+			),
+		)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, records)
+}
+
+// templateVersion reads the optional version query parameter, 0 meaning the latest version
+func templateVersion(c *gin.Context) (int, bool) {
+	value := c.Query("version")
+	if value == "" {
+		return 0, true
+	}
+
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		ErrorResponse(
+			c, http.StatusBadRequest, models.NewErrorResponse(
+				"INVALID_REQUEST",
+				"Invalid template version",
+				value,
+			),
+		)
+		return 0, false
+	}
+	return version, true
+}
+
+func templateErrorResponse(c *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	code := "TEMPLATE_ERROR"
+	if errors.Is(err, service.ErrTemplateNotFound) {
+		status = http.StatusNotFound
+		code = "TEMPLATE_NOT_FOUND"
+	}
+
+	ErrorResponse(c, status, models.NewErrorResponse(code, message, err.Error()))
+}
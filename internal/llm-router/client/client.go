@@ -0,0 +1,393 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"workspace-engine/internal/llm-router/models"
+)
+
+const apiPrefix = "/api/v1"
+
+// Client calls the HTTP API of a running router
+type Client struct {
+	endpoint   string
+	apiKey     string
+	adminToken string
+	http       *http.Client
+}
+
+// NewClient creates a client for the router at the endpoint of the configuration
+func NewClient(cfg Config) *Client {
+	return &Client{
+		endpoint:   strings.TrimSuffix(cfg.Endpoint, "/"),
+		apiKey:     cfg.APIKey,
+		adminToken: cfg.AdminToken,
+		http:       &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// APIError is an error response of the router
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Details    interface{}
+}
+
+func (e *APIError) Error() string {
+	message := fmt.Sprintf("router returned status %d", e.StatusCode)
+	if e.Code != "" {
+		message += ": " + e.Code
+	}
+	if e.Message != "" {
+		message += ": " + e.Message
+	}
+	if e.Details != nil {
+		message += fmt.Sprintf(" (%v)", e.Details)
+	}
+	return message
+}
+
+// Route sends a prompt and waits for the complete response
+func (c *Client) Route(ctx context.Context, req models.RouteRequest) (*models.RouteResponse, error) {
+	var resp models.RouteResponse
+	if err := c.call(ctx, http.MethodPost, "/route", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Stream sends a prompt and passes the chunks of the response to the callback as they arrive
+func (c *Client) Stream(ctx context.Context, req models.RouteRequest, chunk func(content string)) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := c.newRequest(ctx, http.MethodGet, apiPrefix+"/route/stream", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	// A stream lasts as long as the model generates, the timeout of the client does not apply
+	streaming := *c.http
+	streaming.Timeout = 0
+	resp, err := streaming.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return decodeError(resp)
+	}
+	return readEvents(
+		resp.Body, func(event, data string) (bool, error) {
+			switch event {
+			case "error":
+				return false, errors.New(data)
+			case "message":
+				var msg models.StreamResponse
+				if err := json.Unmarshal([]byte(data), &msg); err != nil {
+					return false, fmt.Errorf("invalid stream message: %w", err)
+				}
+				chunk(msg.Content)
+				return !msg.Done, nil
+			}
+			return true, nil
+		},
+	)
+}
+
+// readEvents reads server-sent events until the handler stops or the stream ends
+func readEvents(r io.Reader, handle func(event, data string) (bool, error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	event := "message"
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(data) > 0 {
+				more, err := handle(event, strings.Join(data, "\n"))
+				if err != nil || !more {
+					return err
+				}
+			}
+			event, data = "message", nil
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(data) > 0 {
+		_, err := handle(event, strings.Join(data, "\n"))
+		return err
+	}
+	return nil
+}
+
+// Models returns the models of the catalog
+func (c *Client) Models(ctx context.Context) ([]models.ModelInfo, error) {
+	var list []models.ModelInfo
+	err := c.call(ctx, http.MethodGet, "/models", nil, &list)
+	return list, err
+}
+
+// Health returns the health of the router and its models
+func (c *Client) Health(ctx context.Context) (*models.HealthStatus, error) {
+	var health models.HealthStatus
+	if err := c.call(ctx, http.MethodGet, "/health", nil, &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// Ready tells whether the router accepts new requests, a draining router is not ready
+func (c *Client) Ready(ctx context.Context) (bool, error) {
+	err := c.call(ctx, http.MethodGet, "/health/ready", nil, nil)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusServiceUnavailable {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Usage returns the usage records matching the filter, the latest first
+func (c *Client) Usage(ctx context.Context, filter models.UsageFilter) ([]models.UsageRecord, error) {
+	query := url.Values{}
+	if filter.Model != "" {
+		query.Set("model", filter.Model)
+	}
+	if filter.TemplateName != "" {
+		query.Set("template", filter.TemplateName)
+	}
+	if filter.TemplateVersion > 0 {
+		query.Set("templateVersion", strconv.Itoa(filter.TemplateVersion))
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+
+	path := "/usage"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var records []models.UsageRecord
+	err := c.call(ctx, http.MethodGet, path, nil, &records)
+	return records, err
+}
+
+// CreateBatch submits a batch file with one request per line
+func (c *Client) CreateBatch(ctx context.Context, batch io.Reader) (*models.BatchJob, error) {
+	req, err := c.newRequest(ctx, http.MethodPost, apiPrefix+"/batches", batch)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	var job models.BatchJob
+	if err := c.do(req, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Batch returns the progress of a batch
+func (c *Client) Batch(ctx context.Context, id string) (*models.BatchJob, error) {
+	var job models.BatchJob
+	if err := c.call(ctx, http.MethodGet, "/batches/"+url.PathEscape(id), nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// BatchResults copies the results of a batch, one JSON line per request, to the writer
+func (c *Client) BatchResults(ctx context.Context, id string, w io.Writer) error {
+	req, err := c.newRequest(ctx, http.MethodGet, apiPrefix+"/batches/"+url.PathEscape(id)+"/results", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return decodeError(resp)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Providers returns the runtime state of the provider instances, including the usage of their
+// API keys. It needs an admin token.
+func (c *Client) Providers(ctx context.Context) ([]models.ProviderStatus, error) {
+	var statuses []models.ProviderStatus
+	err := c.call(ctx, http.MethodGet, "/admin/providers", nil, &statuses)
+	return statuses, err
+}
+
+// CredentialPolicies returns which API keys may send their own provider API keys. It needs an
+// admin token.
+func (c *Client) CredentialPolicies(ctx context.Context) ([]models.CredentialPolicy, error) {
+	var policies []models.CredentialPolicy
+	err := c.call(ctx, http.MethodGet, "/admin/credentials", nil, &policies)
+	return policies, err
+}
+
+// SetCredentialPolicy allows or denies provider API keys for an API key of the router. It needs an
+// admin token.
+func (c *Client) SetCredentialPolicy(
+	ctx context.Context, apiKey string, allowed bool,
+) (*models.CredentialPolicy, error) {
+	update := models.CredentialPolicyUpdate{APIKey: apiKey, Allowed: &allowed}
+	var policy models.CredentialPolicy
+	if err := c.call(ctx, http.MethodPut, "/admin/credentials", update, &policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// APIKeys returns the API keys issued by the router. It needs an admin token.
+func (c *Client) APIKeys(ctx context.Context) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := c.call(ctx, http.MethodGet, "/admin/api-keys", nil, &keys)
+	return keys, err
+}
+
+// CreateAPIKey issues a new API key of the router. The returned key is the only place the key
+// itself appears. It needs an admin token.
+func (c *Client) CreateAPIKey(ctx context.Context, name string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := c.call(ctx, http.MethodPost, "/admin/api-keys", models.APIKeyRequest{Name: name}, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// RevokeAPIKey stops the router from accepting an API key it issued. It needs an admin token.
+func (c *Client) RevokeAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := c.call(ctx, http.MethodPost, "/admin/api-keys/"+url.PathEscape(id)+"/revoke", nil, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// call sends a request with an optional JSON body to a path of the API and decodes the response
+// into out, unless it is nil
+func (c *Client) call(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := c.newRequest(ctx, method, apiPrefix+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.do(req, out)
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.adminToken != "" && strings.HasPrefix(path, apiPrefix+"/admin/") {
+		req.Header.Set("Authorization", "Bearer "+c.adminToken)
+	}
+	return req, nil
+}
+
+// do sends a request and decodes the response. Most endpoints wrap their data in the success
+// envelope of the API, the others return it as it is.
+func (c *Client) do(req *http.Request, out interface{}) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var envelope struct {
+		Success *bool           `json:"success"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err == nil && envelope.Success != nil {
+		body = envelope.Data
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("invalid response of the router: %w", err)
+	}
+	return nil
+}
+
+// decodeError reads an error response, which is either the error envelope of the API or a plain
+// error message
+func decodeError(resp *http.Response) error {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	var envelope struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil || len(envelope.Error) == 0 {
+		apiErr.Message = strings.TrimSpace(string(body))
+		return apiErr
+	}
+
+	var structured models.ErrorResponse
+	if err := json.Unmarshal(envelope.Error, &structured); err == nil {
+		apiErr.Code = structured.Code
+		apiErr.Message = structured.Message
+		apiErr.Details = structured.Details
+		return apiErr
+	}
+	_ = json.Unmarshal(envelope.Error, &apiErr.Message)
+	return apiErr
+}
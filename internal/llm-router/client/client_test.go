@@ -0,0 +1,175 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"workspace-engine/internal/llm-router/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	var headers http.Header
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				headers = r.Header.Clone()
+				switch r.URL.Path {
+				case "/api/v1/route":
+					fmt.Fprint(w, `{"success":true,"data":{"id":"1","result":"hello","model":"gpt-4"}}`)
+				case "/api/v1/route/stream":
+					w.Header().Set("Content-Type", "text/event-stream")
+					fmt.Fprint(w, "event:message\ndata:{\"content\":\"hel\",\"done\":false}\n\n")
+					fmt.Fprint(w, "event: message\ndata: {\"content\":\"lo\",\"done\":true}\n\n")
+					fmt.Fprint(w, "event:message\ndata:{\"content\":\"ignored\",\"done\":false}\n\n")
+				case "/api/v1/models":
+					fmt.Fprint(w, `[{"id":"gpt-4","provider":"openai"}]`)
+				case "/api/v1/health/ready":
+					w.WriteHeader(http.StatusServiceUnavailable)
+					fmt.Fprint(w, `{"status":"draining"}`)
+				case "/api/v1/usage":
+					assert.Equal(t, "gpt-4", r.URL.Query().Get("model"))
+					fmt.Fprint(w, `{"success":true,"data":[{"requestId":"1","model":"gpt-4"}]}`)
+				case "/api/v1/admin/providers":
+					fmt.Fprint(w, `{"success":true,"data":[{"key":"openai_default","keys":[{"name":"primary"}]}]}`)
+				case "/api/v1/batches/missing":
+					w.WriteHeader(http.StatusNotFound)
+					fmt.Fprint(w, `{"success":false,"error":{"code":"NOT_FOUND","message":"Failed to get batch"}}`)
+				default:
+					w.WriteHeader(http.StatusBadRequest)
+					fmt.Fprint(w, `{"error":"Invalid request body"}`)
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	c := NewClient(Config{Endpoint: server.URL + "/", APIKey: "key", AdminToken: "token", Timeout: time.Second})
+	ctx := context.Background()
+
+	t.Run(
+		"DecodesTheSuccessEnvelope", func(t *testing.T) {
+			resp, err := c.Route(ctx, models.RouteRequest{Prompt: "hi"})
+			require.NoError(t, err)
+			assert.Equal(t, "hello", resp.Result)
+			assert.Equal(t, "key", headers.Get("X-API-Key"))
+			assert.Empty(t, headers.Get("Authorization"))
+
+			records, err := c.Usage(ctx, models.UsageFilter{Model: "gpt-4"})
+			require.NoError(t, err)
+			require.Len(t, records, 1)
+		},
+	)
+
+	t.Run(
+		"DecodesPlainResponses", func(t *testing.T) {
+			list, err := c.Models(ctx)
+			require.NoError(t, err)
+			require.Len(t, list, 1)
+			assert.Equal(t, "gpt-4", list[0].ID)
+
+			ready, err := c.Ready(ctx)
+			require.NoError(t, err)
+			assert.False(t, ready)
+		},
+	)
+
+	t.Run(
+		"StreamsUntilDone", func(t *testing.T) {
+			var chunks []string
+			err := c.Stream(ctx, models.RouteRequest{Prompt: "hi"}, func(content string) { chunks = append(chunks, content) })
+			require.NoError(t, err)
+			assert.Equal(t, []string{"hel", "lo"}, chunks)
+		},
+	)
+
+	t.Run(
+		"SendsTheAdminTokenToTheAdminAPI", func(t *testing.T) {
+			statuses, err := c.Providers(ctx)
+			require.NoError(t, err)
+			require.Len(t, statuses, 1)
+			assert.Equal(t, "primary", statuses[0].Keys[0].Name)
+			assert.Equal(t, "Bearer token", headers.Get("Authorization"))
+		},
+	)
+
+	t.Run(
+		"Errors", func(t *testing.T) {
+			_, err := c.Batch(ctx, "missing")
+			var apiErr *APIError
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+			assert.Equal(t, "NOT_FOUND", apiErr.Code)
+
+			_, err = c.CredentialPolicies(ctx)
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, "Invalid request body", apiErr.Message)
+		},
+	)
+}
+
+func TestReadEvents(t *testing.T) {
+	stream := "event:error\ndata:provider failed\n\n"
+	err := readEvents(
+		strings.NewReader(stream), func(event, data string) (bool, error) {
+			assert.Equal(t, "error", event)
+			return false, fmt.Errorf("%s", data)
+		},
+	)
+	assert.EqualError(t, err, "provider failed")
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := "endpoint: https://router.example.com\napi_key: ${ROUTERCTL_TEST_KEY}\ntimeout: 10s\n"
+	require.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+	t.Setenv("ROUTERCTL_TEST_KEY", "sk-file")
+	t.Setenv(EnvEndpoint, "")
+	t.Setenv(EnvAPIKey, "")
+	t.Setenv(EnvAdminToken, "")
+
+	t.Run(
+		"ConfigFile", func(t *testing.T) {
+			cfg, err := LoadConfig(context.Background(), path)
+			require.NoError(t, err)
+			assert.Equal(t, "https://router.example.com", cfg.Endpoint)
+			assert.Equal(t, "sk-file", cfg.APIKey)
+			assert.Equal(t, 10*time.Second, cfg.Timeout)
+		},
+	)
+
+	t.Run(
+		"EnvironmentTakesPrecedence", func(t *testing.T) {
+			t.Setenv(EnvEndpoint, "http://localhost:9090")
+			t.Setenv(EnvAdminToken, "admin")
+			cfg, err := LoadConfig(context.Background(), path)
+			require.NoError(t, err)
+			assert.Equal(t, "http://localhost:9090", cfg.Endpoint)
+			assert.Equal(t, "sk-file", cfg.APIKey)
+			assert.Equal(t, "admin", cfg.AdminToken)
+		},
+	)
+
+	t.Run(
+		"MissingConfigFile", func(t *testing.T) {
+			_, err := LoadConfig(context.Background(), filepath.Join(t.TempDir(), "missing.yaml"))
+			assert.Error(t, err)
+
+			t.Setenv(EnvConfig, "")
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			t.Setenv("HOME", t.TempDir())
+			cfg, err := LoadConfig(context.Background(), "")
+			require.NoError(t, err)
+			assert.Equal(t, defaultEndpoint, cfg.Endpoint)
+		},
+	)
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"workspace-engine/internal/llm-router/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultEndpoint = "http://localhost:8080"
+	defaultTimeout  = time.Minute
+
+	// The environment variables override the config file
+	EnvConfig     = "ROUTERCTL_CONFIG"
+	EnvEndpoint   = "ROUTER_ENDPOINT"
+	EnvAPIKey     = "ROUTER_API_KEY"
+	EnvAdminToken = "ROUTER_ADMIN_TOKEN"
+)
+
+// Config tells the client where the router runs and how to authenticate with it
+type Config struct {
+	Endpoint string `yaml:"endpoint"`
+	APIKey   string `yaml:"api_key"`
+	// AdminToken is only sent to the admin API
+	AdminToken string `yaml:"admin_token"`
+	// Timeout bounds the calls that do not stream
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// DefaultConfigPath is the config file read when no other is given, routerctl/config.yaml in the
+// user configuration directory
+func DefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "routerctl", "config.yaml")
+}
+
+// LoadConfig reads the config file at the path, or the one named by ROUTERCTL_CONFIG or the default
+// one when the path is empty, and applies the environment variables on top. A missing default
+// config file is not an error. The key and the token may be secret references such as
+// ${NAME} or file:/path, like in the router configuration.
+func LoadConfig(ctx context.Context, path string) (Config, error) {
+	cfg := Config{Endpoint: defaultEndpoint, Timeout: defaultTimeout}
+
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv(EnvConfig)
+		explicit = path != ""
+	}
+	if !explicit {
+		path = DefaultConfigPath()
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist) && !explicit:
+		case err != nil:
+			return Config{}, fmt.Errorf("failed to read config file: %w", err)
+		default:
+			if err := yaml.Unmarshal(data, &cfg); err != nil {
+				return Config{}, fmt.Errorf("invalid config file %s: %w", path, err)
+			}
+		}
+	}
+
+	if endpoint := os.Getenv(EnvEndpoint); endpoint != "" {
+		cfg.Endpoint = endpoint
+	}
+	if apiKey := os.Getenv(EnvAPIKey); apiKey != "" {
+		cfg.APIKey = apiKey
+	}
+	if token := os.Getenv(EnvAdminToken); token != "" {
+		cfg.AdminToken = token
+	}
+
+	resolver := config.NewSecretResolver()
+	apiKey, err := resolver.Resolve(ctx, cfg.APIKey)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid API key: %w", err)
+	}
+	cfg.APIKey = apiKey.Value()
+	token, err := resolver.Resolve(ctx, cfg.AdminToken)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid admin token: %w", err)
+	}
+	cfg.AdminToken = token.Value()
+
+	if cfg.Endpoint == "" {
+		return Config{}, errors.New("router endpoint is not set")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	return cfg, nil
+}
@@ -1,30 +1,217 @@
 package config
 
 import (
+	"context"
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
+	"workspace-engine/pkg/logger"
+
 	"github.com/spf13/viper"
 )
 
 type Config struct {
 	Server    ServerConfig    `mapstructure:"server"`
+	Storage   StorageConfig   `mapstructure:"storage"`
+	Batch     BatchConfig     `mapstructure:"batch"`
+	Tracing   TracingConfig   `mapstructure:"tracing"`
+	Logging   logger.Config   `mapstructure:"logging"`
+	Catalog   CatalogConfig   `mapstructure:"catalog"`
+	Admin     AdminConfig     `mapstructure:"admin"`
+	Tenants   []TenantConfig  `mapstructure:"tenants"`
+	Routing   RoutingConfig   `mapstructure:"routing"`
 	Providers ProvidersConfig `mapstructure:"providers"`
 }
 
 type ServerConfig struct {
-	Port        int           `mapstructure:"port"`
-	Host        string        `mapstructure:"host"`
+	Port int    `mapstructure:"port"`
+	Host string `mapstructure:"host"`
+	// Timeout bounds reading a request
+	Timeout time.Duration `mapstructure:"timeout"`
+	// WriteTimeout bounds handling a request and writing its response. It must exceed the time a
+	// provider call may take with its retries, or responses are cut off while the provider still
+	// generates them. Streamed responses are not bounded by it, they only end early on shutdown.
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	// ReadHeaderTimeout bounds reading the request headers
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+	// IdleTimeout is how long a keep-alive connection stays open without requests
+	IdleTimeout time.Duration `mapstructure:"idle_timeout"`
+	// DrainDelay is how long the router keeps accepting requests after a shutdown signal while it
+	// reports that it is not ready, so load balancers can take it out of rotation
+	DrainDelay time.Duration `mapstructure:"drain_delay"`
+	// ShutdownTimeout is how long requests and streams in flight may take to finish after the
+	// router stopped accepting requests, before their connections are closed
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	TLS             TLSConfig     `mapstructure:"tls"`
+	CORS            CORSConfig    `mapstructure:"cors"`
+	Environment     string        `mapstructure:"environment"`
+	// GRPCPort is the port of the gRPC API, which is disabled when it is zero
+	GRPCPort int `mapstructure:"grpc_port"`
+}
+
+const (
+	ClientAuthRequire       = "require"
+	ClientAuthVerifyIfGiven = "verify_if_given"
+)
+
+// TLSConfig serves the HTTP and gRPC APIs over TLS when a certificate is set. With a client CA the
+// servers also verify client certificates (mTLS).
+type TLSConfig struct {
+	CertFile     string `mapstructure:"cert_file"`
+	KeyFile      string `mapstructure:"key_file"`
+	ClientCAFile string `mapstructure:"client_ca_file"`
+	// ClientAuth is require, the default, or verify_if_given, which also accepts clients without a
+	// certificate
+	ClientAuth string `mapstructure:"client_auth"`
+}
+
+// Enabled tells whether the APIs are served over TLS
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+type StorageConfig struct {
+	// Path is the directory holding the router database
+	Path string `mapstructure:"path"`
+}
+
+type BatchConfig struct {
+	// Concurrency is the maximum number of batch lines processed at the same time across all jobs
+	Concurrency    int           `mapstructure:"concurrency"`
+	WebhookTimeout time.Duration `mapstructure:"webhook_timeout"`
+	// CallbackHosts are the hosts batch callbacks may be sent to, *.example.com matches the
+	// subdomains of example.com. Callbacks are refused when it is empty.
+	CallbackHosts []string `mapstructure:"callback_hosts"`
+}
+
+type TracingConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	ServiceName string `mapstructure:"service_name"`
+	// Exporter is one of stdout, file or otlp
+	Exporter string `mapstructure:"exporter"`
+	// FilePath is the file spans are appended to by the file exporter
+	FilePath string `mapstructure:"file_path"`
+	// Endpoint is the host:port of the OTLP/HTTP collector
+	Endpoint    string  `mapstructure:"endpoint"`
+	Insecure    bool    `mapstructure:"insecure"`
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+}
+
+type CatalogConfig struct {
+	// RefreshInterval is how often the model lists of the providers are fetched again
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+}
+
+// AdminConfig lists the tokens accepted by the admin API, which is disabled when there are none
+type AdminConfig struct {
+	Tokens []AdminToken `mapstructure:"tokens"`
+}
+
+// TenantConfig binds API keys to a tenant. A request is only sent on behalf of a tenant when its
+// API key is bound to it, routing rules match the tenant.
+type TenantConfig struct {
+	Name    string   `mapstructure:"name"`
+	APIKeys []Secret `mapstructure:"api_keys"`
+}
+
+// AdminToken is a bearer token of the admin API. Name identifies the holder in the audit log.
+type AdminToken struct {
+	Name  string `mapstructure:"name"`
+	Token Secret `mapstructure:"token"`
+}
+
+// RoutingConfig holds the routing rules. Rules are evaluated in order and the first matching rule
+// decides the models a request is routed to. Requests matching no rule are routed automatically.
+type RoutingConfig struct {
+	Rules  []RoutingRule `mapstructure:"rules"`
+	Shadow ShadowConfig  `mapstructure:"shadow"`
+	// Coalesce lets concurrent identical requests share one provider call
+	Coalesce bool `mapstructure:"coalesce"`
+	// Upgrades lists the models a request moves to when it does not fit into the context window of
+	// the model it was routed to
+	Upgrades []ModelUpgrade `mapstructure:"upgrades"`
+}
+
+// ModelUpgrade names the models with a larger context window that replace a model, in order of
+// preference. They are usually models of the same family or with the same capabilities.
+type ModelUpgrade struct {
+	Model string   `mapstructure:"model"`
+	To    []string `mapstructure:"to"`
+}
+
+// ShadowConfig limits the shadow requests mirrored by the routing rules and how their results are
+// kept
+type ShadowConfig struct {
+	// Concurrency is the maximum number of shadow requests in flight, further ones are dropped
+	Concurrency int           `mapstructure:"concurrency"`
 	Timeout     time.Duration `mapstructure:"timeout"`
-	CORS        CORSConfig    `mapstructure:"cors"`
-	Environment string        `mapstructure:"environment"`
+	// Retention is how long shadow results are kept, seven days when unset
+	Retention time.Duration `mapstructure:"retention"`
+	// Redact stores shadow results without the prompt and the responses
+	Redact bool `mapstructure:"redact"`
+}
+
+// RoutingRule routes the requests it matches to a single model, a weighted set of models or a
+// fallback chain of models. Exactly one of Model, Weighted and Fallback is set.
+type RoutingRule struct {
+	Name  string    `mapstructure:"name"`
+	Match RuleMatch `mapstructure:"match"`
+	Model string    `mapstructure:"model"`
+	// Weighted spreads the requests across the models by their weights
+	Weighted []WeightedModel `mapstructure:"weighted"`
+	// Fallback lists models in order of preference, a failed request moves on to the next model
+	Fallback []string `mapstructure:"fallback"`
+	// Shadow mirrors a share of the matched requests to a candidate model
+	Shadow ShadowTarget `mapstructure:"shadow"`
+}
+
+// ShadowTarget is a model that receives a copy of a percentage of the requests of a rule. The
+// percentage is assigned to API keys, a caller is always or never mirrored. Its responses are stored for comparison and never returned to the caller.
+type ShadowTarget struct {
+	Model   string  `mapstructure:"model"`
+	Percent float64 `mapstructure:"percent"`
+}
+
+// RuleMatch lists the conditions of a rule. All set conditions must hold for a request to match,
+// a list condition holds when any of its values matches.
+type RuleMatch struct {
+	APIKeys []string `mapstructure:"api_keys"`
+	Tenants []string `mapstructure:"tenants"`
+	// MinPromptLength and MaxPromptLength bound the prompt length in characters, zero is unbounded
+	MinPromptLength int `mapstructure:"min_prompt_length"`
+	MaxPromptLength int `mapstructure:"max_prompt_length"`
+	// Capabilities must all be required by the request
+	Capabilities []string `mapstructure:"capabilities"`
+	// Headers must all be present with the given value, an empty value only requires the header
+	Headers     map[string]string `mapstructure:"headers"`
+	PromptRegex string            `mapstructure:"prompt_regex"`
 }
 
+// WeightedModel is a model of a weighted rule. Callers with an API key keep being routed to the
+// same model as long as the weights do not change.
+type WeightedModel struct {
+	Model  string `mapstructure:"model"`
+	Weight int    `mapstructure:"weight"`
+}
+
+// CORSConfig lets browsers call the API from other origins. An allowed origin is an exact origin
+// such as https://app.example.com, an origin with a wildcard subdomain such as
+// https://*.example.com, or * for any origin.
 type CORSConfig struct {
 	Enabled        bool     `mapstructure:"enabled"`
 	AllowedOrigins []string `mapstructure:"allowed_origins"`
+	// AllowedMethods and AllowedHeaders default to the methods and request headers of the API
 	AllowedMethods []string `mapstructure:"allowed_methods"`
+	AllowedHeaders []string `mapstructure:"allowed_headers"`
+	// ExposedHeaders are the response headers scripts may read besides the CORS-safelisted ones
+	ExposedHeaders []string `mapstructure:"exposed_headers"`
+	// AllowCredentials lets browsers send cookies and HTTP authentication. It cannot be combined
+	// with the * origin.
+	AllowCredentials bool `mapstructure:"allow_credentials"`
+	// MaxAge is how long browsers may cache the result of a preflight request
+	MaxAge time.Duration `mapstructure:"max_age"`
 }
 
 type ProvidersConfig struct {
@@ -35,22 +222,83 @@ type ProvidersConfig struct {
 }
 
 type ProviderConfig struct {
-	Enabled      bool          `mapstructure:"enabled"`
-	APIKey       string        `mapstructure:"api_key"`
-	DefaultModel string        `mapstructure:"default_model"`
-	Models       []ModelConfig `mapstructure:"models"`
+	Enabled bool   `mapstructure:"enabled"`
+	APIKey  Secret `mapstructure:"api_key"`
+	// APIKeys is a pool of keys the calls are spread over, it replaces APIKey
+	APIKeys      []APIKeyConfig    `mapstructure:"api_keys"`
+	KeyRotation  KeyRotationConfig `mapstructure:"key_rotation"`
+	DefaultModel string            `mapstructure:"default_model"`
+	Models       []ModelConfig     `mapstructure:"models"`
+	Retry        RetryConfig       `mapstructure:"retry"`
+}
+
+// Keys returns the API keys of the provider, a single api_key is a pool of one key named "default"
+func (p ProviderConfig) Keys() []APIKeyConfig {
+	if len(p.APIKeys) > 0 {
+		return p.APIKeys
+	}
+	if p.APIKey == "" {
+		return nil
+	}
+	return []APIKeyConfig{{Name: "default", Key: p.APIKey, Weight: 1}}
+}
+
+// APIKeyConfig is a key of the key pool of a provider
+type APIKeyConfig struct {
+	// Name identifies the key in the admin API and in traces, the key itself is never shown
+	Name string `mapstructure:"name"`
+	Key  Secret `mapstructure:"key"`
+	// Weight is the share of the calls the key receives, zero selects the default of 1
+	Weight int `mapstructure:"weight"`
+}
+
+const (
+	KeyRotationRoundRobin       = "round_robin"
+	KeyRotationLeastRateLimited = "least_rate_limited"
+)
+
+// KeyRotationConfig selects how the calls are spread over the keys of a pool. Zero values select
+// the defaults.
+type KeyRotationConfig struct {
+	// Strategy is round_robin, weighted by the key weights, or least_rate_limited, which prefers the
+	// keys that were rate limited longest ago
+	Strategy string `mapstructure:"strategy"`
+	// Cooldown is how long a rate limited key is skipped when the vendor does not send Retry-After
+	Cooldown time.Duration `mapstructure:"cooldown"`
+}
+
+// RetryConfig is the retry policy of the calls to a provider. Zero values select the defaults.
+type RetryConfig struct {
+	// MaxAttempts includes the first attempt, 1 disables retries
+	MaxAttempts    int           `mapstructure:"max_attempts"`
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	// MaxBackoff bounds the delay between attempts. A call whose vendor asks for a longer delay with
+	// Retry-After is not retried.
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+	Multiplier float64       `mapstructure:"multiplier"`
+	// Jitter is the fraction of the backoff that is randomized, between 0 and 1. It is a pointer so
+	// that 0, which disables jitter, is told apart from an unset value.
+	Jitter *float64 `mapstructure:"jitter"`
+	// RetryableStatusCodes are the HTTP status codes of provider errors that are retried
+	RetryableStatusCodes []int `mapstructure:"retryable_status_codes"`
 }
 
 type ModelConfig struct {
-	Name      string        `mapstructure:"name"`
+	Name string `mapstructure:"name"`
+	// MaxTokens is the context window of the model
 	MaxTokens int           `mapstructure:"max_tokens"`
 	Timeout   time.Duration `mapstructure:"timeout"`
+	// Aliases are additional names the model can be requested by
+	Aliases      []string `mapstructure:"aliases"`
+	Capabilities []string `mapstructure:"capabilities"`
+	// InputPrice and OutputPrice are prices per 1K tokens
+	InputPrice  float64 `mapstructure:"input_price"`
+	OutputPrice float64 `mapstructure:"output_price"`
+	Currency    string  `mapstructure:"currency"`
 }
 
 // Load loads the configuration from config files and environment variables
 func Load(configPath string) (*Config, error) {
-	var config Config
-
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
 
@@ -65,6 +313,18 @@ func Load(configPath string) (*Config, error) {
 	viper.AutomaticEnv()
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 
+	return read()
+}
+
+// Reload reads the configuration file found by Load again. The configuration is only returned
+// when it is valid.
+func Reload() (*Config, error) {
+	return read()
+}
+
+func read() (*Config, error) {
+	var config Config
+
 	// Read config file
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
@@ -75,6 +335,11 @@ func Load(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	// Resolve secret references
+	if err := resolveSecrets(context.Background(), &config, secrets); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
 	// Validate config
 	if err := validateConfig(&config); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
@@ -89,6 +354,35 @@ func validateConfig(config *Config) error {
 	if config.Server.Port <= 0 || config.Server.Port > 65535 {
 		return fmt.Errorf("invalid server port: %d", config.Server.Port)
 	}
+	grpcPort := config.Server.GRPCPort
+	if grpcPort < 0 || grpcPort > 65535 || grpcPort == config.Server.Port {
+		return fmt.Errorf("invalid gRPC port: %d", grpcPort)
+	}
+	server := config.Server
+	for _, timeout := range []time.Duration{
+		server.Timeout, server.WriteTimeout, server.ReadHeaderTimeout, server.IdleTimeout, server.DrainDelay,
+		server.ShutdownTimeout,
+	} {
+		if timeout < 0 {
+			return fmt.Errorf("server timeouts must not be negative")
+		}
+	}
+	if err := validateTLSConfig(server.TLS); err != nil {
+		return fmt.Errorf("invalid TLS config: %w", err)
+	}
+	if err := validateCORSConfig(server.CORS); err != nil {
+		return fmt.Errorf("invalid CORS config: %w", err)
+	}
+
+	if err := validateAdminConfig(config.Admin); err != nil {
+		return fmt.Errorf("invalid admin tokens: %w", err)
+	}
+	if err := validateTenants(config.Tenants); err != nil {
+		return fmt.Errorf("invalid tenants: %w", err)
+	}
+	if err := validateBatchConfig(config.Batch); err != nil {
+		return fmt.Errorf("invalid batch config: %w", err)
+	}
 
 	// Validate providers
 	if !config.Providers.OpenAI.Enabled && !config.Providers.Anthropic.Enabled {
@@ -97,7 +391,7 @@ func validateConfig(config *Config) error {
 
 	// Validate OpenAI config
 	if config.Providers.OpenAI.Enabled {
-		if config.Providers.OpenAI.APIKey == "" {
+		if len(config.Providers.OpenAI.Keys()) == 0 {
 			return fmt.Errorf("OpenAI API key is required when OpenAI is enabled")
 		}
 		if len(config.Providers.OpenAI.Models) == 0 {
@@ -107,7 +401,7 @@ func validateConfig(config *Config) error {
 
 	// Validate Anthropic config
 	if config.Providers.Anthropic.Enabled {
-		if config.Providers.Anthropic.APIKey == "" {
+		if len(config.Providers.Anthropic.Keys()) == 0 {
 			return fmt.Errorf("Anthropic API key is required when Anthropic is enabled")
 		}
 		if len(config.Providers.Anthropic.Models) == 0 {
@@ -115,44 +409,285 @@ func validateConfig(config *Config) error {
 		}
 	}
 
+	// Validate key pools and retry policies
+	for _, name := range []string{"openai", "anthropic", "openrouter", "groq"} {
+		provider, _ := config.GetProviderConfig(name)
+		if err := validateKeyPool(*provider); err != nil {
+			return fmt.Errorf("invalid %s API keys: %w", name, err)
+		}
+		if err := validateRetryConfig(provider.Retry); err != nil {
+			return fmt.Errorf("invalid %s retry policy: %w", name, err)
+		}
+	}
+
+	// Validate routing rules
+	if err := validateRoutingConfig(config.Routing); err != nil {
+		return fmt.Errorf("invalid routing rules: %w", err)
+	}
+
 	return nil
 }
 
-// Helper functions to get specific config values
-func (c *Config) GetProviderAPIKey(provider string) string {
-	switch provider {
-	case "openai":
-		return c.Providers.OpenAI.APIKey
-	case "anthropic":
-		return c.Providers.Anthropic.APIKey
+func validateKeyPool(provider ProviderConfig) error {
+	if len(provider.APIKeys) == 0 {
+		return nil
+	}
+	if provider.APIKey != "" {
+		return fmt.Errorf("api_key and api_keys cannot be set together")
+	}
+
+	names := make(map[string]bool, len(provider.APIKeys))
+	for _, key := range provider.APIKeys {
+		if key.Name == "" {
+			return fmt.Errorf("every key needs a name")
+		}
+		if names[key.Name] {
+			return fmt.Errorf("duplicate key name %s", key.Name)
+		}
+		names[key.Name] = true
+		if provider.Enabled && key.Key == "" {
+			return fmt.Errorf("key %s is empty", key.Name)
+		}
+		if key.Weight < 0 {
+			return fmt.Errorf("weight of key %s must not be negative", key.Name)
+		}
+	}
+
+	switch provider.KeyRotation.Strategy {
+	case "", KeyRotationRoundRobin, KeyRotationLeastRateLimited:
 	default:
-		return ""
+		return fmt.Errorf("unknown key rotation strategy: %s", provider.KeyRotation.Strategy)
+	}
+	if provider.KeyRotation.Cooldown < 0 {
+		return fmt.Errorf("key cooldown must not be negative")
 	}
+	return nil
 }
 
-func (c *Config) IsProviderEnabled(provider string) bool {
-	switch provider {
-	case "openai":
-		return c.Providers.OpenAI.Enabled
-	case "anthropic":
-		return c.Providers.Anthropic.Enabled
+func validateTLSConfig(tls TLSConfig) error {
+	if (tls.CertFile == "") != (tls.KeyFile == "") {
+		return fmt.Errorf("cert_file and key_file must be set together")
+	}
+	if tls.ClientCAFile != "" && !tls.Enabled() {
+		return fmt.Errorf("client_ca_file requires cert_file and key_file")
+	}
+	switch tls.ClientAuth {
+	case "", ClientAuthRequire, ClientAuthVerifyIfGiven:
 	default:
-		return false
+		return fmt.Errorf("unknown client_auth: %s", tls.ClientAuth)
 	}
+	return nil
 }
 
-func (c *Config) GetModelConfig(provider, model string) (*ModelConfig, error) {
-	var models []ModelConfig
+var (
+	corsOrigin = regexp.MustCompile(`^https?://(\*\.)?[a-z0-9.-]+(:[0-9]+)?$`)
+	corsMethod = regexp.MustCompile(`^[A-Z]+$`)
+)
+
+func validateCORSConfig(cors CORSConfig) error {
+	if !cors.Enabled {
+		return nil
+	}
+	if len(cors.AllowedOrigins) == 0 {
+		return fmt.Errorf("allowed_origins must not be empty")
+	}
+	for _, origin := range cors.AllowedOrigins {
+		if origin == "*" {
+			if cors.AllowCredentials {
+				return fmt.Errorf("allow_credentials cannot be combined with the * origin")
+			}
+			continue
+		}
+		if !corsOrigin.MatchString(strings.ToLower(origin)) {
+			return fmt.Errorf("invalid origin %s, expected scheme://host[:port]", origin)
+		}
+	}
+	for _, method := range cors.AllowedMethods {
+		if !corsMethod.MatchString(strings.ToUpper(method)) {
+			return fmt.Errorf("invalid method %s", method)
+		}
+	}
+	if cors.MaxAge < 0 {
+		return fmt.Errorf("max_age must not be negative")
+	}
+	return nil
+}
+
+func validateAdminConfig(admin AdminConfig) error {
+	names := make(map[string]bool, len(admin.Tokens))
+	for i, token := range admin.Tokens {
+		if token.Name == "" {
+			return fmt.Errorf("token %d has no name", i)
+		}
+		if names[token.Name] {
+			return fmt.Errorf("duplicate token %s", token.Name)
+		}
+		names[token.Name] = true
+		if token.Token == "" {
+			return fmt.Errorf("token %s is empty", token.Name)
+		}
+	}
+	return nil
+}
+
+func validateTenants(tenants []TenantConfig) error {
+	names := make(map[string]bool, len(tenants))
+	for i, tenant := range tenants {
+		if tenant.Name == "" {
+			return fmt.Errorf("tenant %d has no name", i)
+		}
+		if names[tenant.Name] {
+			return fmt.Errorf("duplicate tenant %s", tenant.Name)
+		}
+		names[tenant.Name] = true
+		for _, key := range tenant.APIKeys {
+			if key == "" {
+				return fmt.Errorf("tenant %s has an empty API key", tenant.Name)
+			}
+		}
+	}
+	return nil
+}
+
+var callbackHost = regexp.MustCompile(`^(\*\.)?[a-z0-9.-]+$`)
+
+func validateBatchConfig(batch BatchConfig) error {
+	if batch.Concurrency < 0 || batch.WebhookTimeout < 0 {
+		return fmt.Errorf("concurrency and webhook_timeout must not be negative")
+	}
+	for _, host := range batch.CallbackHosts {
+		if !callbackHost.MatchString(strings.ToLower(host)) {
+			return fmt.Errorf("invalid callback host %s, expected a host name without scheme or port", host)
+		}
+	}
+	return nil
+}
+
+func validateRoutingConfig(routing RoutingConfig) error {
+	if routing.Shadow.Concurrency < 0 || routing.Shadow.Timeout < 0 || routing.Shadow.Retention < 0 {
+		return fmt.Errorf("shadow concurrency, timeout and retention must not be negative")
+	}
+
+	names := map[string]bool{}
+	for i, rule := range routing.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("rule %d has no name", i)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate rule name: %s", rule.Name)
+		}
+		names[rule.Name] = true
+
+		targets := 0
+		for _, set := range []bool{rule.Model != "", len(rule.Weighted) > 0, len(rule.Fallback) > 0} {
+			if set {
+				targets++
+			}
+		}
+		if targets != 1 {
+			return fmt.Errorf("rule %s must set exactly one of model, weighted and fallback", rule.Name)
+		}
+		for _, weighted := range rule.Weighted {
+			if weighted.Model == "" || weighted.Weight <= 0 {
+				return fmt.Errorf("rule %s needs a model and a positive weight for every weighted entry", rule.Name)
+			}
+		}
+
+		if rule.Shadow.Model != "" && (rule.Shadow.Percent <= 0 || rule.Shadow.Percent > 100) {
+			return fmt.Errorf("rule %s needs a shadow percent between 0 and 100", rule.Name)
+		}
+		if rule.Shadow.Model == "" && rule.Shadow.Percent != 0 {
+			return fmt.Errorf("rule %s has a shadow percent without a shadow model", rule.Name)
+		}
+
+		match := rule.Match
+		if match.MinPromptLength < 0 || match.MaxPromptLength < 0 {
+			return fmt.Errorf("rule %s has a negative prompt length", rule.Name)
+		}
+		if match.MaxPromptLength > 0 && match.MaxPromptLength < match.MinPromptLength {
+			return fmt.Errorf("rule %s has a max_prompt_length below its min_prompt_length", rule.Name)
+		}
+		if match.PromptRegex != "" {
+			if _, err := regexp.Compile(match.PromptRegex); err != nil {
+				return fmt.Errorf("rule %s has an invalid prompt_regex: %w", rule.Name, err)
+			}
+		}
+	}
+
+	upgraded := map[string]bool{}
+	for i, upgrade := range routing.Upgrades {
+		if upgrade.Model == "" || len(upgrade.To) == 0 {
+			return fmt.Errorf("upgrade %d needs a model and the models it upgrades to", i)
+		}
+		if upgraded[upgrade.Model] {
+			return fmt.Errorf("duplicate upgrade of model %s", upgrade.Model)
+		}
+		upgraded[upgrade.Model] = true
+		for _, model := range upgrade.To {
+			if model == "" || model == upgrade.Model {
+				return fmt.Errorf("upgrade of model %s has an invalid target %q", upgrade.Model, model)
+			}
+		}
+	}
+	return nil
+}
+
+func validateRetryConfig(retry RetryConfig) error {
+	if retry.MaxAttempts < 0 {
+		return fmt.Errorf("max_attempts must not be negative")
+	}
+	if retry.Jitter != nil && (*retry.Jitter < 0 || *retry.Jitter > 1) {
+		return fmt.Errorf("jitter must be between 0 and 1")
+	}
+	if retry.Multiplier != 0 && retry.Multiplier < 1 {
+		return fmt.Errorf("multiplier must be at least 1")
+	}
+	if retry.MaxBackoff > 0 && retry.MaxBackoff < retry.InitialBackoff {
+		return fmt.Errorf("max_backoff must not be less than initial_backoff")
+	}
+	return nil
+}
+
+// Helper functions to get specific config values
+func (c *Config) GetProviderConfig(provider string) (*ProviderConfig, error) {
 	switch provider {
 	case "openai":
-		models = c.Providers.OpenAI.Models
+		return &c.Providers.OpenAI, nil
 	case "anthropic":
-		models = c.Providers.Anthropic.Models
+		return &c.Providers.Anthropic, nil
+	case "openrouter":
+		return &c.Providers.OpenRouter, nil
+	case "groq":
+		return &c.Providers.Groq, nil
 	default:
 		return nil, fmt.Errorf("unknown provider: %s", provider)
 	}
+}
+
+// GetProviderAPIKey returns the first API key of a provider
+func (c *Config) GetProviderAPIKey(provider string) string {
+	if p, err := c.GetProviderConfig(provider); err == nil {
+		if keys := p.Keys(); len(keys) > 0 {
+			return keys[0].Key.Value()
+		}
+	}
+	return ""
+}
+
+func (c *Config) IsProviderEnabled(provider string) bool {
+	if p, err := c.GetProviderConfig(provider); err == nil {
+		return p.Enabled
+	}
+	return false
+}
+
+func (c *Config) GetModelConfig(provider, model string) (*ModelConfig, error) {
+	p, err := c.GetProviderConfig(provider)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, m := range models {
+	for _, m := range p.Models {
 		if m.Name == model {
 			return &m, nil
 		}
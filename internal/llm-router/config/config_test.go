@@ -21,7 +21,7 @@ func TestLoadConfig(t *testing.T) {
 
 	// Test OpenAI provider config
 	assert.True(t, cfg.Providers.OpenAI.Enabled)
-	assert.Equal(t, "test-key", cfg.Providers.OpenAI.APIKey)
+	assert.Equal(t, "test-key", cfg.Providers.OpenAI.APIKey.Value())
 	assert.Equal(t, "gpt-3.5-turbo", cfg.Providers.OpenAI.DefaultModel)
 
 	// Test model config
@@ -32,6 +32,7 @@ func TestLoadConfig(t *testing.T) {
 }
 
 func TestValidateConfig(t *testing.T) {
+	invalidJitter := 1.5
 	tests := []struct {
 		name        string
 		config      *Config
@@ -62,6 +63,159 @@ func TestValidateConfig(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			name: "gRPC port same as HTTP port",
+			config: &Config{
+				Server: ServerConfig{
+					Port:     8080,
+					GRPCPort: 8080,
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "TLS key without certificate",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 8080,
+					TLS:  TLSConfig{KeyFile: "server.key"},
+				},
+				Providers: ProvidersConfig{
+					OpenAI: ProviderConfig{Enabled: true, APIKey: "test-key", Models: []ModelConfig{{Name: "gpt-4"}}},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "unknown client auth",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 8080,
+					TLS: TLSConfig{
+						CertFile: "server.crt", KeyFile: "server.key", ClientCAFile: "ca.crt", ClientAuth: "optional",
+					},
+				},
+				Providers: ProvidersConfig{
+					OpenAI: ProviderConfig{Enabled: true, APIKey: "test-key", Models: []ModelConfig{{Name: "gpt-4"}}},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "CORS credentials with any origin",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 8080,
+					CORS: CORSConfig{Enabled: true, AllowedOrigins: []string{"*"}, AllowCredentials: true},
+				},
+				Providers: ProvidersConfig{
+					OpenAI: ProviderConfig{Enabled: true, APIKey: "test-key", Models: []ModelConfig{{Name: "gpt-4"}}},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "CORS origin with a path",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 8080,
+					CORS: CORSConfig{Enabled: true, AllowedOrigins: []string{"https://app.example.com/console"}},
+				},
+				Providers: ProvidersConfig{
+					OpenAI: ProviderConfig{Enabled: true, APIKey: "test-key", Models: []ModelConfig{{Name: "gpt-4"}}},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "CORS with wildcard subdomains",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 8080,
+					CORS: CORSConfig{
+						Enabled:          true,
+						AllowedOrigins:   []string{"https://*.example.com", "http://localhost:3000"},
+						AllowCredentials: true,
+					},
+				},
+				Providers: ProvidersConfig{
+					OpenAI: ProviderConfig{Enabled: true, APIKey: "test-key", Models: []ModelConfig{{Name: "gpt-4"}}},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "API key pool",
+			config: &Config{
+				Server: ServerConfig{Port: 8080},
+				Providers: ProvidersConfig{
+					OpenAI: ProviderConfig{
+						Enabled:     true,
+						APIKeys:     []APIKeyConfig{{Name: "a", Key: "sk-a", Weight: 2}, {Name: "b", Key: "sk-b"}},
+						KeyRotation: KeyRotationConfig{Strategy: KeyRotationLeastRateLimited},
+						Models:      []ModelConfig{{Name: "gpt-4"}},
+					},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "API key pool with duplicate names",
+			config: &Config{
+				Server: ServerConfig{Port: 8080},
+				Providers: ProvidersConfig{
+					OpenAI: ProviderConfig{
+						Enabled: true,
+						APIKeys: []APIKeyConfig{{Name: "a", Key: "sk-a"}, {Name: "a", Key: "sk-b"}},
+						Models:  []ModelConfig{{Name: "gpt-4"}},
+					},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "API key and API key pool",
+			config: &Config{
+				Server: ServerConfig{Port: 8080},
+				Providers: ProvidersConfig{
+					OpenAI: ProviderConfig{
+						Enabled: true,
+						APIKey:  "test-key",
+						APIKeys: []APIKeyConfig{{Name: "a", Key: "sk-a"}},
+						Models:  []ModelConfig{{Name: "gpt-4"}},
+					},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "unknown key rotation strategy",
+			config: &Config{
+				Server: ServerConfig{Port: 8080},
+				Providers: ProvidersConfig{
+					OpenAI: ProviderConfig{
+						Enabled:     true,
+						APIKeys:     []APIKeyConfig{{Name: "a", Key: "sk-a"}},
+						KeyRotation: KeyRotationConfig{Strategy: "random"},
+						Models:      []ModelConfig{{Name: "gpt-4"}},
+					},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "negative shutdown timeout",
+			config: &Config{
+				Server: ServerConfig{
+					Port:            8080,
+					ShutdownTimeout: -time.Second,
+				},
+				Providers: ProvidersConfig{
+					OpenAI: ProviderConfig{Enabled: true, APIKey: "test-key", Models: []ModelConfig{{Name: "gpt-4"}}},
+				},
+			},
+			expectError: true,
+		},
 		{
 			name: "no providers enabled",
 			config: &Config{
@@ -79,6 +233,95 @@ func TestValidateConfig(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			name: "admin token without a value",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 8080,
+				},
+				Providers: ProvidersConfig{
+					OpenAI: ProviderConfig{
+						Enabled: true,
+						APIKey:  "test-key",
+						Models:  []ModelConfig{{Name: "gpt-4"}},
+					},
+				},
+				Admin: AdminConfig{Tokens: []AdminToken{{Name: "ops"}}},
+			},
+			expectError: true,
+		},
+		{
+			name: "invalid retry jitter",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 8080,
+				},
+				Providers: ProvidersConfig{
+					OpenAI: ProviderConfig{
+						Enabled: true,
+						APIKey:  "test-key",
+						Models:  []ModelConfig{{Name: "gpt-4"}},
+						Retry:   RetryConfig{Jitter: &invalidJitter},
+					},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "callback host with scheme",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 8080,
+				},
+				Providers: ProvidersConfig{
+					OpenAI: ProviderConfig{
+						Enabled: true,
+						APIKey:  "test-key",
+						Models:  []ModelConfig{{Name: "gpt-4"}},
+					},
+				},
+				Batch: BatchConfig{CallbackHosts: []string{"https://hooks.example.com"}},
+			},
+			expectError: true,
+		},
+		{
+			name: "routing rule with two targets",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 8080,
+				},
+				Providers: ProvidersConfig{
+					OpenAI: ProviderConfig{
+						Enabled: true,
+						APIKey:  "test-key",
+						Models:  []ModelConfig{{Name: "gpt-4"}},
+					},
+				},
+				Routing: RoutingConfig{
+					Rules: []RoutingRule{{Name: "long", Model: "gpt-4", Fallback: []string{"gpt-4"}}},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "upgrade to the same model",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 8080,
+				},
+				Providers: ProvidersConfig{
+					OpenAI: ProviderConfig{
+						Enabled: true,
+						APIKey:  "test-key",
+						Models:  []ModelConfig{{Name: "gpt-4"}},
+					},
+				},
+				Routing: RoutingConfig{
+					Upgrades: []ModelUpgrade{{Model: "gpt-4", To: []string{"gpt-4"}}},
+				},
+			},
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const redacted = "[REDACTED]"
+
+// Secret is a configuration value that must not be disclosed. It prints, logs and marshals as
+// [REDACTED], Value returns the secret itself.
+type Secret string
+
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return fmt.Sprintf("%q", s.String())
+}
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s Secret) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
+
+// SecretBackend resolves the secret references of a scheme, e.g. "vault:secret/router#openai" is
+// passed to the backend of the vault scheme as "secret/router#openai"
+type SecretBackend interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// SecretBackendFunc adapts a function to a SecretBackend
+type SecretBackendFunc func(ctx context.Context, ref string) (string, error)
+
+func (f SecretBackendFunc) Resolve(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+var (
+	envReference    = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	schemeReference = regexp.MustCompile(`^([a-z][a-z0-9+.-]*):(.+)$`)
+)
+
+// SecretResolver expands the secret references of configuration values. ${NAME} is replaced by
+// the environment variable NAME anywhere in a value, then a value of the form scheme:ref is
+// resolved by the backend registered for the scheme. file:path reads the secret from a file.
+// Values without a registered scheme are used as they are.
+type SecretResolver struct {
+	mu        sync.RWMutex
+	backends  map[string]SecretBackend
+	lookupEnv func(string) (string, bool)
+}
+
+func NewSecretResolver() *SecretResolver {
+	r := &SecretResolver{
+		backends:  map[string]SecretBackend{},
+		lookupEnv: os.LookupEnv,
+	}
+	r.Register("file", SecretBackendFunc(readSecretFile))
+	return r
+}
+
+// Register sets the backend of a scheme, replacing the previous one
+func (r *SecretResolver) Register(scheme string, backend SecretBackend) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.backends[scheme] = backend
+}
+
+// Resolve returns the secret a value refers to. Errors name the reference but never the secret.
+func (r *SecretResolver) Resolve(ctx context.Context, value string) (Secret, error) {
+	var missing []string
+	expanded := envReference.ReplaceAllStringFunc(
+		value, func(reference string) string {
+			name := envReference.FindStringSubmatch(reference)[1]
+			env, ok := r.lookupEnv(name)
+			if !ok {
+				missing = append(missing, name)
+			}
+			return env
+		},
+	)
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+
+	if match := schemeReference.FindStringSubmatch(expanded); match != nil {
+		r.mu.RLock()
+		backend, ok := r.backends[match[1]]
+		r.mu.RUnlock()
+		if ok {
+			secret, err := backend.Resolve(ctx, match[2])
+			if err != nil {
+				return "", fmt.Errorf("failed to resolve %s secret: %w", match[1], err)
+			}
+			expanded = secret
+		}
+	}
+
+	if expanded == "" && value != "" {
+		return "", fmt.Errorf("%s resolved to an empty value", value)
+	}
+	return Secret(expanded), nil
+}
+
+// readSecretFile reads a secret file, without the trailing newline most editors and secret mounts
+// add
+func readSecretFile(_ context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// secrets resolves the secret references of configuration files
+var secrets = NewSecretResolver()
+
+// RegisterSecretBackend makes a secret backend available to the configuration files under a scheme.
+// It must be called before the configuration is loaded.
+func RegisterSecretBackend(scheme string, backend SecretBackend) {
+	secrets.Register(scheme, backend)
+}
+
+// resolveSecrets replaces the secret references of the configuration by the secrets. The API keys
+// of disabled providers are left as they are, so their variables do not need to be set, an enabled
+// provider needs a key that is not empty.
+func resolveSecrets(ctx context.Context, config *Config, resolver *SecretResolver) error {
+	for _, name := range []string{"openai", "anthropic", "openrouter", "groq"} {
+		provider, _ := config.GetProviderConfig(name)
+		if !provider.Enabled {
+			continue
+		}
+		if len(provider.APIKeys) == 0 && provider.APIKey == "" {
+			return fmt.Errorf("invalid %s API key: the provider is enabled but its key is empty", name)
+		}
+		secret, err := resolver.Resolve(ctx, provider.APIKey.Value())
+		if err != nil {
+			return fmt.Errorf("invalid %s API key: %w", name, err)
+		}
+		provider.APIKey = secret
+
+		for i := range provider.APIKeys {
+			key := &provider.APIKeys[i]
+			secret, err := resolver.Resolve(ctx, key.Key.Value())
+			if err != nil {
+				return fmt.Errorf("invalid %s API key %s: %w", name, key.Name, err)
+			}
+			key.Key = secret
+		}
+	}
+
+	for i := range config.Admin.Tokens {
+		token := &config.Admin.Tokens[i]
+		secret, err := resolver.Resolve(ctx, token.Token.Value())
+		if err != nil {
+			return fmt.Errorf("invalid admin token %s: %w", token.Name, err)
+		}
+		token.Token = secret
+	}
+
+	for i := range config.Tenants {
+		tenant := &config.Tenants[i]
+		for j := range tenant.APIKeys {
+			secret, err := resolver.Resolve(ctx, tenant.APIKeys[j].Value())
+			if err != nil {
+				return fmt.Errorf("invalid API key of tenant %s: %w", tenant.Name, err)
+			}
+			tenant.APIKeys[j] = secret
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretResolver(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "openai"), []byte("sk-file\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "empty"), nil, 0o600))
+	t.Setenv("ROUTER_TEST_KEY", "sk-env")
+	t.Setenv("ROUTER_TEST_DIR", dir)
+	t.Setenv("ROUTER_TEST_EMPTY", "")
+
+	resolver := NewSecretResolver()
+	resolver.Register(
+		"vault", SecretBackendFunc(
+			func(_ context.Context, ref string) (string, error) {
+				if ref == "router#openai" {
+					return "sk-vault", nil
+				}
+				return "", errors.New("not found")
+			},
+		),
+	)
+
+	for _, test := range []struct {
+		value  string
+		secret string
+	}{
+		{"sk-literal", "sk-literal"},
+		{"${ROUTER_TEST_KEY}", "sk-env"},
+		{"prefix-${ROUTER_TEST_KEY}", "prefix-sk-env"},
+		{"file:" + filepath.Join(dir, "openai"), "sk-file"},
+		{"file:${ROUTER_TEST_DIR}/openai", "sk-file"},
+		{"vault:router#openai", "sk-vault"},
+		{"unknown:value", "unknown:value"},
+		{"", ""},
+	} {
+		secret, err := resolver.Resolve(ctx, test.value)
+		require.NoError(t, err, test.value)
+		assert.Equal(t, test.secret, secret.Value(), test.value)
+	}
+
+	for _, value := range []string{
+		"${ROUTER_TEST_MISSING}",
+		"${ROUTER_TEST_EMPTY}",
+		"file:" + filepath.Join(dir, "missing"),
+		"file:" + filepath.Join(dir, "empty"),
+		"vault:router#anthropic",
+	} {
+		_, err := resolver.Resolve(ctx, value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	t.Setenv("ROUTER_TEST_KEY", "sk-env")
+	t.Setenv("ROUTER_TEST_TOKEN", "admin-token")
+
+	config := &Config{
+		Admin: AdminConfig{Tokens: []AdminToken{{Name: "ops", Token: "${ROUTER_TEST_TOKEN}"}}},
+		Providers: ProvidersConfig{
+			OpenAI: ProviderConfig{Enabled: true, APIKey: "${ROUTER_TEST_KEY}"},
+			Anthropic: ProviderConfig{
+				Enabled: true,
+				APIKeys: []APIKeyConfig{{Name: "primary", Key: "${ROUTER_TEST_KEY}"}, {Name: "backup", Key: "sk-literal"}},
+			},
+			Groq: ProviderConfig{APIKey: "${ROUTER_TEST_MISSING}"},
+		},
+	}
+	require.NoError(t, resolveSecrets(context.Background(), config, NewSecretResolver()))
+	assert.Equal(t, "sk-env", config.Providers.OpenAI.APIKey.Value())
+	assert.Equal(t, "sk-env", config.Providers.Anthropic.APIKeys[0].Key.Value())
+	assert.Equal(t, "sk-literal", config.Providers.Anthropic.APIKeys[1].Key.Value())
+	assert.Equal(t, "admin-token", config.Admin.Tokens[0].Token.Value())
+	assert.Equal(t, "${ROUTER_TEST_MISSING}", config.Providers.Groq.APIKey.Value())
+
+	t.Run(
+		"missing secret of an enabled provider", func(t *testing.T) {
+			config.Providers.Groq.Enabled = true
+			err := resolveSecrets(context.Background(), config, NewSecretResolver())
+			assert.ErrorContains(t, err, "groq")
+		},
+	)
+
+	t.Run(
+		"empty secret of an enabled provider", func(t *testing.T) {
+			t.Setenv("ROUTER_TEST_EMPTY", "")
+			for _, key := range []Secret{"", "${ROUTER_TEST_EMPTY}"} {
+				config := &Config{
+					Providers: ProvidersConfig{OpenRouter: ProviderConfig{Enabled: true, APIKey: key}},
+				}
+				err := resolveSecrets(context.Background(), config, NewSecretResolver())
+				assert.ErrorContains(t, err, "openrouter", string(key))
+			}
+		},
+	)
+}
+
+func TestSecretRedaction(t *testing.T) {
+	secret := Secret("sk-secret")
+	provider := ProviderConfig{Enabled: true, APIKey: secret, APIKeys: []APIKeyConfig{{Name: "pool", Key: secret}}}
+
+	assert.NotContains(t, fmt.Sprintf("%v %+v %#v %s", provider, provider, provider, secret), "sk-secret")
+
+	data, err := json.Marshal(provider)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "sk-secret")
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("config", "key", secret, "provider", provider)
+	assert.NotContains(t, buf.String(), "sk-secret")
+	assert.Contains(t, buf.String(), redacted)
+
+	assert.Equal(t, "", Secret("").String())
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"workspace-engine/pkg/logger"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// watchDebounce is how long a changed configuration file must stay untouched before it is read.
+// Editors and secret mounts often write a file in several steps.
+const watchDebounce = 500 * time.Millisecond
+
+// Watch calls changed whenever the configuration file found by Load changes, until the context is
+// done. The new configuration is read with Reload.
+func Watch(ctx context.Context, changed func()) error {
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		return errors.New("no configuration file was loaded")
+	}
+	return watchFile(ctx, path, changed)
+}
+
+// watchFile calls changed after the file was written, created or replaced. The directory of the
+// file is watched, so replacing the file by a rename or by swapping a symlink, as Kubernetes does
+// with mounted config maps, is noticed as well.
+func watchFile(ctx context.Context, path string, changed func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch configuration: %w", err)
+	}
+
+	path = filepath.Clean(path)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch configuration: %w", err)
+	}
+	target, _ := filepath.EvalSymlinks(path)
+
+	go func() {
+		defer watcher.Close()
+
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				current, _ := filepath.EvalSymlinks(path)
+				if filepath.Clean(event.Name) != path && current == target {
+					continue
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 && current == target {
+					continue
+				}
+				target = current
+				debounce = time.After(watchDebounce)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warn("Configuration watcher failed", "path", path, "error", err)
+			case <-debounce:
+				debounce = nil
+				changed()
+			}
+		}
+	}()
+	return nil
+}
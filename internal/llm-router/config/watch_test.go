@@ -0,0 +1,58 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("server: {port: 8080}\n"), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan struct{}, 10)
+	require.NoError(t, watchFile(ctx, path, func() { changes <- struct{}{} }))
+
+	t.Run(
+		"WritesAreDebounced", func(t *testing.T) {
+			for _, port := range []string{"8081", "8082", "8083"} {
+				require.NoError(t, os.WriteFile(path, []byte("server: {port: "+port+"}\n"), 0o600))
+			}
+			select {
+			case <-changes:
+			case <-time.After(5 * time.Second):
+				t.Fatal("change was not noticed")
+			}
+			assert.Never(t, func() bool { return len(changes) > 0 }, 2*watchDebounce, 50*time.Millisecond)
+		},
+	)
+
+	t.Run(
+		"ReplacedFile", func(t *testing.T) {
+			replacement := filepath.Join(dir, "config.yaml.new")
+			require.NoError(t, os.WriteFile(replacement, []byte("server: {port: 9090}\n"), 0o600))
+			require.NoError(t, os.Rename(replacement, path))
+			select {
+			case <-changes:
+			case <-time.After(5 * time.Second):
+				t.Fatal("replacement was not noticed")
+			}
+		},
+	)
+
+	t.Run(
+		"OtherFilesAreIgnored", func(t *testing.T) {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "other.yaml"), []byte("x: 1\n"), 0o600))
+			assert.Never(t, func() bool { return len(changes) > 0 }, 2*watchDebounce, 50*time.Millisecond)
+		},
+	)
+}
@@ -0,0 +1,134 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// AssertionResult is the outcome of an assertion. Skipped assertions do not count towards the
+// score, e.g. judge assertions of a run without a judge model.
+type AssertionResult struct {
+	Type    string `json:"type"`
+	Passed  bool   `json:"passed"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// check runs an assertion that needs no model on the output of a case
+func check(assertion Assertion, output string) AssertionResult {
+	result := AssertionResult{Type: assertion.Type}
+	switch assertion.Type {
+	case AssertionEquals:
+		result.Passed = strings.TrimSpace(output) == strings.TrimSpace(assertion.Value)
+		if !result.Passed {
+			result.Message = fmt.Sprintf("expected %q", assertion.Value)
+		}
+	case AssertionContains:
+		result.Passed = strings.Contains(output, assertion.Value)
+		if !result.Passed {
+			result.Message = fmt.Sprintf("output does not contain %q", assertion.Value)
+		}
+	case AssertionRegex:
+		regex, err := compileRegex(assertion.Value)
+		if err != nil {
+			result.Message = err.Error()
+			break
+		}
+		result.Passed = regex.MatchString(output)
+		if !result.Passed {
+			result.Message = fmt.Sprintf("output does not match %s", assertion.Value)
+		}
+	case AssertionJSONSchema:
+		if err := validateJSON(assertion.Schema, output); err != nil {
+			result.Message = err.Error()
+			break
+		}
+		result.Passed = true
+	default:
+		result.Message = fmt.Sprintf("unknown assertion type %q", assertion.Type)
+	}
+	return result
+}
+
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, errors.New("regex needs a pattern")
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %w", err)
+	}
+	return regex, nil
+}
+
+func compileSchema(schema map[string]interface{}) (*jsonschema.Schema, error) {
+	if len(schema) == 0 {
+		return nil, errors.New("json_schema needs a schema")
+	}
+
+	// Decode the schema again so its numbers have the types the compiler expects
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("assertion.json", doc); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	compiled, err := compiler.Compile("assertion.json")
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return compiled, nil
+}
+
+// validateJSON parses the output as JSON, tolerating a markdown code fence around it, and
+// validates it against the schema
+func validateJSON(schema map[string]interface{}, output string) error {
+	compiled, err := compileSchema(schema)
+	if err != nil {
+		return err
+	}
+
+	text := strings.TrimSpace(output)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	}
+	value, err := jsonschema.UnmarshalJSON(strings.NewReader(text))
+	if err != nil {
+		return fmt.Errorf("output is not JSON: %w", err)
+	}
+	if err := compiled.Validate(value); err != nil {
+		return fmt.Errorf("output does not match the schema: %w", err)
+	}
+	return nil
+}
+
+// judgeSchema is the response format of the judge model
+var judgeSchema = json.RawMessage(`{
+	"type": "object",
+	"required": ["pass", "reason"],
+	"properties": {"pass": {"type": "boolean"}, "reason": {"type": "string"}}
+}`)
+
+// judgePrompt asks the judge model whether an output meets the criteria of an assertion
+func judgePrompt(c Case, criteria, output string) string {
+	return fmt.Sprintf(
+		"You grade the output of a language model. Decide whether the output meets the criteria "+
+			"and explain your decision in one sentence.\n\nPrompt:\n%s\n\nCriteria:\n%s\n\nOutput:\n%s",
+		c.Prompt, criteria, output,
+	)
+}
@@ -0,0 +1,249 @@
+package eval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service"
+	"workspace-engine/internal/llm-router/service/llm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSuite = `
+name: capitals
+cases:
+  - name: france
+    prompt: What is the capital of France?
+    expected: Paris
+    parameters:
+      temperature: 0
+  - name: person
+    prompt: Describe a person as JSON
+    mock: '{"name": "Ada", "age": 36}'
+    assertions:
+      - type: json_schema
+        schema:
+          type: object
+          required: [name, age]
+          properties:
+            name: {type: string}
+            age: {type: integer}
+      - type: judge
+        criteria: The person is a real historical figure
+  - name: greeting
+    prompt: Say hello
+    mock: Hi there
+    assertions:
+      - type: regex
+        value: "(?i)^hello"
+      - type: contains
+        value: there
+`
+
+func writeSuite(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadSuite(t *testing.T) {
+	t.Run(
+		"YAML", func(t *testing.T) {
+			suite, err := LoadSuite(writeSuite(t, "suite.yml", testSuite))
+			require.NoError(t, err)
+
+			assert.Equal(t, "capitals", suite.Name)
+			require.Len(t, suite.Cases, 3)
+			assert.Equal(t, map[string]interface{}{"temperature": 0.0}, suite.Cases[0].Parameters)
+			assert.Len(t, suite.Cases[1].Assertions, 2)
+		},
+	)
+
+	t.Run(
+		"JSONL", func(t *testing.T) {
+			suite, err := LoadSuite(
+				writeSuite(
+					t, "smoke.jsonl",
+					`{"prompt": "Say hello", "expected": "Hello"}`+"\n\n"+
+						`{"prompt": "Count to three", "assertions": [{"type": "contains", "value": "3"}]}`+"\n",
+				),
+			)
+			require.NoError(t, err)
+
+			assert.Equal(t, "smoke", suite.Name)
+			require.Len(t, suite.Cases, 2)
+			assert.Equal(t, "case-1", suite.Cases[0].Name)
+			assert.Equal(t, "case-2", suite.Cases[1].Name)
+		},
+	)
+
+	t.Run(
+		"invalid suites", func(t *testing.T) {
+			for name, content := range map[string]string{
+				"no cases":       "name: empty\n",
+				"no prompt":      "cases:\n  - expected: Paris\n",
+				"no checks":      "cases:\n  - prompt: Hello\n",
+				"duplicate case": "cases:\n  - {name: a, prompt: x, expected: y}\n  - {name: a, prompt: x, expected: y}\n",
+				"unknown type":   "cases:\n  - prompt: x\n    assertions: [{type: fuzzy}]\n",
+				"invalid regex":  "cases:\n  - prompt: x\n    assertions: [{type: regex, value: '('}]\n",
+				"no criteria":    "cases:\n  - prompt: x\n    assertions: [{type: judge}]\n",
+			} {
+				_, err := LoadSuite(writeSuite(t, "suite.yml", content))
+				assert.Error(t, err, name)
+			}
+		},
+	)
+}
+
+func TestCheck(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"name"},
+	}
+
+	for _, test := range []struct {
+		name      string
+		assertion Assertion
+		output    string
+		passed    bool
+	}{
+		{"equals", Assertion{Type: AssertionEquals, Value: "Paris"}, " Paris\n", true},
+		{"not equals", Assertion{Type: AssertionEquals, Value: "Paris"}, "Lyon", false},
+		{"contains", Assertion{Type: AssertionContains, Value: "Paris"}, "It is Paris.", true},
+		{"regex", Assertion{Type: AssertionRegex, Value: `^\d+$`}, "42", true},
+		{"regex mismatch", Assertion{Type: AssertionRegex, Value: `^\d+$`}, "forty-two", false},
+		{"schema", Assertion{Type: AssertionJSONSchema, Schema: schema}, "```json\n{\"name\": \"Ada\"}\n```", true},
+		{"schema mismatch", Assertion{Type: AssertionJSONSchema, Schema: schema}, `{"age": 36}`, false},
+		{"not JSON", Assertion{Type: AssertionJSONSchema, Schema: schema}, "Ada", false},
+	} {
+		result := check(test.assertion, test.output)
+		assert.Equal(t, test.passed, result.Passed, test.name)
+		if !test.passed {
+			assert.NotEmpty(t, result.Message, test.name)
+		}
+	}
+}
+
+// renamedProvider answers like the mock provider but names another model in its responses
+type renamedProvider struct {
+	*MockProvider
+	answeredBy string
+}
+
+func (p *renamedProvider) Generate(
+	ctx context.Context, prompt string, params map[string]interface{},
+) (*models.RouteResponse, error) {
+	resp, err := p.MockProvider.Generate(ctx, prompt, params)
+	if err == nil {
+		resp.Model = p.answeredBy
+	}
+	return resp, err
+}
+
+func TestAnsweredBy(t *testing.T) {
+	target := models.ModelInfo{ID: "gpt-4", Aliases: []string{"gpt4"}}
+	for model, want := range map[string]bool{
+		"gpt-4":            true,
+		"gpt4":             true,
+		"gpt-4-0613":       true,
+		"gpt-4-2024-04-09": true,
+		"gpt-4o":           false,
+		"gpt-4-turbo":      false,
+		"gpt-4-32k":        false,
+		"claude-2":         false,
+	} {
+		assert.Equal(t, want, answeredBy(model, target), model)
+	}
+}
+
+func TestRunner(t *testing.T) {
+	suite, err := LoadSuite(writeSuite(t, "suite.yml", testSuite))
+	require.NoError(t, err)
+
+	modelNames := []string{"model-a", "model-b"}
+	report, err := NewRunner(NewMockRouter(suite, modelNames), "").Run(context.Background(), suite, modelNames)
+	require.NoError(t, err)
+
+	assert.Equal(t, "capitals", report.Suite)
+	require.Len(t, report.Models, 2)
+	for i, model := range report.Models {
+		assert.Equal(t, modelNames[i], model.Model)
+		require.Len(t, model.Cases, 3)
+
+		france := model.Cases[0]
+		assert.True(t, france.Passed)
+		assert.Equal(t, "Paris", france.Output)
+		assert.Equal(t, modelNames[i], france.Model)
+
+		// The judge assertion is skipped without a judge model
+		person := model.Cases[1]
+		assert.True(t, person.Passed)
+		require.Len(t, person.Assertions, 2)
+		assert.True(t, person.Assertions[1].Skipped)
+
+		greeting := model.Cases[2]
+		assert.False(t, greeting.Passed)
+		assert.Equal(t, 0.5, greeting.Score)
+
+		assert.Equal(t, 2, model.Passed)
+		assert.Equal(t, 1, model.Failed)
+		assert.InDelta(t, 2.5/3, model.Score, 0.001)
+	}
+
+	t.Run(
+		"unknown model", func(t *testing.T) {
+			_, err := NewRunner(NewMockRouter(suite, modelNames), "").Run(context.Background(), suite, []string{"other"})
+			assert.Error(t, err)
+		},
+	)
+
+	t.Run(
+		"disabled model", func(t *testing.T) {
+			router := NewMockRouter(suite, modelNames)
+			require.NoError(t, router.Providers().SetStatus("mock_model-a", models.ProviderDisabled))
+			_, err := NewRunner(router, "").Run(context.Background(), suite, []string{"model-a"})
+			assert.Error(t, err)
+		},
+	)
+
+	t.Run(
+		"answered by another model", func(t *testing.T) {
+			providers := map[string]llm.Provider{
+				"mock_model-a": &renamedProvider{NewMockProvider(suite, "model-a"), "model-b"},
+			}
+			catalog := service.NewCatalogService(providers, nil, &config.Config{})
+			router := service.NewRouterService(providers, catalog, nil, nil)
+			report, err := NewRunner(router, "").Run(context.Background(), suite, []string{"model-a"})
+			require.NoError(t, err)
+
+			for _, result := range report.Models[0].Cases {
+				assert.Equal(t, "answered by model-b instead of model-a", result.Error)
+				assert.Empty(t, result.Assertions)
+			}
+			assert.Equal(t, 3, report.Models[0].Errors)
+		},
+	)
+
+	t.Run(
+		"reports", func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, report.WriteJSON(&buf))
+			var decoded Report
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+			assert.Equal(t, report.Models[0].Score, decoded.Models[0].Score)
+
+			buf.Reset()
+			require.NoError(t, report.WriteHTML(&buf))
+			assert.Contains(t, buf.String(), "Evaluation of capitals")
+			assert.Contains(t, buf.String(), "83.3%")
+		},
+	)
+}
@@ -0,0 +1,84 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service"
+	"workspace-engine/internal/llm-router/service/llm"
+
+	"github.com/google/uuid"
+)
+
+// MockProvider answers the prompts of a suite with the mock output of their case, or the expected
+// output without one, so suites and assertions can be checked without calling a vendor
+type MockProvider struct {
+	model   string
+	outputs map[string]string
+}
+
+func NewMockProvider(suite *Suite, model string) *MockProvider {
+	outputs := make(map[string]string, len(suite.Cases))
+	for _, c := range suite.Cases {
+		output := c.Mock
+		if output == "" {
+			output = c.Expected
+		}
+		outputs[c.Prompt] = output
+	}
+	return &MockProvider{
+		model:   model,
+		outputs: outputs,
+	}
+}
+
+func (p *MockProvider) Generate(
+	_ context.Context, prompt string, _ map[string]interface{},
+) (*models.RouteResponse, error) {
+	output, ok := p.outputs[prompt]
+	if !ok {
+		return nil, fmt.Errorf("no mock output for prompt")
+	}
+	promptTokens, completionTokens := len(prompt)/4, len(output)/4
+	return &models.RouteResponse{
+		ID:     uuid.New().String(),
+		Result: output,
+		Model:  p.model,
+		Usage: models.Usage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
+		},
+	}, nil
+}
+
+func (p *MockProvider) GenerateStream(
+	context.Context, string, map[string]interface{},
+) (<-chan models.StreamResponse, error) {
+	return nil, fmt.Errorf("streaming is not supported by the mock provider")
+}
+
+func (p *MockProvider) GetModelInfo() models.ModelInfo {
+	return models.ModelInfo{
+		ID:           p.model,
+		Provider:     "mock",
+		Capabilities: []string{"chat"},
+		MaxTokens:    128000,
+	}
+}
+
+func (p *MockProvider) IsHealthy() bool {
+	return true
+}
+
+// NewMockRouter returns a router service with a mock provider for each of the models
+func NewMockRouter(suite *Suite, modelNames []string) *service.RouterService {
+	providers := make(map[string]llm.Provider, len(modelNames))
+	for _, model := range modelNames {
+		providers["mock_"+model] = NewMockProvider(suite, model)
+	}
+	catalog := service.NewCatalogService(providers, nil, &config.Config{})
+	return service.NewRouterService(providers, catalog, nil, nil)
+}
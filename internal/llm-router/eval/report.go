@@ -0,0 +1,156 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+)
+
+// Report is the scored outcome of a suite run
+type Report struct {
+	Suite      string        `json:"suite"`
+	Judge      string        `json:"judge,omitempty"`
+	StartedAt  time.Time     `json:"startedAt"`
+	DurationMs int64         `json:"durationMs"`
+	Models     []ModelReport `json:"models"`
+}
+
+// ModelReport holds the results of the cases of a model. Score is the average score of its cases.
+type ModelReport struct {
+	Model  string       `json:"model"`
+	Score  float64      `json:"score"`
+	Passed int          `json:"passed"`
+	Failed int          `json:"failed"`
+	Errors int          `json:"errors"`
+	Cases  []CaseResult `json:"cases"`
+}
+
+// CaseResult is the output of a case and its assertions. Score is the share of the assertions that
+// passed, a case passes when all of them did. Model is the model that served the case.
+type CaseResult struct {
+	Name        string            `json:"name"`
+	Prompt      string            `json:"prompt"`
+	Output      string            `json:"output"`
+	Model       string            `json:"model,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	LatencyMs   int64             `json:"latencyMs"`
+	TotalTokens int               `json:"totalTokens"`
+	Score       float64           `json:"score"`
+	Passed      bool              `json:"passed"`
+	Assertions  []AssertionResult `json:"assertions"`
+}
+
+func (c *CaseResult) score() {
+	counted, passed := 0, 0
+	for _, assertion := range c.Assertions {
+		if assertion.Skipped {
+			continue
+		}
+		counted++
+		if assertion.Passed {
+			passed++
+		}
+	}
+
+	c.Score = 1
+	if counted > 0 {
+		c.Score = float64(passed) / float64(counted)
+	}
+	c.Passed = passed == counted
+}
+
+func (m *ModelReport) summarize() {
+	total := 0.0
+	for _, c := range m.Cases {
+		switch {
+		case c.Error != "":
+			m.Errors++
+		case c.Passed:
+			m.Passed++
+		default:
+			m.Failed++
+		}
+		total += c.Score
+	}
+	if len(m.Cases) > 0 {
+		m.Score = total / float64(len(m.Cases))
+	}
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteHTML writes the report as a standalone HTML page
+func (r *Report) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, r)
+}
+
+var reportTemplate = template.Must(
+	template.New("report").Funcs(
+		template.FuncMap{
+			"percent": func(score float64) string {
+				return fmt.Sprintf("%.1f%%", score*100)
+			},
+		},
+	).Parse(
+		`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Evaluation of {{.Suite}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 0.4em; text-align: left; vertical-align: top; }
+.pass { color: #1a7f37; }
+.fail { color: #cf222e; }
+.skip { color: #6e7781; }
+pre { white-space: pre-wrap; margin: 0; }
+</style>
+</head>
+<body>
+<h1>Evaluation of {{.Suite}}</h1>
+<p>
+Started {{.StartedAt.Format "2006-01-02 15:04:05 UTC"}}, took {{.DurationMs}} ms
+{{- if .Judge}}, judged by {{.Judge}}{{end}}
+</p>
+<table>
+<tr><th>Model</th><th>Score</th><th>Passed</th><th>Failed</th><th>Errors</th></tr>
+{{range .Models}}<tr>
+<td><a href="#{{.Model}}">{{.Model}}</a></td>
+<td>{{percent .Score}}</td>
+<td>{{.Passed}}</td>
+<td>{{.Failed}}</td>
+<td>{{.Errors}}</td>
+</tr>
+{{end}}</table>
+{{range .Models}}
+<h2 id="{{.Model}}">{{.Model}}</h2>
+<table>
+<tr><th>Case</th><th>Score</th><th>Latency</th><th>Assertions</th><th>Output</th></tr>
+{{range .Cases}}<tr>
+<td class="{{if .Passed}}pass{{else}}fail{{end}}">{{.Name}}</td>
+<td>{{percent .Score}}</td>
+<td>{{.LatencyMs}} ms</td>
+<td>
+{{- if .Error}}<span class="fail">{{.Error}}</span>{{end}}
+{{- range .Assertions}}
+<div class="{{if .Skipped}}skip{{else if .Passed}}pass{{else}}fail{{end}}">
+{{- .Type}}{{if .Message}}: {{.Message}}{{end}}</div>
+{{- end}}
+</td>
+<td><details><summary>{{.Model}}</summary><pre>{{.Output}}</pre></details></td>
+</tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`,
+	),
+)
@@ -0,0 +1,163 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service"
+)
+
+// Runner sends the cases of a suite through the router service, so they are routed, trimmed and
+// retried like production requests
+type Runner struct {
+	router *service.RouterService
+	// judge is the model grading judge assertions, they are skipped without one
+	judge string
+}
+
+func NewRunner(router *service.RouterService, judge string) *Runner {
+	return &Runner{
+		router: router,
+		judge:  judge,
+	}
+}
+
+// Run sends every case of the suite to every model and scores the outputs
+func (r *Runner) Run(ctx context.Context, suite *Suite, modelNames []string) (*Report, error) {
+	if len(modelNames) == 0 {
+		return nil, fmt.Errorf("no models to evaluate")
+	}
+	targets := make(map[string]models.ModelInfo, len(modelNames))
+	for _, model := range append([]string{r.judge}, modelNames...) {
+		if model == "" {
+			continue
+		}
+		info, ok := r.router.EnabledModel(model)
+		if !ok {
+			return nil, fmt.Errorf("model %s is not in the catalog or its provider is not enabled", model)
+		}
+		targets[model] = info
+	}
+
+	report := &Report{Suite: suite.Name, Judge: r.judge, StartedAt: time.Now().UTC()}
+	for _, model := range modelNames {
+		modelReport := ModelReport{Model: model}
+		for _, c := range suite.Cases {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			modelReport.Cases = append(modelReport.Cases, r.runCase(ctx, c, targets[model]))
+		}
+		modelReport.summarize()
+		report.Models = append(report.Models, modelReport)
+	}
+	report.DurationMs = time.Since(report.StartedAt).Milliseconds()
+	return report, nil
+}
+
+// runCase sends a case to the target model. A case answered by another model is an error, its
+// output would be scored for the wrong model.
+func (r *Runner) runCase(ctx context.Context, c Case, target models.ModelInfo) CaseResult {
+	result := CaseResult{Name: c.Name, Prompt: c.Prompt}
+
+	start := time.Now()
+	resp, err := r.router.Route(
+		ctx, models.RouteRequest{
+			Prompt:         c.Prompt,
+			PreferredModel: target.ID,
+			Parameters:     c.Parameters,
+			Messages:       c.Messages,
+		},
+	)
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Output = resp.Result
+	result.Model = resp.Model
+	result.TotalTokens = resp.Usage.TotalTokens
+	if !answeredBy(resp.Model, target) {
+		result.Error = fmt.Sprintf("answered by %s instead of %s", resp.Model, target.ID)
+		return result
+	}
+
+	for _, assertion := range c.checks() {
+		if assertion.Type == AssertionJudge {
+			result.Assertions = append(result.Assertions, r.judgeOutput(ctx, c, assertion, resp.Result))
+			continue
+		}
+		result.Assertions = append(result.Assertions, check(assertion, resp.Result))
+	}
+	result.score()
+	return result
+}
+
+// judgeOutput asks the judge model whether the output meets the criteria of the assertion
+func (r *Runner) judgeOutput(ctx context.Context, c Case, assertion Assertion, output string) AssertionResult {
+	result := AssertionResult{Type: assertion.Type}
+	if r.judge == "" {
+		result.Skipped = true
+		result.Message = "no judge model"
+		return result
+	}
+
+	resp, err := r.router.Route(
+		ctx, models.RouteRequest{
+			Prompt:         judgePrompt(c, assertion.Criteria, output),
+			PreferredModel: r.judge,
+			Parameters:     map[string]interface{}{"temperature": 0.0},
+			ResponseFormat: &models.ResponseFormat{
+				Type:   models.ResponseFormatJSONSchema,
+				Name:   "judgement",
+				Schema: judgeSchema,
+			},
+		},
+	)
+	if err != nil {
+		result.Message = fmt.Sprintf("judge failed: %v", err)
+		return result
+	}
+
+	var verdict struct {
+		Pass   bool   `json:"pass"`
+		Reason string `json:"reason"`
+	}
+	data, err := json.Marshal(resp.Parsed)
+	if err == nil {
+		err = json.Unmarshal(data, &verdict)
+	}
+	if err != nil {
+		result.Message = fmt.Sprintf("invalid judgement: %v", err)
+		return result
+	}
+	result.Passed = verdict.Pass
+	result.Message = verdict.Reason
+	return result
+}
+
+// snapshotSuffix is the suffix vendors add to the model of a response to name the dated snapshot
+// that answered, such as gpt-4-0613 or gpt-4o-2024-05-13
+var snapshotSuffix = regexp.MustCompile(`^-(\d{4}|\d{4}-\d{2}-\d{2})$`)
+
+// answeredBy reports whether a response model is the target model, one of its aliases or a dated
+// snapshot of it
+func answeredBy(model string, target models.ModelInfo) bool {
+	if model == target.ID {
+		return true
+	}
+	if rest, ok := strings.CutPrefix(model, target.ID); ok && snapshotSuffix.MatchString(rest) {
+		return true
+	}
+	for _, alias := range target.Aliases {
+		if model == alias {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+// Package eval runs suites of prompts with expected outputs against models of the router and
+// scores the responses, so a model change can be checked for quality regressions.
+package eval
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"workspace-engine/internal/llm-router/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	AssertionEquals     = "equals"
+	AssertionContains   = "contains"
+	AssertionRegex      = "regex"
+	AssertionJSONSchema = "json_schema"
+	AssertionJudge      = "judge"
+)
+
+// Suite is a named set of cases
+type Suite struct {
+	Name  string `yaml:"name" json:"name"`
+	Cases []Case `yaml:"cases" json:"cases"`
+}
+
+// Case is a prompt and the checks its output must pass. Expected is a shorthand for an equals
+// assertion. Mock is the output of the mock provider used for offline runs.
+type Case struct {
+	Name       string                 `yaml:"name" json:"name"`
+	Prompt     string                 `yaml:"prompt" json:"prompt"`
+	Messages   []models.Message       `yaml:"messages" json:"messages"`
+	Parameters map[string]interface{} `yaml:"parameters" json:"parameters"`
+	Expected   string                 `yaml:"expected" json:"expected"`
+	Assertions []Assertion            `yaml:"assertions" json:"assertions"`
+	Mock       string                 `yaml:"mock" json:"mock"`
+}
+
+// Assertion is a check of the output of a case. Value is the expected text of equals and contains
+// assertions and the pattern of regex assertions, Schema the JSON Schema of json_schema assertions
+// and Criteria what the judge model grades the output on.
+type Assertion struct {
+	Type     string                 `yaml:"type" json:"type"`
+	Value    string                 `yaml:"value" json:"value"`
+	Schema   map[string]interface{} `yaml:"schema" json:"schema"`
+	Criteria string                 `yaml:"criteria" json:"criteria"`
+}
+
+// LoadSuite reads a suite from a YAML file, or from a JSONL file with one case per line. A JSONL
+// suite is named after its file.
+func LoadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suite: %w", err)
+	}
+
+	var suite Suite
+	if strings.EqualFold(filepath.Ext(path), ".jsonl") {
+		suite.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			var c Case
+			if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+				return nil, fmt.Errorf("invalid case on line %d: %w", line, err)
+			}
+			suite.Cases = append(suite.Cases, c)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read suite: %w", err)
+		}
+	} else if err := yaml.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("invalid suite: %w", err)
+	}
+
+	if err := suite.validate(); err != nil {
+		return nil, err
+	}
+	return &suite, nil
+}
+
+func (s *Suite) validate() error {
+	if len(s.Cases) == 0 {
+		return errors.New("suite has no cases")
+	}
+
+	names := map[string]bool{}
+	for i := range s.Cases {
+		c := &s.Cases[i]
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("case-%d", i+1)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("duplicate case %s", c.Name)
+		}
+		names[c.Name] = true
+
+		if c.Prompt == "" {
+			return fmt.Errorf("case %s has no prompt", c.Name)
+		}
+		if err := c.normalizeParameters(); err != nil {
+			return err
+		}
+		if c.Expected == "" && len(c.Assertions) == 0 {
+			return fmt.Errorf("case %s has neither an expected output nor assertions", c.Name)
+		}
+		for j, assertion := range c.Assertions {
+			if err := assertion.validate(); err != nil {
+				return fmt.Errorf("assertion %d of case %s: %w", j+1, c.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (a Assertion) validate() error {
+	switch a.Type {
+	case AssertionEquals, AssertionContains:
+		if a.Value == "" {
+			return fmt.Errorf("%s needs a value", a.Type)
+		}
+	case AssertionRegex:
+		if _, err := compileRegex(a.Value); err != nil {
+			return err
+		}
+	case AssertionJSONSchema:
+		if _, err := compileSchema(a.Schema); err != nil {
+			return err
+		}
+	case AssertionJudge:
+		if a.Criteria == "" {
+			return errors.New("judge needs criteria")
+		}
+	default:
+		return fmt.Errorf("unknown assertion type %q", a.Type)
+	}
+	return nil
+}
+
+// normalizeParameters decodes the parameters again as JSON, so numbers of YAML suites are float64
+// like those of API requests
+func (c *Case) normalizeParameters() error {
+	if c.Parameters == nil {
+		return nil
+	}
+	data, err := json.Marshal(c.Parameters)
+	if err != nil {
+		return fmt.Errorf("invalid parameters of case %s: %w", c.Name, err)
+	}
+	c.Parameters = nil
+	if err := json.Unmarshal(data, &c.Parameters); err != nil {
+		return fmt.Errorf("invalid parameters of case %s: %w", c.Name, err)
+	}
+	return nil
+}
+
+// checks returns the assertions of a case, with the expected output as the first one
+func (c Case) checks() []Assertion {
+	if c.Expected == "" {
+		return c.Assertions
+	}
+	return append([]Assertion{{Type: AssertionEquals, Value: c.Expected}}, c.Assertions...)
+}
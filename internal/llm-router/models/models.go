@@ -1,12 +1,103 @@
 package models
 
+import (
+	"encoding/json"
+)
+
 type RouteRequest struct {
 	Prompt         string                 `json:"prompt"`
 	PreferredModel string                 `json:"preferredModel,omitempty"`
 	Parameters     map[string]interface{} `json:"parameters,omitempty"`
 	Context        RequestContext         `json:"context,omitempty"`
+	Template       *TemplateRef           `json:"template,omitempty"`
+	ResponseFormat *ResponseFormat        `json:"responseFormat,omitempty"`
+	// Content holds parts that are sent after the prompt, such as images and documents
+	Content []ContentPart `json:"content,omitempty"`
+	// Capabilities are model capabilities the request needs in addition to those of its content
+	Capabilities []string `json:"capabilities,omitempty"`
+	// Messages is the conversation before the prompt. It is trimmed to the context window of the
+	// selected model, dropping the oldest messages first.
+	Messages []Message `json:"messages,omitempty"`
+}
+
+// Message is a role-tagged message of a conversation
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+const (
+	MessageRoleSystem    = "system"
+	MessageRoleUser      = "user"
+	MessageRoleAssistant = "assistant"
+)
+
+// RoutePlan explains how a request would be routed without sending it
+type RoutePlan struct {
+	// Rule is the routing rule the request matched, empty when it matched none
+	Rule     string `json:"rule,omitempty"`
+	Strategy string `json:"strategy"`
+	// Candidates are the provider keys the request may be sent to, in order of preference
+	Candidates  []string `json:"candidates"`
+	ProviderKey string   `json:"providerKey,omitempty"`
+	Model       string   `json:"model,omitempty"`
+	// Shadow is the model the request would be mirrored to
+	Shadow string `json:"shadow,omitempty"`
+	// UpgradedFrom is the model the request was routed to before it was moved to a model with a
+	// larger context window
+	UpgradedFrom string `json:"upgradedFrom,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+const (
+	RouteStrategyPreferred = "preferred"
+	RouteStrategyModel     = "model"
+	RouteStrategyWeighted  = "weighted"
+	RouteStrategyFallback  = "fallback"
+	RouteStrategyAutomatic = "automatic"
+)
+
+// ContentPart is a part of a multimodal message. Images and documents are given either by URL or
+// as base64 Data together with their MediaType.
+type ContentPart struct {
+	Type      string `json:"type"`
+	Text      string `json:"text,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Data      string `json:"data,omitempty"`
+	MediaType string `json:"mediaType,omitempty"`
 }
 
+const (
+	ContentPartText     = "text"
+	ContentPartImage    = "image"
+	ContentPartDocument = "document"
+)
+
+// CapabilityVision marks models that accept image and document parts
+const CapabilityVision = "vision"
+
+// TemplateRef selects a stored prompt template and the variables used to render it.
+// A zero Version selects the latest version.
+type TemplateRef struct {
+	Name      string                 `json:"name"`
+	Version   int                    `json:"version,omitempty"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+// ResponseFormat asks the router for output that parses as JSON and, when a schema is given,
+// validates against it. Invalid output is repaired up to MaxRetries times.
+type ResponseFormat struct {
+	Type       string          `json:"type"`
+	Name       string          `json:"name,omitempty"`
+	Schema     json.RawMessage `json:"schema,omitempty"`
+	MaxRetries int             `json:"maxRetries,omitempty"`
+}
+
+const (
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
 type RequestContext struct {
 	Priority string `json:"priority,omitempty"`
 	Timeout  int    `json:"timeout,omitempty"`
@@ -15,6 +106,7 @@ type RequestContext struct {
 type RouteResponse struct {
 	ID       string                 `json:"id"`
 	Result   string                 `json:"result"`
+	Parsed   interface{}            `json:"parsed,omitempty"`
 	Model    string                 `json:"model"`
 	Usage    Usage                  `json:"usage"`
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
@@ -26,13 +118,269 @@ type Usage struct {
 	TotalTokens      int `json:"totalTokens"`
 }
 
+type PromptTemplate struct {
+	Name        string                 `json:"name"`
+	Version     int                    `json:"version"`
+	Description string                 `json:"description,omitempty"`
+	Template    string                 `json:"template"`
+	Model       string                 `json:"model,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+	CreatedAt   string                 `json:"createdAt"`
+}
+
+type TemplateRequest struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Template    string                 `json:"template"`
+	Model       string                 `json:"model,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+type UsageRecord struct {
+	RequestID       string `json:"requestId"`
+	Model           string `json:"model"`
+	Provider        string `json:"provider,omitempty"`
+	TemplateName    string `json:"templateName,omitempty"`
+	TemplateVersion int    `json:"templateVersion,omitempty"`
+	Usage           Usage  `json:"usage"`
+	CreatedAt       string `json:"createdAt"`
+}
+
+type UsageFilter struct {
+	Model           string `form:"model"`
+	TemplateName    string `form:"template"`
+	TemplateVersion int    `form:"templateVersion"`
+	Limit           int    `form:"limit"`
+}
+
+const (
+	EstimateConfidenceHigh = "high"
+	EstimateConfidenceMed  = "med"
+	EstimateConfidenceNone = "none"
+)
+
+// EstimateResponse is the predicted cost of routing a request. The confidence uses the status
+// values of the workspace engine prompt estimates.
+type EstimateResponse struct {
+	Model        string  `json:"model"`
+	Provider     string  `json:"provider"`
+	Amount       float64 `json:"amount"`
+	Currency     string  `json:"currency"`
+	InputTokens  int     `json:"inputTokens"`
+	OutputTokens int     `json:"outputTokens"`
+	TotalTokens  int     `json:"totalTokens"`
+	Confidence   string  `json:"confidence"`
+	// OutputSource tells where the output token count comes from: history, maxTokens or none
+	OutputSource string `json:"outputSource"`
+}
+
+// BatchLine is a single line of an uploaded batch file
+type BatchLine struct {
+	CustomID string       `json:"customId,omitempty"`
+	Request  RouteRequest `json:"request"`
+}
+
+// BatchResult is a single line of a batch results file
+type BatchResult struct {
+	Line     int            `json:"line"`
+	CustomID string         `json:"customId,omitempty"`
+	Status   string         `json:"status"`
+	Response *RouteResponse `json:"response,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Usage    Usage          `json:"usage"`
+}
+
+type BatchJob struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	CallbackURL string `json:"callbackUrl,omitempty"`
+	Total       int    `json:"total"`
+	Completed   int    `json:"completed"`
+	Failed      int    `json:"failed"`
+	Error       string `json:"error,omitempty"`
+	CreatedAt   string `json:"createdAt"`
+	FinishedAt  string `json:"finishedAt,omitempty"`
+}
+
+const (
+	BatchStatusQueued    = "queued"
+	BatchStatusRunning   = "running"
+	BatchStatusCompleted = "completed"
+	BatchStatusFailed    = "failed"
+	BatchStatusCancelled = "cancelled"
+)
+
+const (
+	ProviderEnabled  = "enabled"
+	ProviderDisabled = "disabled"
+	ProviderDraining = "draining"
+)
+
+// ProviderStatus is the runtime state of a provider instance as shown by the admin API
+type ProviderStatus struct {
+	Key    string `json:"key"`
+	Vendor string `json:"vendor"`
+	Model  string `json:"model"`
+	Status string `json:"status"`
+	Weight int    `json:"weight"`
+	// Healthy and LastProbe are the result of the most recent health probe
+	Healthy          bool    `json:"healthy"`
+	LastProbe        string  `json:"lastProbe,omitempty"`
+	InFlight         int64   `json:"inFlight"`
+	Requests         int64   `json:"requests"`
+	Failures         int64   `json:"failures"`
+	AverageLatencyMs float64 `json:"averageLatencyMs"`
+	LastError        string  `json:"lastError,omitempty"`
+	// Keys is the usage of the API keys of instances with a key pool
+	Keys []ProviderKeyStatus `json:"keys,omitempty"`
+}
+
+// ProviderKeyStatus is the usage of an API key of a key pool. The key itself is never shown.
+type ProviderKeyStatus struct {
+	Name             string `json:"name"`
+	Weight           int    `json:"weight"`
+	Requests         int64  `json:"requests"`
+	RateLimited      int64  `json:"rateLimited"`
+	Failures         int64  `json:"failures"`
+	PromptTokens     int64  `json:"promptTokens"`
+	CompletionTokens int64  `json:"completionTokens"`
+	LastRateLimited  string `json:"lastRateLimited,omitempty"`
+	// CoolingDownUntil is set while the key is skipped after a rate limit
+	CoolingDownUntil string `json:"coolingDownUntil,omitempty"`
+}
+
+// ProviderUpdate changes a provider instance, fields that are not set are left unchanged.
+// Model is only accepted for the default instance of a vendor.
+type ProviderUpdate struct {
+	Status *string `json:"status,omitempty"`
+	Weight *int    `json:"weight,omitempty"`
+	Model  *string `json:"model,omitempty"`
+}
+
+// Session is a conversation kept by the router. Messages are only included when it is exported.
+type Session struct {
+	ID           string                 `json:"id"`
+	Title        string                 `json:"title,omitempty"`
+	Model        string                 `json:"model,omitempty"`
+	System       string                 `json:"system,omitempty"`
+	Parameters   map[string]interface{} `json:"parameters,omitempty"`
+	MessageCount int                    `json:"messageCount"`
+	CreatedAt    string                 `json:"createdAt"`
+	UpdatedAt    string                 `json:"updatedAt"`
+	Messages     []SessionMessage       `json:"messages,omitempty"`
+}
+
+type SessionMessage struct {
+	Role      string `json:"role"`
+	Content   string `json:"content"`
+	Model     string `json:"model,omitempty"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// SessionRequest creates a session. Model is the preferred model of every turn and System the
+// system message that starts the conversation.
+type SessionRequest struct {
+	Title      string                 `json:"title"`
+	Model      string                 `json:"model"`
+	System     string                 `json:"system"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// SessionTurn is a user message appended to a session. Its parameters override those of the session.
+type SessionTurn struct {
+	Content    string                 `json:"content" binding:"required"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// ShadowResult pairs the response of a request with the response of the model it was mirrored to
+type ShadowResult struct {
+	ID               uint   `json:"id"`
+	RequestID        string `json:"requestId"`
+	Rule             string `json:"rule"`
+	Prompt           string `json:"prompt"`
+	PrimaryModel     string `json:"primaryModel"`
+	PrimaryResult    string `json:"primaryResult"`
+	PrimaryLatencyMs int64  `json:"primaryLatencyMs"`
+	PrimaryTokens    int    `json:"primaryTokens"`
+	ShadowModel      string `json:"shadowModel"`
+	ShadowResult     string `json:"shadowResult,omitempty"`
+	ShadowError      string `json:"shadowError,omitempty"`
+	ShadowLatencyMs  int64  `json:"shadowLatencyMs"`
+	ShadowTokens     int    `json:"shadowTokens"`
+	CreatedAt        string `json:"createdAt"`
+}
+
+type ShadowFilter struct {
+	Rule  string `form:"rule"`
+	Limit int    `form:"limit"`
+}
+
+// APIKey is an API key issued by the router. The key itself is only returned when it is created,
+// afterwards it is shown by its prefix.
+type APIKey struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Prefix    string `json:"prefix"`
+	Key       string `json:"key,omitempty"`
+	CreatedAt string `json:"createdAt"`
+	RevokedAt string `json:"revokedAt,omitempty"`
+}
+
+// APIKeyRequest issues a new API key of the router
+type APIKeyRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// CredentialPolicy decides whether requests made with an API key of the router may carry their own
+// provider API keys. The API key is only shown as its SHA-256 hash.
+type CredentialPolicy struct {
+	KeyHash   string `json:"keyHash"`
+	Allowed   bool   `json:"allowed"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// CredentialPolicyUpdate allows or denies provider API keys for an API key of the router
+type CredentialPolicyUpdate struct {
+	APIKey  string `json:"apiKey" binding:"required"`
+	Allowed *bool  `json:"allowed" binding:"required"`
+}
+
+const (
+	ReloadSucceeded = "succeeded"
+	ReloadFailed    = "failed"
+)
+
+// ReloadStatus describes the configuration the router runs with. Generation counts the applied
+// configurations, starting with 1 for the one loaded at startup. A failed reload leaves the last
+// applied configuration in effect.
+type ReloadStatus struct {
+	Generation  int    `json:"generation"`
+	Status      string `json:"status"`
+	Trigger     string `json:"trigger"`
+	LastAttempt string `json:"lastAttempt"`
+	LastSuccess string `json:"lastSuccess"`
+	Error       string `json:"error,omitempty"`
+}
+
+type AuditRecord struct {
+	ID        uint            `json:"id"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target"`
+	Details   json.RawMessage `json:"details,omitempty"`
+	CreatedAt string          `json:"createdAt"`
+}
+
 type ModelInfo struct {
 	ID           string   `json:"id"`
 	Name         string   `json:"name"`
 	Provider     string   `json:"provider"`
+	Aliases      []string `json:"aliases,omitempty"`
 	Capabilities []string `json:"capabilities"`
 	MaxTokens    int      `json:"maxTokens"`
 	Pricing      Pricing  `json:"pricing"`
+	// Available is false when the vendor no longer lists the model
+	Available bool `json:"available"`
 }
 
 type Pricing struct {
@@ -57,7 +405,13 @@ type StreamResponse struct {
 	ID      string `json:"id,omitempty"`
 	Content string `json:"content,omitempty"`
 	Done    bool   `json:"done"`
-	Error   error  `json:"error,omitempty"`
+	// FinishReason and Usage are set on the last chunk by providers that report them
+	FinishReason string `json:"finishReason,omitempty"`
+	Usage        *Usage `json:"usage,omitempty"`
+	// Metadata is set on the first chunk, upgraded_from names the model a request that did not fit
+	// its context window was moved from
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	Error    error                  `json:"error,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -0,0 +1,144 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/rpc/routerpb"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func toRouteRequest(req *routerpb.RouteRequest) (models.RouteRequest, error) {
+	routeReq := models.RouteRequest{
+		Prompt:         req.GetPrompt(),
+		PreferredModel: req.GetPreferredModel(),
+		Capabilities:   req.GetCapabilities(),
+		Context: models.RequestContext{
+			Priority: req.GetContext().GetPriority(),
+			Timeout:  int(req.GetContext().GetTimeout()),
+		},
+	}
+	if req.GetParameters() != nil {
+		routeReq.Parameters = req.GetParameters().AsMap()
+	}
+
+	if tmpl := req.GetTemplate(); tmpl != nil {
+		routeReq.Template = &models.TemplateRef{
+			Name:    tmpl.GetName(),
+			Version: int(tmpl.GetVersion()),
+		}
+		if tmpl.GetVariables() != nil {
+			routeReq.Template.Variables = tmpl.GetVariables().AsMap()
+		}
+	}
+
+	if format := req.GetResponseFormat(); format != nil {
+		routeReq.ResponseFormat = &models.ResponseFormat{
+			Type:       format.GetType(),
+			Name:       format.GetName(),
+			MaxRetries: int(format.GetMaxRetries()),
+		}
+		if format.GetSchema() != nil {
+			schema, err := json.Marshal(format.GetSchema().AsMap())
+			if err != nil {
+				return models.RouteRequest{}, fmt.Errorf("invalid response format schema: %w", err)
+			}
+			routeReq.ResponseFormat.Schema = schema
+		}
+	}
+
+	for _, part := range req.GetContent() {
+		routeReq.Content = append(
+			routeReq.Content, models.ContentPart{
+				Type:      part.GetType(),
+				Text:      part.GetText(),
+				URL:       part.GetUrl(),
+				Data:      part.GetData(),
+				MediaType: part.GetMediaType(),
+			},
+		)
+	}
+	for _, message := range req.GetMessages() {
+		routeReq.Messages = append(
+			routeReq.Messages, models.Message{Role: message.GetRole(), Content: message.GetContent()},
+		)
+	}
+	return routeReq, nil
+}
+
+func fromRouteResponse(resp *models.RouteResponse) (*routerpb.RouteResponse, error) {
+	pbResp := &routerpb.RouteResponse{
+		Id:     resp.ID,
+		Result: resp.Result,
+		Model:  resp.Model,
+		Usage: &routerpb.Usage{
+			PromptTokens:     int32(resp.Usage.PromptTokens),
+			CompletionTokens: int32(resp.Usage.CompletionTokens),
+			TotalTokens:      int32(resp.Usage.TotalTokens),
+		},
+	}
+
+	if resp.Parsed != nil {
+		var parsed interface{}
+		if err := roundTrip(resp.Parsed, &parsed); err != nil {
+			return nil, fmt.Errorf("failed to convert parsed output: %w", err)
+		}
+		value, err := structpb.NewValue(parsed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert parsed output: %w", err)
+		}
+		pbResp.Parsed = value
+	}
+
+	if len(resp.Metadata) > 0 {
+		var metadata map[string]interface{}
+		if err := roundTrip(resp.Metadata, &metadata); err != nil {
+			return nil, fmt.Errorf("failed to convert metadata: %w", err)
+		}
+		value, err := structpb.NewStruct(metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert metadata: %w", err)
+		}
+		pbResp.Metadata = value
+	}
+	return pbResp, nil
+}
+
+func fromModelInfo(info models.ModelInfo) *routerpb.ModelInfo {
+	return &routerpb.ModelInfo{
+		Id:           info.ID,
+		Name:         info.Name,
+		Provider:     info.Provider,
+		Aliases:      info.Aliases,
+		Capabilities: info.Capabilities,
+		MaxTokens:    int32(info.MaxTokens),
+		Pricing: &routerpb.Pricing{
+			InputPrice:  info.Pricing.InputPrice,
+			OutputPrice: info.Pricing.OutputPrice,
+			Currency:    info.Pricing.Currency,
+		},
+		Available: info.Available,
+	}
+}
+
+func fromHealthStatus(health models.HealthStatus) *routerpb.HealthResponse {
+	resp := &routerpb.HealthResponse{
+		Status: health.Status,
+		Models: make(map[string]*routerpb.ModelStatus, len(health.Models)),
+	}
+	for id, model := range health.Models {
+		resp.Models[id] = &routerpb.ModelStatus{Status: model.Status, Latency: int32(model.Latency)}
+	}
+	return resp
+}
+
+// roundTrip converts a value to the plain JSON types structpb accepts
+func roundTrip(value interface{}, target interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
@@ -0,0 +1,167 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/rpc/routerpb"
+	"workspace-engine/internal/llm-router/service"
+	"workspace-engine/pkg/logger"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// apiKeyMetadata, tenantMetadata, providerKeyMetadata and requestIDMetadata are the gRPC
+	// counterparts of the X-API-Key, X-Tenant-ID, X-Provider-Key and X-Request-ID headers of the
+	// HTTP API
+	apiKeyMetadata      = "x-api-key"
+	tenantMetadata      = "x-tenant-id"
+	providerKeyMetadata = "x-provider-key"
+	requestIDMetadata   = "x-request-id"
+)
+
+// publicMethods can be called without an API key, like the health endpoint of the HTTP API
+var publicMethods = map[string]bool{
+	routerpb.Router_Health_FullMethodName: true,
+}
+
+// authenticator checks the API key of calls, the tenants are looked up on every call, so the
+// bindings of API keys to tenants follow configuration reloads
+type authenticator struct {
+	keys    *service.APIKeyService
+	tenants func() []config.TenantConfig
+}
+
+func (a authenticator) unary(
+	ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (interface{}, error) {
+	ctx, err := a.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a authenticator) stream(
+	srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+) error {
+	ctx, err := a.authenticate(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticate requires an API key for all but the public methods and attaches the caller to the
+// context, the same way AuthMiddleware does for HTTP requests
+func (a authenticator) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if publicMethods[method] {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	apiKey := first(md, apiKeyMetadata)
+	if apiKey == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing API key")
+	}
+
+	if err := a.keys.Validate(apiKey); err != nil {
+		if errors.Is(err, service.ErrInvalidAPIKey) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	credentials, err := service.ParseCredentials(md.Get(providerKeyMetadata))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	tenant, err := service.ResolveTenant(a.tenants(), apiKey, first(md, tenantMetadata))
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	// Provider keys are only kept on the caller, so they cannot leak through the headers
+	headers := make(http.Header, len(md))
+	for key, values := range md {
+		if key != providerKeyMetadata {
+			headers[http.CanonicalHeaderKey(key)] = values
+		}
+	}
+	return service.WithCaller(
+		ctx, service.Caller{
+			APIKey:      apiKey,
+			Tenant:      tenant,
+			Headers:     headers,
+			Credentials: credentials,
+		},
+	), nil
+}
+
+func loggingUnaryInterceptor(
+	ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (interface{}, error) {
+	ctx, finish := logCall(ctx, info.FullMethod)
+	resp, err := handler(ctx, req)
+	finish(err)
+	return resp, err
+}
+
+func loggingStreamInterceptor(
+	srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+) error {
+	ctx, finish := logCall(stream.Context(), info.FullMethod)
+	err := handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+	finish(err)
+	return err
+}
+
+// logCall assigns a request id to a call, taken from the x-request-id metadata when the caller
+// sends one, and logs the call with it
+func logCall(ctx context.Context, method string) (context.Context, func(err error)) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	requestID := first(md, requestIDMetadata)
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
+	ctx = logger.WithRequestID(ctx, requestID)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, requestID))
+
+	logger.InfoContext(ctx, "Incoming call", "method", method)
+	start := time.Now()
+
+	return ctx, func(err error) {
+		logger.InfoContext(
+			ctx,
+			"Call completed",
+			"method", method,
+			"code", status.Code(err).String(),
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	}
+}
+
+// contextStream replaces the context of a server stream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,257 @@
+// gRPC API of the LLM router. It mirrors the routing endpoints of the HTTP API in openapi.yml.
+//
+// Calls are authenticated with the x-api-key metadata key, like the X-API-Key header of the HTTP
+// API. The x-tenant-id metadata key names the tenant used by the routing rules, it must be bound to
+// the API key like the X-Tenant-ID header. x-provider-key carries provider API keys of the caller
+// in the form vendor=key, like the X-Provider-Key header.
+//
+// The Go code in internal/llm-router/rpc/routerpb is generated from this file with protoc-gen-go
+// and protoc-gen-go-grpc and checked in.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: router.proto
+
+package routerpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Router_Route_FullMethodName       = "/llmrouter.v1.Router/Route"
+	Router_RouteStream_FullMethodName = "/llmrouter.v1.Router/RouteStream"
+	Router_ListModels_FullMethodName  = "/llmrouter.v1.Router/ListModels"
+	Router_Health_FullMethodName      = "/llmrouter.v1.Router/Health"
+)
+
+// RouterClient is the client API for Router service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RouterClient interface {
+	// Route routes a prompt to the best-suited model and returns the whole response
+	Route(ctx context.Context, in *RouteRequest, opts ...grpc.CallOption) (*RouteResponse, error)
+	// RouteStream routes a prompt and streams the response as it is generated
+	RouteStream(ctx context.Context, in *RouteRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StreamResponse], error)
+	// ListModels returns the models of the catalog
+	ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error)
+	// Health reports the health of the providers. It does not require an API key.
+	Health(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error)
+}
+
+type routerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRouterClient(cc grpc.ClientConnInterface) RouterClient {
+	return &routerClient{cc}
+}
+
+func (c *routerClient) Route(ctx context.Context, in *RouteRequest, opts ...grpc.CallOption) (*RouteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RouteResponse)
+	err := c.cc.Invoke(ctx, Router_Route_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *routerClient) RouteStream(ctx context.Context, in *RouteRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StreamResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Router_ServiceDesc.Streams[0], Router_RouteStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RouteRequest, StreamResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Router_RouteStreamClient = grpc.ServerStreamingClient[StreamResponse]
+
+func (c *routerClient) ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListModelsResponse)
+	err := c.cc.Invoke(ctx, Router_ListModels_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *routerClient) Health(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HealthResponse)
+	err := c.cc.Invoke(ctx, Router_Health_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RouterServer is the server API for Router service.
+// All implementations must embed UnimplementedRouterServer
+// for forward compatibility.
+type RouterServer interface {
+	// Route routes a prompt to the best-suited model and returns the whole response
+	Route(context.Context, *RouteRequest) (*RouteResponse, error)
+	// RouteStream routes a prompt and streams the response as it is generated
+	RouteStream(*RouteRequest, grpc.ServerStreamingServer[StreamResponse]) error
+	// ListModels returns the models of the catalog
+	ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error)
+	// Health reports the health of the providers. It does not require an API key.
+	Health(context.Context, *HealthRequest) (*HealthResponse, error)
+	mustEmbedUnimplementedRouterServer()
+}
+
+// UnimplementedRouterServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRouterServer struct{}
+
+func (UnimplementedRouterServer) Route(context.Context, *RouteRequest) (*RouteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Route not implemented")
+}
+func (UnimplementedRouterServer) RouteStream(*RouteRequest, grpc.ServerStreamingServer[StreamResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method RouteStream not implemented")
+}
+func (UnimplementedRouterServer) ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListModels not implemented")
+}
+func (UnimplementedRouterServer) Health(context.Context, *HealthRequest) (*HealthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Health not implemented")
+}
+func (UnimplementedRouterServer) mustEmbedUnimplementedRouterServer() {}
+func (UnimplementedRouterServer) testEmbeddedByValue()                {}
+
+// UnsafeRouterServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RouterServer will
+// result in compilation errors.
+type UnsafeRouterServer interface {
+	mustEmbedUnimplementedRouterServer()
+}
+
+func RegisterRouterServer(s grpc.ServiceRegistrar, srv RouterServer) {
+	// If the following call pancis, it indicates UnimplementedRouterServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Router_ServiceDesc, srv)
+}
+
+func _Router_Route_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RouteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RouterServer).Route(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Router_Route_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RouterServer).Route(ctx, req.(*RouteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Router_RouteStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RouteRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RouterServer).RouteStream(m, &grpc.GenericServerStream[RouteRequest, StreamResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Router_RouteStreamServer = grpc.ServerStreamingServer[StreamResponse]
+
+func _Router_ListModels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListModelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RouterServer).ListModels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Router_ListModels_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RouterServer).ListModels(ctx, req.(*ListModelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Router_Health_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HealthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RouterServer).Health(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Router_Health_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RouterServer).Health(ctx, req.(*HealthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Router_ServiceDesc is the grpc.ServiceDesc for Router service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Router_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "llmrouter.v1.Router",
+	HandlerType: (*RouterServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Route",
+			Handler:    _Router_Route_Handler,
+		},
+		{
+			MethodName: "ListModels",
+			Handler:    _Router_ListModels_Handler,
+		},
+		{
+			MethodName: "Health",
+			Handler:    _Router_Health_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "RouteStream",
+			Handler:       _Router_RouteStream_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "router.proto",
+}
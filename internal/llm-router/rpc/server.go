@@ -0,0 +1,122 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/rpc/routerpb"
+	"workspace-engine/internal/llm-router/service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements the gRPC API on top of the router service used by the HTTP API
+type Server struct {
+	routerpb.UnimplementedRouterServer
+	router *service.RouterService
+}
+
+// NewServer creates a gRPC server with the router service registered. Calls are authenticated
+// with the API keys of the router and logged like requests to the HTTP API, tenants returns the
+// current bindings of API keys to tenants. opts are added to the server options, e.g. TLS.
+func NewServer(
+	router *service.RouterService, keys *service.APIKeyService, tenants func() []config.TenantConfig,
+	opts ...grpc.ServerOption,
+) *grpc.Server {
+	auth := authenticator{keys: keys, tenants: tenants}
+	server := grpc.NewServer(
+		append(
+			[]grpc.ServerOption{
+				grpc.ChainUnaryInterceptor(loggingUnaryInterceptor, auth.unary),
+				grpc.ChainStreamInterceptor(loggingStreamInterceptor, auth.stream),
+			},
+			opts...,
+		)...,
+	)
+	routerpb.RegisterRouterServer(server, &Server{router: router})
+	return server
+}
+
+func (s *Server) Route(ctx context.Context, req *routerpb.RouteRequest) (*routerpb.RouteResponse, error) {
+	routeReq, err := toRouteRequest(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	resp, err := s.router.Route(ctx, routeReq)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	pbResp, err := fromRouteResponse(resp)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return pbResp, nil
+}
+
+func (s *Server) RouteStream(req *routerpb.RouteRequest, stream routerpb.Router_RouteStreamServer) error {
+	routeReq, err := toRouteRequest(req)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	chunks, err := s.router.RouteStream(stream.Context(), routeReq)
+	if err != nil {
+		return toStatus(err)
+	}
+
+	for chunk := range chunks {
+		if chunk.Error != nil {
+			return status.Error(codes.Unavailable, chunk.Error.Error())
+		}
+		err := stream.Send(
+			&routerpb.StreamResponse{
+				Id:      chunk.ID,
+				Content: chunk.Content,
+				Done:    chunk.Done,
+			},
+		)
+		if err != nil {
+			return err
+		}
+		if chunk.Done {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *Server) ListModels(context.Context, *routerpb.ListModelsRequest) (*routerpb.ListModelsResponse, error) {
+	resp := &routerpb.ListModelsResponse{}
+	for _, info := range s.router.GetAvailableModels() {
+		resp.Models = append(resp.Models, fromModelInfo(info))
+	}
+	return resp, nil
+}
+
+func (s *Server) Health(context.Context, *routerpb.HealthRequest) (*routerpb.HealthResponse, error) {
+	return fromHealthStatus(s.router.GetHealth()), nil
+}
+
+// toStatus maps the errors of the router service to the gRPC codes matching the HTTP statuses of
+// the HTTP API
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, service.ErrTemplateNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrInvalidContent), errors.Is(err, service.ErrUnsupportedContent):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrCredentialsNotAllowed):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,185 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/rpc/routerpb"
+	"workspace-engine/internal/llm-router/service"
+	"workspace-engine/internal/llm-router/service/llm"
+	"workspace-engine/internal/llm-router/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// echoProvider answers with the prompt and streams it word by word
+type echoProvider struct {
+	params map[string]interface{}
+}
+
+func (p *echoProvider) Generate(
+	_ context.Context, prompt string, params map[string]interface{},
+) (*models.RouteResponse, error) {
+	p.params = params
+	return &models.RouteResponse{
+		ID:     "resp-1",
+		Result: prompt,
+		Model:  "echo",
+		Usage:  models.Usage{PromptTokens: 2, CompletionTokens: 2, TotalTokens: 4},
+	}, nil
+}
+
+func (p *echoProvider) GenerateStream(
+	context.Context, string, map[string]interface{},
+) (<-chan models.StreamResponse, error) {
+	stream := make(chan models.StreamResponse, 3)
+	stream <- models.StreamResponse{ID: "resp-1", Content: "hello "}
+	stream <- models.StreamResponse{ID: "resp-1", Content: "world"}
+	stream <- models.StreamResponse{ID: "resp-1", Done: true}
+	close(stream)
+	return stream, nil
+}
+
+func (p *echoProvider) GetModelInfo() models.ModelInfo {
+	return models.ModelInfo{ID: "echo", Capabilities: []string{"chat"}, MaxTokens: 4096}
+}
+
+func (p *echoProvider) IsHealthy() bool {
+	return true
+}
+
+func newTestClient(t *testing.T, provider llm.Provider) routerpb.RouterClient {
+	providers := map[string]llm.Provider{"openai_default": provider}
+	catalog := service.NewCatalogService(providers, nil, &config.Config{})
+	tenants := func() []config.TenantConfig {
+		return []config.TenantConfig{{Name: "acme", APIKeys: []config.Secret{"key-1"}}}
+	}
+	db, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	server := NewServer(
+		service.NewRouterService(providers, catalog, nil, nil), service.NewAPIKeyService(db, tenants), tenants,
+	)
+
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return routerpb.NewRouterClient(conn)
+}
+
+func TestServer(t *testing.T) {
+	provider := &echoProvider{}
+	client := newTestClient(t, provider)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), apiKeyMetadata, "key-1")
+
+	t.Run(
+		"Route", func(t *testing.T) {
+			params, err := structpb.NewStruct(map[string]interface{}{"temperature": 0.2})
+			require.NoError(t, err)
+
+			resp, err := client.Route(ctx, &routerpb.RouteRequest{Prompt: "hello", Parameters: params})
+			require.NoError(t, err)
+			assert.Equal(t, "hello", resp.GetResult())
+			assert.Equal(t, "echo", resp.GetModel())
+			assert.Equal(t, int32(4), resp.GetUsage().GetTotalTokens())
+			assert.Equal(t, 0.2, provider.params["temperature"])
+		},
+	)
+
+	t.Run(
+		"RequiresAPIKey", func(t *testing.T) {
+			_, err := client.Route(context.Background(), &routerpb.RouteRequest{Prompt: "hello"})
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		},
+	)
+
+	t.Run(
+		"RejectsUnknownAPIKey", func(t *testing.T) {
+			_, err := client.Route(
+				metadata.AppendToOutgoingContext(context.Background(), apiKeyMetadata, "unknown"),
+				&routerpb.RouteRequest{Prompt: "hello"},
+			)
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		},
+	)
+
+	t.Run(
+		"TenantMustBeBoundToTheAPIKey", func(t *testing.T) {
+			_, err := client.Route(
+				metadata.AppendToOutgoingContext(ctx, tenantMetadata, "acme"), &routerpb.RouteRequest{Prompt: "hello"},
+			)
+			require.NoError(t, err)
+
+			_, err = client.Route(
+				metadata.AppendToOutgoingContext(ctx, tenantMetadata, "globex"), &routerpb.RouteRequest{Prompt: "hello"},
+			)
+			assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		},
+	)
+
+	t.Run(
+		"InvalidContent", func(t *testing.T) {
+			_, err := client.Route(
+				ctx, &routerpb.RouteRequest{
+					Prompt:  "hello",
+					Content: []*routerpb.ContentPart{{Type: "image"}},
+				},
+			)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		},
+	)
+
+	t.Run(
+		"RouteStream", func(t *testing.T) {
+			stream, err := client.RouteStream(ctx, &routerpb.RouteRequest{Prompt: "hello"})
+			require.NoError(t, err)
+
+			var content string
+			for {
+				chunk, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				content += chunk.GetContent()
+			}
+			assert.Equal(t, "hello world", content)
+		},
+	)
+
+	t.Run(
+		"ListModels", func(t *testing.T) {
+			resp, err := client.ListModels(ctx, &routerpb.ListModelsRequest{})
+			require.NoError(t, err)
+			require.Len(t, resp.GetModels(), 1)
+			assert.Equal(t, "echo", resp.GetModels()[0].GetId())
+		},
+	)
+
+	t.Run(
+		"HealthWithoutAPIKey", func(t *testing.T) {
+			resp, err := client.Health(context.Background(), &routerpb.HealthRequest{})
+			require.NoError(t, err)
+			assert.Equal(t, "healthy", resp.GetStatus())
+		},
+	)
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service/llm"
+	"workspace-engine/internal/llm-router/store"
+	"workspace-engine/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAuditLimit = 100
+
+	auditActionUpdate = "provider.update"
+	auditActionProbe  = "provider.probe"
+
+	auditActionCredentialPolicy = "credentials.policy"
+	auditActionReload           = "config.reload"
+	auditActionAPIKeyCreate     = "apikey.create"
+	auditActionAPIKeyRevoke     = "apikey.revoke"
+
+	AuditActionTemplateCreate = "template.create"
+	AuditActionTemplateUpdate = "template.update"
+	AuditActionTemplateDelete = "template.delete"
+)
+
+// ProviderFactory creates a ready to use provider instance for a model of a vendor
+type ProviderFactory func(vendor, model string) (llm.Provider, error)
+
+// AdminService applies runtime changes to the provider instances of the router and keeps an
+// audit log of every change
+type AdminService struct {
+	db          *gorm.DB
+	providers   *ProviderRegistry
+	factory     ProviderFactory
+	credentials *CredentialService
+	keys        *APIKeyService
+}
+
+func NewAdminService(
+	db *gorm.DB, providers *ProviderRegistry, factory ProviderFactory, credentials *CredentialService,
+	keys *APIKeyService,
+) *AdminService {
+	return &AdminService{
+		db:          db,
+		providers:   providers,
+		factory:     factory,
+		credentials: credentials,
+		keys:        keys,
+	}
+}
+
+func (s *AdminService) ListProviders() []models.ProviderStatus {
+	return s.providers.List()
+}
+
+func (s *AdminService) GetProvider(key string) (models.ProviderStatus, error) {
+	return s.providers.Status(key)
+}
+
+// UpdateProvider changes the status, weight or default model of a provider instance
+func (s *AdminService) UpdateProvider(actor, key string, update models.ProviderUpdate) (models.ProviderStatus, error) {
+	if _, err := s.providers.Status(key); err != nil {
+		return models.ProviderStatus{}, err
+	}
+
+	// Validate the whole update first so it is either applied completely or not at all
+	vendor, instance, _ := strings.Cut(key, "_")
+	switch {
+	case update.Status != nil && !validProviderStatus(*update.Status):
+		return models.ProviderStatus{}, fmt.Errorf("unknown provider status: %s", *update.Status)
+	case update.Weight != nil && *update.Weight < 0:
+		return models.ProviderStatus{}, errors.New("weight must not be negative")
+	case update.Model != nil && instance != "default":
+		return models.ProviderStatus{}, errors.New("the model can only be changed on the default instance of a vendor")
+	case update.Model != nil && *update.Model == "":
+		return models.ProviderStatus{}, errors.New("model must not be empty")
+	}
+
+	change := ProviderChange{Status: update.Status, Weight: update.Weight}
+	if update.Model != nil {
+		provider, err := s.factory(vendor, *update.Model)
+		if err != nil {
+			return models.ProviderStatus{}, err
+		}
+		change.Provider = provider
+	}
+	if err := s.providers.Apply(key, change); err != nil {
+		return models.ProviderStatus{}, err
+	}
+
+	s.audit(actor, auditActionUpdate, key, update)
+	return s.providers.Status(key)
+}
+
+// ProbeProvider runs the health check of a provider instance
+func (s *AdminService) ProbeProvider(actor, key string) (models.ProviderStatus, error) {
+	status, err := s.providers.Probe(key)
+	if err != nil {
+		return status, err
+	}
+
+	s.audit(actor, auditActionProbe, key, map[string]bool{"healthy": status.Healthy})
+	return status, nil
+}
+
+// ListCredentialPolicies returns which API keys may send their own provider API keys
+func (s *AdminService) ListCredentialPolicies() ([]models.CredentialPolicy, error) {
+	return s.credentials.Policies()
+}
+
+// SetCredentialPolicy allows or denies provider API keys for an API key of the router. The audit
+// record names the API key by its hash only.
+func (s *AdminService) SetCredentialPolicy(
+	actor string, update models.CredentialPolicyUpdate,
+) (models.CredentialPolicy, error) {
+	policy, err := s.credentials.SetPolicy(update.APIKey, *update.Allowed)
+	if err != nil {
+		return models.CredentialPolicy{}, err
+	}
+
+	s.audit(actor, auditActionCredentialPolicy, policy.KeyHash, map[string]bool{"allowed": policy.Allowed})
+	return policy, nil
+}
+
+// ListAPIKeys returns the API keys issued by the router
+func (s *AdminService) ListAPIKeys() ([]models.APIKey, error) {
+	return s.keys.List()
+}
+
+// CreateAPIKey issues a new API key of the router. The audit record names the key by its id only.
+func (s *AdminService) CreateAPIKey(actor string, req models.APIKeyRequest) (*models.APIKey, error) {
+	key, err := s.keys.Create(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(actor, auditActionAPIKeyCreate, key.ID, map[string]string{"name": key.Name, "prefix": key.Prefix})
+	return key, nil
+}
+
+// RevokeAPIKey stops accepting an API key issued by the router
+func (s *AdminService) RevokeAPIKey(actor, id string) (*models.APIKey, error) {
+	key, err := s.keys.Revoke(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(actor, auditActionAPIKeyRevoke, key.ID, map[string]string{"name": key.Name})
+	return key, nil
+}
+
+// AuditReload records a configuration reload requested through the admin API
+func (s *AdminService) AuditReload(actor string, status models.ReloadStatus) {
+	s.audit(
+		actor, auditActionReload, "config", map[string]interface{}{
+			"status":     status.Status,
+			"generation": status.Generation,
+		},
+	)
+}
+
+// AuditTemplate records a change of a prompt template made through the admin API. Version 0 stands
+// for all versions of the template.
+func (s *AdminService) AuditTemplate(actor, action, name string, version int) {
+	s.audit(actor, action, name, map[string]int{"version": version})
+}
+
+// Audit returns the most recent admin changes, newest first
+func (s *AdminService) Audit(limit int) ([]models.AuditRecord, error) {
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+
+	var entities []store.AuditRecord
+	if err := s.db.Order("created_at desc, id desc").Limit(limit).Find(&entities).Error; err != nil {
+		return nil, fmt.Errorf("failed to list audit records: %w", err)
+	}
+
+	records := make([]models.AuditRecord, 0, len(entities))
+	for _, entity := range entities {
+		record := models.AuditRecord{
+			ID:        entity.ID,
+			Actor:     entity.Actor,
+			Action:    entity.Action,
+			Target:    entity.Target,
+			CreatedAt: entity.CreatedAt.UTC().Format(time.RFC3339),
+		}
+		if entity.Details != "" {
+			record.Details = json.RawMessage(entity.Details)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// audit stores an applied change. The change is already live, so a failure is only logged.
+func (s *AdminService) audit(actor, action, target string, details interface{}) {
+	record := store.AuditRecord{
+		Actor:  actor,
+		Action: action,
+		Target: target,
+	}
+	if body, err := json.Marshal(details); err == nil {
+		record.Details = string(body)
+	}
+
+	logger.Info("Admin change", "actor", actor, "action", action, "target", target, "details", record.Details)
+	if err := s.db.Create(&record).Error; err != nil {
+		logger.Error("Failed to store audit record", "action", action, "target", target, "error", err)
+	}
+}
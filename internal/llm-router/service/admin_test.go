@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service/llm"
+	"workspace-engine/internal/llm-router/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminService(t *testing.T) {
+	db, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+
+	providers := map[string]llm.Provider{
+		"anthropic_default": &scriptedProvider{model: "claude", results: []string{"claude"}},
+		"openai_default":    &scriptedProvider{model: "gpt-4", results: []string{"gpt-4"}},
+	}
+	router := NewRouterService(providers, NewCatalogService(providers, nil, &config.Config{}), nil, nil)
+	admin := NewAdminService(
+		db, router.Providers(), func(vendor, model string) (llm.Provider, error) {
+			if model == "unknown" {
+				return nil, errors.New("unknown model")
+			}
+			return &scriptedProvider{model: model, results: []string{model}}, nil
+		},
+		NewCredentialService(db, nil), nil,
+	)
+
+	status := func(value string) *string { return &value }
+	weight := func(value int) *int { return &value }
+
+	t.Run(
+		"DisabledProviderIsSkipped", func(t *testing.T) {
+			_, err := admin.UpdateProvider("ops", "anthropic_default", models.ProviderUpdate{Status: status(models.ProviderDisabled)})
+			require.NoError(t, err)
+
+			for i := 0; i < 5; i++ {
+				resp, err := router.Route(context.Background(), models.RouteRequest{Prompt: "hi", PreferredModel: "claude"})
+				require.NoError(t, err)
+				assert.Equal(t, "gpt-4", resp.Result)
+			}
+
+			openAI, err := admin.GetProvider("openai_default")
+			require.NoError(t, err)
+			assert.Equal(t, int64(5), openAI.Requests)
+			assert.Zero(t, openAI.InFlight)
+		},
+	)
+
+	t.Run(
+		"ZeroWeightIsNotRoutedAutomatically", func(t *testing.T) {
+			_, err := admin.UpdateProvider(
+				"ops", "anthropic_default", models.ProviderUpdate{Status: status(models.ProviderEnabled), Weight: weight(0)},
+			)
+			require.NoError(t, err)
+
+			resp, err := router.Route(context.Background(), models.RouteRequest{Prompt: "hi"})
+			require.NoError(t, err)
+			assert.Equal(t, "gpt-4", resp.Result)
+		},
+	)
+
+	t.Run(
+		"DrainWithoutRequestsDisables", func(t *testing.T) {
+			updated, err := admin.UpdateProvider("ops", "anthropic_default", models.ProviderUpdate{Status: status(models.ProviderDraining)})
+			require.NoError(t, err)
+			assert.Equal(t, models.ProviderDisabled, updated.Status)
+		},
+	)
+
+	t.Run(
+		"DrainWaitsForRequestsInFlight", func(t *testing.T) {
+			done := router.Providers().Track("openai_default")
+			updated, err := admin.UpdateProvider("ops", "openai_default", models.ProviderUpdate{Status: status(models.ProviderDraining)})
+			require.NoError(t, err)
+			assert.Equal(t, models.ProviderDraining, updated.Status)
+
+			done(nil)
+			updated, err = admin.GetProvider("openai_default")
+			require.NoError(t, err)
+			assert.Equal(t, models.ProviderDisabled, updated.Status)
+		},
+	)
+
+	t.Run(
+		"DrainRacingTheLastRequest", func(t *testing.T) {
+			registry := router.Providers()
+			for i := 0; i < 200; i++ {
+				require.NoError(t, registry.SetStatus("anthropic_default", models.ProviderEnabled))
+				done := registry.Track("anthropic_default")
+				finished := make(chan struct{})
+				go func() {
+					done(nil)
+					close(finished)
+				}()
+				require.NoError(t, registry.SetStatus("anthropic_default", models.ProviderDraining))
+				<-finished
+
+				drained, err := registry.Status("anthropic_default")
+				require.NoError(t, err)
+				require.Equal(t, models.ProviderDisabled, drained.Status)
+			}
+		},
+	)
+
+	t.Run(
+		"ChangesDefaultModel", func(t *testing.T) {
+			model := "gpt-4o"
+			updated, err := admin.UpdateProvider(
+				"ops", "openai_default", models.ProviderUpdate{Model: &model, Status: status(models.ProviderEnabled)},
+			)
+			require.NoError(t, err)
+			assert.Equal(t, "gpt-4o", updated.Model)
+
+			resp, err := router.Route(context.Background(), models.RouteRequest{Prompt: "hi", PreferredModel: "gpt-4o"})
+			require.NoError(t, err)
+			assert.Equal(t, "gpt-4o", resp.Result)
+		},
+	)
+
+	t.Run(
+		"RejectsInvalidUpdates", func(t *testing.T) {
+			_, err := admin.UpdateProvider("ops", "openai_default", models.ProviderUpdate{Weight: weight(-1)})
+			assert.Error(t, err)
+
+			_, err = admin.UpdateProvider("ops", "missing", models.ProviderUpdate{Weight: weight(1)})
+			assert.ErrorIs(t, err, ErrProviderNotFound)
+		},
+	)
+
+	t.Run(
+		"FailedUpdatesChangeNothing", func(t *testing.T) {
+			model := "unknown"
+			_, err := admin.UpdateProvider(
+				"ops", "openai_default",
+				models.ProviderUpdate{Model: &model, Weight: weight(7), Status: status(models.ProviderDisabled)},
+			)
+			require.Error(t, err)
+
+			unchanged, err := admin.GetProvider("openai_default")
+			require.NoError(t, err)
+			assert.Equal(t, "gpt-4o", unchanged.Model)
+			assert.Equal(t, models.ProviderEnabled, unchanged.Status)
+			assert.Equal(t, defaultProviderWeight, unchanged.Weight)
+		},
+	)
+
+	t.Run(
+		"AuditsChanges", func(t *testing.T) {
+			// A fixture of its own, so only the changes of this test are in the audit log
+			db, err := store.Open(t.TempDir())
+			require.NoError(t, err)
+			providers := map[string]llm.Provider{"openai_default": &scriptedProvider{model: "gpt-4"}}
+			router := NewRouterService(providers, NewCatalogService(providers, nil, &config.Config{}), nil, nil)
+			admin := NewAdminService(db, router.Providers(), nil, NewCredentialService(db, nil), nil)
+
+			_, err = admin.UpdateProvider("deploy", "openai_default", models.ProviderUpdate{Weight: weight(2)})
+			require.NoError(t, err)
+			_, err = admin.ProbeProvider("ops", "openai_default")
+			require.NoError(t, err)
+
+			records, err := admin.Audit(0)
+			require.NoError(t, err)
+			require.Len(t, records, 2)
+			assert.Equal(t, "provider.update", records[1].Action)
+			assert.Equal(t, "deploy", records[1].Actor)
+			assert.Equal(t, "provider.probe", records[0].Action)
+			assert.Equal(t, "ops", records[0].Actor)
+			assert.JSONEq(t, `{"healthy": true}`, string(records[0].Details))
+		},
+	)
+}
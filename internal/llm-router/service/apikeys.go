@@ -0,0 +1,142 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/store"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// apiKeyPrefix marks the API keys issued by the router, so leaked keys are easy to recognize
+	apiKeyPrefix = "rk_"
+	apiKeyBytes  = 32
+	// apiKeyShownLength is the length of the start of a key kept to tell keys apart in listings
+	apiKeyShownLength = len(apiKeyPrefix) + 8
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// APIKeyService issues and checks the API keys of the router. A key is accepted when it was issued
+// by the router and not revoked, or when it is bound to a tenant in the configuration. Only the
+// hash of an issued key is stored, the key itself is returned once when it is created.
+type APIKeyService struct {
+	db      *gorm.DB
+	tenants func() []config.TenantConfig
+}
+
+// NewAPIKeyService creates the service. The tenants are looked up on every check, so the keys
+// bound to tenants follow configuration reloads.
+func NewAPIKeyService(db *gorm.DB, tenants func() []config.TenantConfig) *APIKeyService {
+	return &APIKeyService{
+		db:      db,
+		tenants: tenants,
+	}
+}
+
+// Create issues a new API key. The returned key is the only place the key itself appears.
+func (s *APIKeyService) Create(name string) (*models.APIKey, error) {
+	if name == "" {
+		return nil, errors.New("API key name is required")
+	}
+
+	secret := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+
+	entity := store.APIKey{
+		ID:      uuid.New().String(),
+		Name:    name,
+		KeyHash: hashAPIKey(key),
+		Prefix:  key[:apiKeyShownLength],
+	}
+	if err := s.db.Create(&entity).Error; err != nil {
+		return nil, fmt.Errorf("failed to store API key: %w", err)
+	}
+
+	created := toAPIKeyModel(entity)
+	created.Key = key
+	return created, nil
+}
+
+// List returns the issued API keys including the revoked ones, newest first
+func (s *APIKeyService) List() ([]models.APIKey, error) {
+	var entities []store.APIKey
+	if err := s.db.Order("created_at desc").Find(&entities).Error; err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	keys := make([]models.APIKey, 0, len(entities))
+	for _, entity := range entities {
+		keys = append(keys, *toAPIKeyModel(entity))
+	}
+	return keys, nil
+}
+
+// Revoke stops accepting an issued API key. The key stays listed with the time it was revoked.
+func (s *APIKeyService) Revoke(id string) (*models.APIKey, error) {
+	now := time.Now()
+	result := s.db.Model(&store.APIKey{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", &now)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to revoke API key: %w", result.Error)
+	}
+
+	var entity store.APIKey
+	err := s.db.Where("id = ?", id).First(&entity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load API key: %w", err)
+	}
+	return toAPIKeyModel(entity), nil
+}
+
+// Validate returns ErrInvalidAPIKey unless the key is accepted by the router
+func (s *APIKeyService) Validate(apiKey string) error {
+	for _, tenant := range s.tenants() {
+		for _, key := range tenant.APIKeys {
+			if subtle.ConstantTimeCompare([]byte(apiKey), []byte(key.Value())) == 1 {
+				return nil
+			}
+		}
+	}
+
+	var count int64
+	err := s.db.Model(&store.APIKey{}).Where("key_hash = ? AND revoked_at IS NULL", hashAPIKey(apiKey)).
+		Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to check API key: %w", err)
+	}
+	if count == 0 {
+		return ErrInvalidAPIKey
+	}
+	return nil
+}
+
+func toAPIKeyModel(entity store.APIKey) *models.APIKey {
+	key := &models.APIKey{
+		ID:        entity.ID,
+		Name:      entity.Name,
+		Prefix:    entity.Prefix,
+		CreatedAt: entity.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if entity.RevokedAt != nil {
+		key.RevokedAt = entity.RevokedAt.UTC().Format(time.RFC3339)
+	}
+	return key
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyService(t *testing.T) {
+	db, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+
+	tenants := []config.TenantConfig{{Name: "acme", APIKeys: []config.Secret{"acme-key"}}}
+	keys := NewAPIKeyService(db, func() []config.TenantConfig { return tenants })
+
+	t.Run(
+		"IssuedKeysAreAccepted", func(t *testing.T) {
+			created, err := keys.Create("ci")
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(created.Key, created.Prefix))
+			require.NoError(t, keys.Validate(created.Key))
+
+			listed, err := keys.List()
+			require.NoError(t, err)
+			require.NotEmpty(t, listed)
+			assert.Equal(t, created.ID, listed[0].ID)
+			assert.Empty(t, listed[0].Key, "the key is only returned when it is created")
+
+			var stored store.APIKey
+			require.NoError(t, db.First(&stored, "id = ?", created.ID).Error)
+			assert.NotContains(t, stored.KeyHash, created.Key)
+		},
+	)
+
+	t.Run(
+		"RevokedKeysAreRejected", func(t *testing.T) {
+			created, err := keys.Create("temporary")
+			require.NoError(t, err)
+
+			revoked, err := keys.Revoke(created.ID)
+			require.NoError(t, err)
+			assert.NotEmpty(t, revoked.RevokedAt)
+			assert.ErrorIs(t, keys.Validate(created.Key), ErrInvalidAPIKey)
+
+			_, err = keys.Revoke("missing")
+			assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+		},
+	)
+
+	t.Run(
+		"TenantKeysAreAccepted", func(t *testing.T) {
+			require.NoError(t, keys.Validate("acme-key"))
+			assert.ErrorIs(t, keys.Validate("unknown"), ErrInvalidAPIKey)
+			assert.ErrorIs(t, keys.Validate(""), ErrInvalidAPIKey)
+		},
+	)
+}
@@ -0,0 +1,475 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/store"
+	"workspace-engine/pkg/logger"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultBatchConcurrency    = 4
+	defaultBatchWebhookTimeout = 10 * time.Second
+	maxBatchLineSize           = 1024 * 1024
+	webhookAttempts            = 3
+
+	batchItemPending   = "pending"
+	batchItemCompleted = "completed"
+	batchItemFailed    = "failed"
+)
+
+var ErrBatchNotFound = errors.New("batch not found")
+
+// unfinishedBatchStatuses are the states a job can still leave
+var unfinishedBatchStatuses = []string{models.BatchStatusQueued, models.BatchStatusRunning}
+
+// BatchService runs uploaded batches of route requests in the background. Every processed line is
+// persisted immediately, so jobs interrupted by a restart resume where they stopped. Every job
+// belongs to the API key that created it, and its lines are routed as requests of that key and
+// its tenant.
+type BatchService struct {
+	db        *gorm.DB
+	router    *RouterService
+	client    *http.Client
+	callbacks callbackPolicy
+
+	// slots bounds the number of lines processed at the same time across all jobs
+	slots chan struct{}
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+}
+
+func NewBatchService(db *gorm.DB, router *RouterService, cfg config.BatchConfig) *BatchService {
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	timeout := cfg.WebhookTimeout
+	if timeout <= 0 {
+		timeout = defaultBatchWebhookTimeout
+	}
+
+	return &BatchService{
+		db:        db,
+		router:    router,
+		client:    newWebhookClient(timeout),
+		callbacks: callbackPolicy{hosts: cfg.CallbackHosts},
+		slots:     make(chan struct{}, concurrency),
+		running:   make(map[string]context.CancelFunc),
+	}
+}
+
+// Resume restarts all jobs that were queued or running when the router stopped
+func (s *BatchService) Resume() error {
+	var jobs []store.BatchJob
+	err := s.db.Where("status IN ?", unfinishedBatchStatuses).
+		Order("created_at").Find(&jobs).Error
+	if err != nil {
+		return fmt.Errorf("failed to load unfinished batches: %w", err)
+	}
+
+	for _, job := range jobs {
+		logger.Info("Resuming batch", "batch_id", job.ID)
+		s.start(job.ID)
+	}
+	return nil
+}
+
+// Create parses a JSONL upload, stores it as a new job of the caller and starts processing it. The
+// callback URL is optional and must be an https URL of an allowed host.
+func (s *BatchService) Create(caller Caller, body io.Reader, callbackURL string) (*models.BatchJob, error) {
+	if callbackURL != "" {
+		if err := s.callbacks.check(callbackURL); err != nil {
+			return nil, err
+		}
+	}
+
+	var items []store.BatchItem
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBatchLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var batchLine models.BatchLine
+		if err := json.Unmarshal([]byte(text), &batchLine); err != nil {
+			return nil, fmt.Errorf("invalid batch line %d: %w", line, err)
+		}
+		if batchLine.Request.Prompt == "" && batchLine.Request.Template == nil &&
+			len(batchLine.Request.Content) == 0 {
+			return nil, fmt.Errorf("invalid batch line %d: request prompt is required", line)
+		}
+
+		request, err := json.Marshal(batchLine.Request)
+		if err != nil {
+			return nil, fmt.Errorf("invalid batch line %d: %w", line, err)
+		}
+		items = append(
+			items, store.BatchItem{
+				Line:     line,
+				CustomID: batchLine.CustomID,
+				Request:  string(request),
+				Status:   batchItemPending,
+			},
+		)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read batch: %w", err)
+	}
+	if len(items) == 0 {
+		return nil, errors.New("batch is empty")
+	}
+
+	job := store.BatchJob{
+		ID:          uuid.New().String(),
+		Owner:       caller.owner(),
+		Tenant:      caller.Tenant,
+		Status:      models.BatchStatusQueued,
+		CallbackURL: callbackURL,
+		Total:       len(items),
+	}
+	for i := range items {
+		items[i].JobID = job.ID
+	}
+
+	err := s.db.Transaction(
+		func(tx *gorm.DB) error {
+			if err := tx.Create(&job).Error; err != nil {
+				return err
+			}
+			return tx.CreateInBatches(items, 100).Error
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save batch: %w", err)
+	}
+
+	s.start(job.ID)
+	return toBatchModel(job), nil
+}
+
+func (s *BatchService) Get(apiKey, id string) (*models.BatchJob, error) {
+	job, err := s.load(apiKey, id)
+	if err != nil {
+		return nil, err
+	}
+	return toBatchModel(*job), nil
+}
+
+// List returns the jobs of an API key, newest first
+func (s *BatchService) List(apiKey string) ([]models.BatchJob, error) {
+	var jobs []store.BatchJob
+	if err := s.db.Where("owner = ?", hashAPIKey(apiKey)).Order("created_at desc").Find(&jobs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list batches: %w", err)
+	}
+
+	result := make([]models.BatchJob, 0, len(jobs))
+	for _, job := range jobs {
+		result = append(result, *toBatchModel(job))
+	}
+	return result, nil
+}
+
+// Cancel stops a job. Lines that were already processed keep their results.
+func (s *BatchService) Cancel(apiKey, id string) (*models.BatchJob, error) {
+	job, err := s.load(apiKey, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.BatchStatusQueued && job.Status != models.BatchStatusRunning {
+		return nil, fmt.Errorf("batch is already %s", job.Status)
+	}
+
+	s.mu.Lock()
+	if cancel, ok := s.running[id]; ok {
+		cancel()
+	}
+	s.mu.Unlock()
+
+	finished, err := s.finish(job, models.BatchStatusCancelled, "")
+	if err != nil {
+		return nil, err
+	}
+	if !finished {
+		// The job finished on its own in the meantime
+		if job, err = s.find(id); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("batch is already %s", job.Status)
+	}
+	return toBatchModel(*job), nil
+}
+
+// WriteResults writes the processed lines of a job as JSONL, in upload order
+func (s *BatchService) WriteResults(apiKey, id string, w io.Writer) error {
+	if _, err := s.load(apiKey, id); err != nil {
+		return err
+	}
+
+	rows, err := s.db.Model(&store.BatchItem{}).
+		Where("job_id = ? AND status <> ?", id, batchItemPending).Order("line").Rows()
+	if err != nil {
+		return fmt.Errorf("failed to load batch results: %w", err)
+	}
+	defer rows.Close()
+
+	encoder := json.NewEncoder(w)
+	for rows.Next() {
+		var item store.BatchItem
+		if err := s.db.ScanRows(rows, &item); err != nil {
+			return fmt.Errorf("failed to load batch results: %w", err)
+		}
+
+		result := models.BatchResult{
+			Line:     item.Line,
+			CustomID: item.CustomID,
+			Status:   item.Status,
+			Error:    item.Error,
+		}
+		if item.Response != "" {
+			var resp models.RouteResponse
+			if err := json.Unmarshal([]byte(item.Response), &resp); err == nil {
+				result.Response = &resp
+				result.Usage = resp.Usage
+			}
+		}
+
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *BatchService) start(id string) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.mu.Lock()
+	s.running[id] = cancel
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.running, id)
+			s.mu.Unlock()
+			cancel()
+		}()
+
+		if err := s.run(ctx, id); err != nil {
+			logger.Error("Batch failed", "batch_id", id, "error", err)
+		}
+	}()
+}
+
+func (s *BatchService) run(ctx context.Context, id string) error {
+	job, err := s.find(id)
+	if err != nil {
+		return err
+	}
+
+	started := s.db.Model(&store.BatchJob{}).Where("id = ? AND status IN ?", id, unfinishedBatchStatuses).
+		Update("status", models.BatchStatusRunning)
+	if started.Error != nil {
+		return fmt.Errorf("failed to update batch: %w", started.Error)
+	}
+	if started.RowsAffected == 0 {
+		// Cancelled before it started
+		return nil
+	}
+
+	var items []store.BatchItem
+	if err := s.db.Where("job_id = ? AND status = ?", id, batchItemPending).Order("line").Find(&items).Error; err != nil {
+		_, _ = s.finish(job, models.BatchStatusFailed, err.Error())
+		return fmt.Errorf("failed to load batch items: %w", err)
+	}
+
+	// The lines are routed for the caller that submitted the job. Only the hash of its API key is
+	// stored, and neither its headers nor provider keys, so rules matching on those do not apply.
+	ctx = WithCaller(ctx, Caller{KeyHash: job.Owner, Tenant: job.Tenant})
+
+	var wg sync.WaitGroup
+dispatch:
+	for i := range items {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case s.slots <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(item *store.BatchItem) {
+			defer func() {
+				<-s.slots
+				wg.Done()
+			}()
+			s.process(ctx, item)
+		}(&items[i])
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		// Cancelled, the job state has already been stored
+		return nil
+	}
+
+	job, err = s.find(id)
+	if err != nil {
+		return err
+	}
+	_, err = s.finish(job, models.BatchStatusCompleted, "")
+	return err
+}
+
+func (s *BatchService) process(ctx context.Context, item *store.BatchItem) {
+	var req models.RouteRequest
+	resp, err := func() (*models.RouteResponse, error) {
+		if err := json.Unmarshal([]byte(item.Request), &req); err != nil {
+			return nil, fmt.Errorf("invalid request: %w", err)
+		}
+		return s.router.Route(ctx, req)
+	}()
+
+	// A line interrupted by cancellation stays pending so it is not reported as failed
+	if ctx.Err() != nil {
+		return
+	}
+
+	counter := "completed"
+	item.Status = batchItemCompleted
+	if err != nil {
+		counter = "failed"
+		item.Status = batchItemFailed
+		item.Error = err.Error()
+	} else if body, err := json.Marshal(resp); err == nil {
+		item.Response = string(body)
+	}
+
+	err = s.db.Transaction(
+		func(tx *gorm.DB) error {
+			if err := tx.Save(item).Error; err != nil {
+				return err
+			}
+			return tx.Model(&store.BatchJob{}).Where("id = ?", item.JobID).
+				Update(counter, gorm.Expr(counter+" + 1")).Error
+		},
+	)
+	if err != nil {
+		logger.Error("Failed to store batch result", "batch_id", item.JobID, "line", item.Line, "error", err)
+	}
+}
+
+// finish moves an unfinished job to a final status and reports whether it did. A job that has
+// already finished keeps its status, so a job cancelled while its last line runs is not marked
+// completed afterwards.
+func (s *BatchService) finish(job *store.BatchJob, status, message string) (bool, error) {
+	now := time.Now()
+	result := s.db.Model(&store.BatchJob{}).Where("id = ? AND status IN ?", job.ID, unfinishedBatchStatuses).Updates(
+		map[string]interface{}{
+			"status":      status,
+			"error":       message,
+			"finished_at": &now,
+		},
+	)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to update batch: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	job.Status = status
+	job.Error = message
+	job.FinishedAt = &now
+	if job.CallbackURL != "" {
+		go s.notify(*job)
+	}
+	return true, nil
+}
+
+// notify posts the final job state to the callback URL of the job
+func (s *BatchService) notify(job store.BatchJob) {
+	body, err := json.Marshal(toBatchModel(job))
+	if err != nil {
+		return
+	}
+
+	for attempt := 0; attempt < webhookAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+
+		resp, err := s.client.Post(job.CallbackURL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			logger.Warn("Batch webhook failed", "batch_id", job.ID, "attempt", attempt+1, "error", err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 300 {
+			return
+		}
+		logger.Warn(
+			"Batch webhook failed", "batch_id", job.ID, "attempt", attempt+1, "status", resp.StatusCode,
+		)
+	}
+}
+
+// load returns a job of the API key, jobs of other keys are reported as not found
+func (s *BatchService) load(apiKey, id string) (*store.BatchJob, error) {
+	return s.first(s.db.Where("id = ? AND owner = ?", id, hashAPIKey(apiKey)))
+}
+
+// find returns a job of any API key, for processing it in the background
+func (s *BatchService) find(id string) (*store.BatchJob, error) {
+	return s.first(s.db.Where("id = ?", id))
+}
+
+func (s *BatchService) first(query *gorm.DB) (*store.BatchJob, error) {
+	var job store.BatchJob
+	err := query.First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBatchNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load batch: %w", err)
+	}
+	return &job, nil
+}
+
+func toBatchModel(job store.BatchJob) *models.BatchJob {
+	batch := &models.BatchJob{
+		ID:          job.ID,
+		Status:      job.Status,
+		CallbackURL: job.CallbackURL,
+		Total:       job.Total,
+		Completed:   job.Completed,
+		Failed:      job.Failed,
+		Error:       job.Error,
+		CreatedAt:   job.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if job.FinishedAt != nil {
+		batch.FinishedAt = job.FinishedAt.UTC().Format(time.RFC3339)
+	}
+	return batch
+}
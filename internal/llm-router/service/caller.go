@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"workspace-engine/internal/llm-router/config"
+)
+
+var ErrTenantNotAllowed = errors.New("tenant not allowed")
+
+// Caller describes who sent a request. It is attached to the request context by the API layer
+// and read by the routing rules.
+type Caller struct {
+	APIKey string
+	// KeyHash stands in for the API key of callers rebuilt from the database, which only stores
+	// the hash of the key
+	KeyHash string
+	Tenant  string
+	Headers http.Header
+	// Credentials are the provider API keys sent with the request, by vendor. They are only used
+	// for the request and never logged or stored.
+	Credentials map[string]string
+}
+
+type callerKey struct{}
+
+// WithCaller returns a copy of the context carrying the caller
+func WithCaller(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the caller of the request, or an empty caller when there is none
+func CallerFromContext(ctx context.Context) Caller {
+	caller, _ := ctx.Value(callerKey{}).(Caller)
+	return caller
+}
+
+// owner returns the hash of the caller's API key, which is how the key is stored in the database
+func (c Caller) owner() string {
+	if c.KeyHash != "" {
+		return c.KeyHash
+	}
+	return hashAPIKey(c.APIKey)
+}
+
+// ParseCredentials parses provider API keys in the form vendor=key. A value may hold several
+// comma separated keys. It returns nil when there are none.
+func ParseCredentials(values []string) (map[string]string, error) {
+	var credentials map[string]string
+	for _, value := range values {
+		for _, entry := range strings.Split(value, ",") {
+			vendor, key, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			vendor = strings.ToLower(strings.TrimSpace(vendor))
+			key = strings.TrimSpace(key)
+			if !ok || vendor == "" || key == "" {
+				// The entry is not echoed, it may hold a key
+				return nil, fmt.Errorf("%w: expected vendor=key", ErrInvalidCredentials)
+			}
+			if credentials == nil {
+				credentials = map[string]string{}
+			}
+			credentials[vendor] = key
+		}
+	}
+	return credentials, nil
+}
+
+// ResolveTenant returns the tenant a request is sent on behalf of. The tenant comes from the API
+// key: a requested tenant must be bound to the key, without one the only tenant of the key is
+// used. Keys bound to no tenant or to several send requests without a tenant.
+func ResolveTenant(tenants []config.TenantConfig, apiKey, requested string) (string, error) {
+	var bound []string
+	for _, tenant := range tenants {
+		for _, key := range tenant.APIKeys {
+			if subtle.ConstantTimeCompare([]byte(apiKey), []byte(key.Value())) == 1 {
+				bound = append(bound, tenant.Name)
+				break
+			}
+		}
+	}
+
+	switch {
+	case requested != "":
+		for _, name := range bound {
+			if name == requested {
+				return name, nil
+			}
+		}
+		return "", fmt.Errorf("%w: the API key is not bound to tenant %s", ErrTenantNotAllowed, requested)
+	case len(bound) == 1:
+		return bound[0], nil
+	default:
+		return "", nil
+	}
+}
+
+// hashAPIKey hashes an API key, so keys are not stored in the database
+func hashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
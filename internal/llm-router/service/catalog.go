@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service/llm"
+	"workspace-engine/pkg/logger"
+)
+
+const (
+	defaultCatalogRefreshInterval = time.Hour
+	catalogDiscoveryTimeout       = 15 * time.Second
+)
+
+// CatalogService keeps one entry per configured model. Entries start from what the provider
+// reports about itself, are updated with what the vendor models endpoint lists and finally with
+// the overrides from the configuration.
+type CatalogService struct {
+	providers map[string]llm.Provider
+	listers   map[string]llm.ModelLister
+	cfg       *config.Config
+	interval  time.Duration
+
+	mu         sync.RWMutex
+	entries    []catalogEntry
+	aliases    map[string]int
+	discovered map[string]map[string]models.ModelInfo
+}
+
+type catalogEntry struct {
+	info        models.ModelInfo
+	providerKey string
+}
+
+// NewCatalogService builds the catalog from the configured providers. The listers are keyed by
+// vendor name (openai, anthropic, ...) and are used to discover the models of that vendor.
+func NewCatalogService(
+	providers map[string]llm.Provider, listers map[string]llm.ModelLister, cfg *config.Config,
+) *CatalogService {
+	interval := cfg.Catalog.RefreshInterval
+	if interval <= 0 {
+		interval = defaultCatalogRefreshInterval
+	}
+
+	s := &CatalogService{
+		providers:  providers,
+		listers:    listers,
+		cfg:        cfg,
+		interval:   interval,
+		discovered: make(map[string]map[string]models.ModelInfo),
+	}
+	s.rebuild()
+	return s
+}
+
+// Start discovers the vendor models in the background and refreshes them periodically until the
+// context is cancelled
+func (s *CatalogService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.Refresh(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Refresh fetches the model lists of all vendors and rebuilds the catalog. A vendor whose list
+// cannot be fetched keeps the result of its last successful discovery.
+func (s *CatalogService) Refresh(ctx context.Context) {
+	s.mu.RLock()
+	listers := s.listers
+	s.mu.RUnlock()
+
+	for vendor, lister := range listers {
+		listCtx, cancel := context.WithTimeout(ctx, catalogDiscoveryTimeout)
+		list, err := lister.ListModels(listCtx)
+		cancel()
+		if err != nil {
+			logger.WarnContext(ctx, "Failed to discover models", "provider", vendor, "error", err)
+			continue
+		}
+
+		found := make(map[string]models.ModelInfo, len(list))
+		for _, info := range list {
+			found[info.ID] = info
+		}
+
+		s.mu.Lock()
+		s.discovered[vendor] = found
+		s.mu.Unlock()
+	}
+
+	s.rebuild()
+}
+
+// Models returns the catalog entries ordered by provider and model
+func (s *CatalogService) Models() []models.ModelInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	infos := make([]models.ModelInfo, 0, len(s.entries))
+	for _, entry := range s.entries {
+		infos = append(infos, entry.info)
+	}
+	return infos
+}
+
+// Resolve looks a model up by id, alias or provider key
+func (s *CatalogService) Resolve(name string) (models.ModelInfo, string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	index, ok := s.aliases[name]
+	if !ok {
+		return models.ModelInfo{}, "", false
+	}
+	entry := s.entries[index]
+	return entry.info, entry.providerKey, true
+}
+
+// ProviderKeys returns the provider keys of all available models in catalog order
+func (s *CatalogService) ProviderKeys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.entries))
+	for _, entry := range s.entries {
+		if entry.info.Available {
+			keys = append(keys, entry.providerKey)
+		}
+	}
+	return keys
+}
+
+// SetProviders replaces the provider instances of the catalog, e.g. after the default model of a
+// vendor changed
+func (s *CatalogService) SetProviders(providers map[string]llm.Provider) {
+	s.mu.Lock()
+	s.providers = providers
+	s.mu.Unlock()
+
+	s.rebuild()
+}
+
+// Reload replaces the provider instances, the model listers and the overrides of the catalog after
+// the configuration changed. Vendors without a previous discovery are discovered on the next
+// refresh, the refresh interval is not changed.
+func (s *CatalogService) Reload(
+	providers map[string]llm.Provider, listers map[string]llm.ModelLister, cfg *config.Config,
+) {
+	s.mu.Lock()
+	s.providers = providers
+	s.listers = listers
+	s.cfg = cfg
+	s.mu.Unlock()
+
+	s.rebuild()
+}
+
+func (s *CatalogService) rebuild() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.providers))
+	for key := range s.providers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var entries []catalogEntry
+	byModel := make(map[string]int)
+	aliasSets := make([]map[string]bool, 0)
+
+	// Several provider keys (e.g. openai_default and openai_gpt-4) can serve the same model,
+	// they become aliases of a single entry
+	for _, key := range keys {
+		vendor := providerVendor(key)
+		info := s.providers[key].GetModelInfo()
+		id := vendor + "/" + info.ID
+
+		if index, ok := byModel[id]; ok {
+			aliasSets[index][key] = true
+			continue
+		}
+
+		byModel[id] = len(entries)
+		entries = append(entries, catalogEntry{info: s.merge(vendor, info), providerKey: key})
+		aliasSets = append(aliasSets, map[string]bool{key: true, id: true})
+	}
+
+	aliases := make(map[string]int)
+	for index := range entries {
+		info := &entries[index].info
+		for _, alias := range info.Aliases {
+			aliasSets[index][alias] = true
+		}
+
+		info.Aliases = info.Aliases[:0]
+		for alias := range aliasSets[index] {
+			if alias != info.ID {
+				info.Aliases = append(info.Aliases, alias)
+			}
+		}
+		sort.Strings(info.Aliases)
+
+		// Model ids that are served by several vendors resolve to the first one
+		if _, ok := aliases[info.ID]; !ok {
+			aliases[info.ID] = index
+		}
+		for _, alias := range info.Aliases {
+			if _, ok := aliases[alias]; !ok {
+				aliases[alias] = index
+			}
+		}
+	}
+
+	s.entries = entries
+	s.aliases = aliases
+}
+
+// merge applies the discovered model data and the configured overrides to the provider defaults
+func (s *CatalogService) merge(vendor string, info models.ModelInfo) models.ModelInfo {
+	info.Provider = vendor
+	info.Available = true
+	info.Capabilities = append([]string(nil), info.Capabilities...)
+	info.Aliases = nil
+
+	if found, ok := s.discovered[vendor]; ok {
+		discovered, listed := found[info.ID]
+		info.Available = listed
+		if discovered.Name != "" {
+			info.Name = discovered.Name
+		}
+		if discovered.MaxTokens > 0 {
+			info.MaxTokens = discovered.MaxTokens
+		}
+		if discovered.Pricing.InputPrice > 0 || discovered.Pricing.OutputPrice > 0 {
+			info.Pricing = discovered.Pricing
+		}
+	}
+
+	override, err := s.cfg.GetModelConfig(vendor, info.ID)
+	if err != nil {
+		return info
+	}
+	if override.MaxTokens > 0 {
+		info.MaxTokens = override.MaxTokens
+	}
+	if len(override.Capabilities) > 0 {
+		info.Capabilities = append([]string(nil), override.Capabilities...)
+	}
+	if override.InputPrice > 0 {
+		info.Pricing.InputPrice = override.InputPrice
+	}
+	if override.OutputPrice > 0 {
+		info.Pricing.OutputPrice = override.OutputPrice
+	}
+	if override.Currency != "" {
+		info.Pricing.Currency = override.Currency
+	}
+	info.Aliases = append(info.Aliases, override.Aliases...)
+
+	return info
+}
+
+// providerVendor returns the vendor part of a provider key such as openai_gpt-4
+func providerVendor(key string) string {
+	vendor, _, _ := strings.Cut(key, "_")
+	return vendor
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service/llm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticLister []models.ModelInfo
+
+func (l staticLister) ListModels(context.Context) ([]models.ModelInfo, error) {
+	return l, nil
+}
+
+func TestCatalogService(t *testing.T) {
+	gpt4 := &scriptedProvider{model: "gpt-4", results: []string{"gpt-4"}}
+	providers := map[string]llm.Provider{
+		"openai_default": gpt4,
+		"openai_gpt-4":   gpt4,
+		"openai_gpt-old": &scriptedProvider{model: "gpt-old", results: []string{"gpt-old"}},
+	}
+	listers := map[string]llm.ModelLister{
+		"openai": staticLister{{ID: "gpt-4", MaxTokens: 8192, Pricing: models.Pricing{InputPrice: 0.01, OutputPrice: 0.02}}},
+	}
+	cfg := &config.Config{
+		Providers: config.ProvidersConfig{
+			OpenAI: config.ProviderConfig{
+				Models: []config.ModelConfig{
+					{Name: "gpt-4", Aliases: []string{"smart"}, Capabilities: []string{"chat", "vision"}, OutputPrice: 0.06},
+				},
+			},
+		},
+	}
+
+	catalog := NewCatalogService(providers, listers, cfg)
+	require.Len(t, catalog.Models(), 2, "provider keys of the same model are deduplicated")
+
+	catalog.Refresh(context.Background())
+	modelsByID := map[string]models.ModelInfo{}
+	for _, info := range catalog.Models() {
+		modelsByID[info.ID] = info
+	}
+
+	info := modelsByID["gpt-4"]
+	assert.True(t, info.Available)
+	assert.Equal(t, "openai", info.Provider)
+	assert.Equal(t, 8192, info.MaxTokens)
+	assert.Equal(t, []string{"chat", "vision"}, info.Capabilities)
+	assert.Equal(t, 0.01, info.Pricing.InputPrice)
+	assert.Equal(t, 0.06, info.Pricing.OutputPrice, "configured prices replace discovered ones")
+	assert.Equal(t, []string{"openai/gpt-4", "openai_default", "openai_gpt-4", "smart"}, info.Aliases)
+
+	assert.False(t, modelsByID["gpt-old"].Available, "models the vendor does not list are unavailable")
+	assert.Equal(t, []string{"openai_default"}, catalog.ProviderKeys())
+
+	for _, name := range []string{"gpt-4", "smart", "openai_gpt-4", "openai/gpt-4"} {
+		resolved, _, ok := catalog.Resolve(name)
+		require.True(t, ok, name)
+		assert.Equal(t, "gpt-4", resolved.ID)
+	}
+
+	router := NewRouterService(providers, catalog, nil, nil)
+	resp, err := router.Route(context.Background(), models.RouteRequest{Prompt: "hi", PreferredModel: "smart"})
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4", resp.Result)
+
+	resp, err = router.Route(context.Background(), models.RouteRequest{Prompt: "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4", resp.Result, "unavailable models are skipped")
+}
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"workspace-engine/internal/llm-router/models"
+
+	"github.com/google/uuid"
+)
+
+// flightGroup shares one provider call between concurrent identical requests. A call runs on a
+// context that keeps the values of the request that started it, so its usage is attributed to that
+// request, and it is only canceled once every request waiting for it has gone.
+type flightGroup struct {
+	mu      sync.Mutex
+	calls   map[string]*flight
+	streams map[string]*streamFlight
+}
+
+type flight struct {
+	done    chan struct{}
+	resp    *models.RouteResponse
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{
+		calls:   make(map[string]*flight),
+		streams: make(map[string]*streamFlight),
+	}
+}
+
+// do runs call once for concurrent requests with the same key. shared reports whether the response
+// belongs to a call started by another request.
+func (g *flightGroup) do(
+	ctx context.Context, key string, call func(ctx context.Context) (*models.RouteResponse, error),
+) (resp *models.RouteResponse, shared bool, err error) {
+	g.mu.Lock()
+	if f, ok := g.calls[key]; ok {
+		f.waiters++
+		g.mu.Unlock()
+		return g.wait(ctx, key, f, true)
+	}
+
+	callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	f := &flight{done: make(chan struct{}), waiters: 1, cancel: cancel}
+	g.calls[key] = f
+	g.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		f.resp, f.err = call(callCtx)
+
+		g.mu.Lock()
+		if g.calls[key] == f {
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		close(f.done)
+	}()
+	return g.wait(ctx, key, f, false)
+}
+
+func (g *flightGroup) wait(
+	ctx context.Context, key string, f *flight, shared bool,
+) (*models.RouteResponse, bool, error) {
+	select {
+	case <-f.done:
+		return f.resp, shared, f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			// Nobody waits for the call anymore, later requests start a new one
+			f.cancel()
+			if g.calls[key] == f {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, shared, ctx.Err()
+	}
+}
+
+// streamFlight is an upstream stream shared by its subscribers. Chunks are kept until the stream
+// ends, so subscribers joining late receive the whole response.
+type streamFlight struct {
+	ready  chan struct{}
+	err    error
+	cancel context.CancelFunc
+	// subscribers is guarded by the mutex of the group
+	subscribers int
+
+	mu      sync.Mutex
+	chunks  []models.StreamResponse
+	closed  bool
+	updated chan struct{}
+}
+
+// stream opens an upstream stream once for concurrent requests with the same key and subscribes
+// the request to it. shared reports whether the stream was opened by another request.
+func (g *flightGroup) stream(
+	ctx context.Context, key string, open func(ctx context.Context) (<-chan models.StreamResponse, error),
+) (stream <-chan models.StreamResponse, shared bool, err error) {
+	g.mu.Lock()
+	f, shared := g.streams[key]
+	if shared {
+		f.subscribers++
+	} else {
+		streamCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &streamFlight{
+			ready:       make(chan struct{}),
+			updated:     make(chan struct{}),
+			subscribers: 1,
+			cancel:      cancel,
+		}
+		g.streams[key] = f
+		go g.pump(streamCtx, key, f, open)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-f.ready:
+	case <-ctx.Done():
+		g.unsubscribe(key, f)
+		return nil, shared, ctx.Err()
+	}
+	if f.err != nil {
+		g.unsubscribe(key, f)
+		return nil, shared, f.err
+	}
+
+	id := ""
+	if shared {
+		id = uuid.New().String()
+	}
+	return g.subscribe(ctx, key, f, id), shared, nil
+}
+
+// pump opens the upstream stream and keeps the chunks it sends
+func (g *flightGroup) pump(
+	ctx context.Context, key string, f *streamFlight,
+	open func(ctx context.Context) (<-chan models.StreamResponse, error),
+) {
+	defer f.cancel()
+	defer g.forget(key, f)
+
+	upstream, err := open(ctx)
+	f.err = err
+	close(f.ready)
+	if err != nil {
+		return
+	}
+
+	for chunk := range upstream {
+		f.mu.Lock()
+		f.chunks = append(f.chunks, chunk)
+		close(f.updated)
+		f.updated = make(chan struct{})
+		f.mu.Unlock()
+	}
+
+	f.mu.Lock()
+	f.closed = true
+	close(f.updated)
+	f.mu.Unlock()
+}
+
+// subscribe replays the chunks of a shared stream to a request, with the id of its response
+// replaced when it is set
+func (g *flightGroup) subscribe(
+	ctx context.Context, key string, f *streamFlight, id string,
+) <-chan models.StreamResponse {
+	stream := make(chan models.StreamResponse)
+	go func() {
+		defer close(stream)
+		defer g.unsubscribe(key, f)
+
+		for next := 0; ; {
+			f.mu.Lock()
+			chunks, closed, updated := f.chunks[next:], f.closed, f.updated
+			f.mu.Unlock()
+
+			for _, chunk := range chunks {
+				if id != "" {
+					chunk.ID = id
+				}
+				select {
+				case stream <- chunk:
+				case <-ctx.Done():
+					return
+				}
+			}
+			next += len(chunks)
+
+			if closed {
+				return
+			}
+			if len(chunks) == 0 {
+				select {
+				case <-updated:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return stream
+}
+
+// unsubscribe cancels the upstream stream once its last subscriber has gone
+func (g *flightGroup) unsubscribe(key string, f *streamFlight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	f.subscribers--
+	if f.subscribers == 0 {
+		f.cancel()
+		if g.streams[key] == f {
+			delete(g.streams, key)
+		}
+	}
+}
+
+// forget stops new requests from joining a stream
+func (g *flightGroup) forget(key string, f *streamFlight) {
+	g.mu.Lock()
+	if g.streams[key] == f {
+		delete(g.streams, key)
+	}
+	g.mu.Unlock()
+}
+
+// coalesceKey identifies requests that can share a provider call. Requests routed automatically
+// may share the call of any model, all others only the call of the model they were routed to.
+// Nothing is shared when coalescing is disabled or the caller sent its own provider API keys. The
+// flight group is returned with the key, as a reload may replace it.
+func (s *RouterService) coalesceKey(
+	ctx context.Context, route *routeDecision, req models.RouteRequest,
+) (*flightGroup, string, bool) {
+	flights := s.flights.Load()
+	if flights == nil || len(CallerFromContext(ctx).Credentials) > 0 {
+		return nil, "", false
+	}
+
+	target := route.key
+	if route.strategy == models.RouteStrategyAutomatic {
+		target = ""
+	}
+	// A call may only be shared by requests that need the same capabilities of the model
+	capabilities := append([]string(nil), route.required...)
+	sort.Strings(capabilities)
+	key, err := json.Marshal(
+		struct {
+			Rule           string
+			Target         string
+			Capabilities   []string
+			Template       *models.TemplateRef
+			Prompt         string
+			Messages       []models.Message
+			Content        []models.ContentPart
+			Parameters     map[string]interface{}
+			ResponseFormat *models.ResponseFormat
+		}{
+			Rule:           route.rule,
+			Target:         target,
+			Capabilities:   capabilities,
+			Template:       req.Template,
+			Prompt:         strings.TrimSpace(req.Prompt),
+			Messages:       req.Messages,
+			Content:        req.Content,
+			Parameters:     req.Parameters,
+			ResponseFormat: req.ResponseFormat,
+		},
+	)
+	if err != nil {
+		return nil, "", false
+	}
+
+	sum := sha256.Sum256(key)
+	return flights, hex.EncodeToString(sum[:]), true
+}
+
+// coalescedResponse is the copy of a shared response returned to a request that did not start the
+// call. It gets its own id and refers to the response it was copied from.
+func coalescedResponse(resp *models.RouteResponse) *models.RouteResponse {
+	coalesced := *resp
+	coalesced.ID = uuid.New().String()
+	coalesced.Metadata = make(map[string]interface{}, len(resp.Metadata)+1)
+	for key, value := range resp.Metadata {
+		coalesced.Metadata[key] = value
+	}
+	coalesced.Metadata["coalesced_with"] = resp.ID
+	return &coalesced
+}
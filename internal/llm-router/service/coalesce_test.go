@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service/llm"
+	"workspace-engine/internal/llm-router/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingProvider holds every call until it is released and streams the chunks sent to it
+type blockingProvider struct {
+	mu      sync.Mutex
+	calls   int
+	release chan struct{}
+	chunks  chan models.StreamResponse
+}
+
+func (p *blockingProvider) Generate(
+	ctx context.Context, prompt string, _ map[string]interface{},
+) (*models.RouteResponse, error) {
+	p.mu.Lock()
+	p.calls++
+	p.mu.Unlock()
+
+	select {
+	case <-p.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &models.RouteResponse{
+		ID:     "upstream",
+		Result: prompt,
+		Model:  "blocking",
+		Usage:  models.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}, nil
+}
+
+func (p *blockingProvider) GenerateStream(
+	context.Context, string, map[string]interface{},
+) (<-chan models.StreamResponse, error) {
+	p.mu.Lock()
+	p.calls++
+	p.mu.Unlock()
+	return p.chunks, nil
+}
+
+func (p *blockingProvider) GetModelInfo() models.ModelInfo {
+	return models.ModelInfo{ID: "blocking", Capabilities: []string{"chat"}, MaxTokens: 4096}
+}
+
+func (p *blockingProvider) IsHealthy() bool {
+	return true
+}
+
+func (p *blockingProvider) callCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+func TestCoalescing(t *testing.T) {
+	db, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+
+	provider := &blockingProvider{release: make(chan struct{}), chunks: make(chan models.StreamResponse)}
+	providers := map[string]llm.Provider{"openai_default": provider}
+	usage := NewUsageService(db)
+	router := NewRouterService(providers, NewCatalogService(providers, nil, &config.Config{}), nil, usage)
+	router.SetCoalescing(true)
+
+	// waiting reports whether the only call in flight has the given number of waiters
+	waiting := func(n int) func() bool {
+		return func() bool {
+			router.flights.Load().mu.Lock()
+			defer router.flights.Load().mu.Unlock()
+			for _, f := range router.flights.Load().calls {
+				return f.waiters == n
+			}
+			return false
+		}
+	}
+	req := models.RouteRequest{Prompt: "What changed in the release?"}
+
+	t.Run(
+		"IdenticalRequestsShareOneCall", func(t *testing.T) {
+			const callers = 5
+			responses := make([]*models.RouteResponse, callers)
+			var wg sync.WaitGroup
+			for i := 0; i < callers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					resp, err := router.Route(context.Background(), req)
+					assert.NoError(t, err)
+					responses[i] = resp
+				}(i)
+			}
+			require.Eventually(t, waiting(callers), time.Second, time.Millisecond)
+			provider.release <- struct{}{}
+			wg.Wait()
+
+			assert.Equal(t, 1, provider.callCount())
+			ids := map[string]bool{}
+			coalesced := 0
+			for _, resp := range responses {
+				require.NotNil(t, resp)
+				assert.Equal(t, req.Prompt, resp.Result)
+				ids[resp.ID] = true
+				if resp.Metadata["coalesced_with"] == "upstream" {
+					coalesced++
+				}
+			}
+			assert.Len(t, ids, callers)
+			assert.True(t, ids["upstream"])
+			assert.Equal(t, callers-1, coalesced)
+
+			records, err := usage.List("", models.UsageFilter{})
+			require.NoError(t, err)
+			require.Len(t, records, 1)
+			assert.Equal(t, "upstream", records[0].RequestID)
+		},
+	)
+
+	t.Run(
+		"DifferentRequestsAreNotShared", func(t *testing.T) {
+			before := provider.callCount()
+			var wg sync.WaitGroup
+			for _, prompt := range []string{"first", "second"} {
+				wg.Add(1)
+				go func(prompt string) {
+					defer wg.Done()
+					resp, err := router.Route(context.Background(), models.RouteRequest{Prompt: prompt})
+					assert.NoError(t, err)
+					assert.Equal(t, prompt, resp.Result)
+				}(prompt)
+			}
+			require.Eventually(t, func() bool { return provider.callCount() == before+2 }, time.Second, time.Millisecond)
+			provider.release <- struct{}{}
+			provider.release <- struct{}{}
+			wg.Wait()
+		},
+	)
+
+	t.Run(
+		"DifferentCapabilitiesAreNotShared", func(t *testing.T) {
+			before := provider.callCount()
+			var wg sync.WaitGroup
+			for _, capabilities := range [][]string{nil, {"chat"}} {
+				wg.Add(1)
+				go func(capabilities []string) {
+					defer wg.Done()
+					_, err := router.Route(
+						context.Background(), models.RouteRequest{Prompt: req.Prompt, Capabilities: capabilities},
+					)
+					assert.NoError(t, err)
+				}(capabilities)
+			}
+			require.Eventually(t, func() bool { return provider.callCount() == before+2 }, time.Second, time.Millisecond)
+			provider.release <- struct{}{}
+			provider.release <- struct{}{}
+			wg.Wait()
+		},
+	)
+
+	t.Run(
+		"CanceledCallerDoesNotCancelTheSharedCall", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			first := make(chan error, 1)
+			go func() {
+				_, err := router.Route(ctx, req)
+				first <- err
+			}()
+			require.Eventually(t, waiting(1), time.Second, time.Millisecond)
+
+			second := make(chan *models.RouteResponse, 1)
+			go func() {
+				resp, err := router.Route(context.Background(), req)
+				assert.NoError(t, err)
+				second <- resp
+			}()
+			require.Eventually(t, waiting(2), time.Second, time.Millisecond)
+
+			cancel()
+			assert.ErrorIs(t, <-first, context.Canceled)
+			provider.release <- struct{}{}
+			resp := <-second
+			require.NotNil(t, resp)
+			assert.Equal(t, req.Prompt, resp.Result)
+		},
+	)
+
+	t.Run(
+		"CallerCredentialsAreNotShared", func(t *testing.T) {
+			ctx := WithCaller(context.Background(), Caller{Credentials: map[string]string{"openai": "sk-tenant"}})
+			route, err := router.resolveProvider(context.Background(), req)
+			require.NoError(t, err)
+			_, _, ok := router.coalesceKey(ctx, route, req)
+			assert.False(t, ok)
+		},
+	)
+
+	t.Run(
+		"StreamSubscribersShareTheUpstream", func(t *testing.T) {
+			before := provider.callCount()
+			recorded, err := usage.List("", models.UsageFilter{})
+			require.NoError(t, err)
+			first, err := router.RouteStream(context.Background(), req)
+			require.NoError(t, err)
+
+			provider.chunks <- models.StreamResponse{ID: "upstream", Content: "Release "}
+			assert.Equal(t, "Release ", (<-first).Content)
+
+			// A late subscriber receives the chunks sent before it joined
+			second, err := router.RouteStream(context.Background(), req)
+			require.NoError(t, err)
+			go func() {
+				provider.chunks <- models.StreamResponse{ID: "upstream", Content: "notes"}
+				provider.chunks <- models.StreamResponse{
+					ID:    "upstream",
+					Done:  true,
+					Usage: &models.Usage{PromptTokens: 4, CompletionTokens: 3, TotalTokens: 7},
+				}
+				close(provider.chunks)
+			}()
+
+			read := func(stream <-chan models.StreamResponse) (string, map[string]bool) {
+				var content string
+				ids := map[string]bool{}
+				for chunk := range stream {
+					content += chunk.Content
+					ids[chunk.ID] = true
+				}
+				return content, ids
+			}
+			rest, firstIDs := read(first)
+			content, secondIDs := read(second)
+
+			assert.Equal(t, before+1, provider.callCount())
+			assert.Equal(t, "notes", rest)
+			assert.Equal(t, map[string]bool{"upstream": true}, firstIDs)
+			assert.Equal(t, "Release notes", content)
+			assert.Len(t, secondIDs, 1)
+			assert.False(t, secondIDs["upstream"])
+
+			// The usage of the shared upstream is recorded once, when its last chunk arrives
+			var records []models.UsageRecord
+			require.Eventually(
+				t, func() bool {
+					records, err = usage.List("", models.UsageFilter{})
+					return err == nil && len(records) == len(recorded)+1
+				}, time.Second, time.Millisecond,
+			)
+			assert.Equal(t, "upstream", records[0].RequestID)
+			assert.Equal(t, "blocking", records[0].Model)
+			assert.Equal(t, 7, records[0].Usage.TotalTokens)
+		},
+	)
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service/llm"
+)
+
+var (
+	ErrInvalidContent     = errors.New("invalid content")
+	ErrUnsupportedContent = errors.New("model does not support the request content")
+)
+
+// validateRequest checks the content parts and the conversation history of a request
+func validateRequest(req models.RouteRequest) error {
+	if err := validateContent(req.Content); err != nil {
+		return err
+	}
+	return validateMessages(req.Messages)
+}
+
+// validateContent checks that every content part is complete
+func validateContent(parts []models.ContentPart) error {
+	for i, part := range parts {
+		switch part.Type {
+		case models.ContentPartText:
+			if part.Text == "" {
+				return fmt.Errorf("%w: part %d has no text", ErrInvalidContent, i)
+			}
+		case models.ContentPartImage, models.ContentPartDocument:
+			if (part.URL == "") == (part.Data == "") {
+				return fmt.Errorf("%w: part %d needs either url or data", ErrInvalidContent, i)
+			}
+			if part.Data != "" && part.MediaType == "" {
+				return fmt.Errorf("%w: part %d needs a mediaType for its data", ErrInvalidContent, i)
+			}
+		default:
+			return fmt.Errorf("%w: part %d has unknown type %q", ErrInvalidContent, i, part.Type)
+		}
+	}
+	return nil
+}
+
+// requiredCapabilities returns the model capabilities needed to serve the request content along
+// with the capabilities requested explicitly
+func requiredCapabilities(req models.RouteRequest) []string {
+	required := append([]string(nil), req.Capabilities...)
+	for _, part := range req.Content {
+		if part.Type != models.ContentPartText && !contains(required, models.CapabilityVision) {
+			required = append(required, models.CapabilityVision)
+			break
+		}
+	}
+	return required
+}
+
+// unsupportedPart returns the type of the first content part the provider cannot send to its
+// vendor, or an empty string when it can send them all
+func unsupportedPart(provider llm.Provider, parts []models.ContentPart) string {
+	content, ok := provider.(llm.ContentProvider)
+	if !ok {
+		return ""
+	}
+	for _, part := range parts {
+		if !content.SupportsContent(part.Type) {
+			return part.Type
+		}
+	}
+	return ""
+}
+
+func hasCapabilities(info models.ModelInfo, required []string) bool {
+	for _, capability := range required {
+		found := false
+		for _, c := range info.Capabilities {
+			if c == capability {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// providerParams returns the parameters passed to the provider, which carry the content parts and
+// the conversation history of the request along with the request parameters
+func providerParams(req models.RouteRequest) map[string]interface{} {
+	params := make(map[string]interface{}, len(req.Parameters)+1)
+	for k, v := range req.Parameters {
+		params[k] = v
+	}
+	if len(req.Content) > 0 {
+		params["content"] = req.Content
+	}
+	if len(req.Messages) > 0 {
+		params["messages"] = req.Messages
+	}
+	return params
+}
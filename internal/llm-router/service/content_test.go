@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service/llm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteContent(t *testing.T) {
+	text := &scriptedProvider{model: "text-model", results: []string{"text"}}
+	vision := &scriptedProvider{model: "vision-model", results: []string{"vision"}}
+	providers := map[string]llm.Provider{"anthropic_text": text, "openai_vision": vision}
+	cfg := &config.Config{
+		Providers: config.ProvidersConfig{
+			OpenAI: config.ProviderConfig{
+				Models: []config.ModelConfig{
+					{Name: "vision-model", Capabilities: []string{"chat", models.CapabilityVision}},
+				},
+			},
+		},
+	}
+	router := NewRouterService(providers, NewCatalogService(providers, nil, cfg), nil, nil)
+
+	image := []models.ContentPart{{Type: models.ContentPartImage, Data: "iVBORw0KGgo=", MediaType: "image/png"}}
+
+	t.Run(
+		"SelectsVisionModel", func(t *testing.T) {
+			resp, err := router.Route(context.Background(), models.RouteRequest{Prompt: "What is this?", Content: image})
+			require.NoError(t, err)
+			assert.Equal(t, "vision", resp.Result)
+			assert.Equal(t, image, vision.params[0]["content"])
+		},
+	)
+
+	t.Run(
+		"RejectsPreferredModelWithoutVision", func(t *testing.T) {
+			_, err := router.Route(
+				context.Background(), models.RouteRequest{
+					Prompt:         "What is this?",
+					PreferredModel: "text-model",
+					Content:        image,
+				},
+			)
+			assert.ErrorIs(t, err, ErrUnsupportedContent)
+		},
+	)
+
+	t.Run(
+		"RejectsIncompleteParts", func(t *testing.T) {
+			_, err := router.Route(
+				context.Background(), models.RouteRequest{
+					Prompt:  "What is this?",
+					Content: []models.ContentPart{{Type: models.ContentPartImage}},
+				},
+			)
+			assert.ErrorIs(t, err, ErrInvalidContent)
+		},
+	)
+
+	t.Run(
+		"SkipsModelsWithoutTheParts", func(t *testing.T) {
+			images := &imageProvider{scriptedProvider{model: "vision-model", results: []string{"image", "image"}}}
+			providers := map[string]llm.Provider{"openai_vision": images}
+			router := NewRouterService(providers, NewCatalogService(providers, nil, cfg), nil, nil)
+
+			resp, err := router.Route(context.Background(), models.RouteRequest{Prompt: "What is this?", Content: image})
+			require.NoError(t, err)
+			assert.Equal(t, "image", resp.Result)
+
+			document := []models.ContentPart{{Type: models.ContentPartDocument, URL: "https://example.com/a.pdf"}}
+			_, err = router.Route(context.Background(), models.RouteRequest{Prompt: "Summarize", Content: document})
+			assert.ErrorIs(t, err, ErrUnsupportedContent)
+
+			_, err = router.Route(
+				context.Background(), models.RouteRequest{
+					Prompt:         "Summarize",
+					PreferredModel: "vision-model",
+					Content:        document,
+				},
+			)
+			assert.ErrorIs(t, err, ErrUnsupportedContent)
+			assert.Empty(t, images.prompts[1:])
+		},
+	)
+}
+
+// imageProvider reads text and image parts only, like the OpenAI chat API
+type imageProvider struct {
+	scriptedProvider
+}
+
+func (p *imageProvider) SupportsContent(partType string) bool {
+	return partType == models.ContentPartText || partType == models.ContentPartImage
+}
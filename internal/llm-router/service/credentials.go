@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service/llm"
+	"workspace-engine/internal/llm-router/store"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidCredentials    = errors.New("invalid provider credentials")
+	ErrCredentialsNotAllowed = errors.New("provider credentials are not allowed for this API key")
+)
+
+// CredentialFactory creates a ready to use provider instance for a model of a vendor that
+// authenticates with the given API key
+type CredentialFactory func(vendor, model, apiKey string) (llm.Provider, error)
+
+// CredentialService lets callers bring their own provider API keys, so their requests are billed
+// to their own vendor accounts. A request is sent with a provider instance created for it alone,
+// and only API keys of the router an admin allowed may do so.
+type CredentialService struct {
+	db      *gorm.DB
+	factory CredentialFactory
+}
+
+func NewCredentialService(db *gorm.DB, factory CredentialFactory) *CredentialService {
+	return &CredentialService{
+		db:      db,
+		factory: factory,
+	}
+}
+
+// Allowed reports whether requests made with the API key may carry provider API keys. Keys
+// without a policy are denied.
+func (s *CredentialService) Allowed(apiKey string) (bool, error) {
+	var entity store.CredentialPolicy
+	err := s.db.Where("key_hash = ?", hashAPIKey(apiKey)).First(&entity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load credential policy: %w", err)
+	}
+	return entity.Allowed, nil
+}
+
+// SetPolicy allows or denies provider API keys for an API key of the router
+func (s *CredentialService) SetPolicy(apiKey string, allowed bool) (models.CredentialPolicy, error) {
+	entity := store.CredentialPolicy{KeyHash: hashAPIKey(apiKey), Allowed: allowed}
+	err := s.db.Clauses(
+		clause.OnConflict{
+			Columns:   []clause.Column{{Name: "key_hash"}},
+			DoUpdates: clause.AssignmentColumns([]string{"allowed", "updated_at"}),
+		},
+	).Create(&entity).Error
+	if err != nil {
+		return models.CredentialPolicy{}, fmt.Errorf("failed to store credential policy: %w", err)
+	}
+	return toCredentialPolicyModel(entity), nil
+}
+
+// Policies returns the credential policies, most recently changed first
+func (s *CredentialService) Policies() ([]models.CredentialPolicy, error) {
+	var entities []store.CredentialPolicy
+	if err := s.db.Order("updated_at desc").Find(&entities).Error; err != nil {
+		return nil, fmt.Errorf("failed to list credential policies: %w", err)
+	}
+
+	policies := make([]models.CredentialPolicy, 0, len(entities))
+	for _, entity := range entities {
+		policies = append(policies, toCredentialPolicyModel(entity))
+	}
+	return policies, nil
+}
+
+// authorize rejects requests carrying provider API keys unless their API key is allowed to
+func (s *CredentialService) authorize(ctx context.Context) error {
+	caller := CallerFromContext(ctx)
+	if len(caller.Credentials) == 0 {
+		return nil
+	}
+	if s == nil {
+		return ErrCredentialsNotAllowed
+	}
+
+	allowed, err := s.Allowed(caller.APIKey)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrCredentialsNotAllowed
+	}
+	return nil
+}
+
+// provider returns the provider instance a request is sent with. When the caller sent an API key
+// for the vendor of the provider, it is a new instance for the same model using that key.
+func (s *CredentialService) provider(
+	ctx context.Context, key, model string, provider llm.Provider,
+) (llm.Provider, error) {
+	if s == nil {
+		return provider, nil
+	}
+	vendor, _, _ := strings.Cut(key, "_")
+	apiKey, ok := CallerFromContext(ctx).Credentials[vendor]
+	if !ok {
+		return provider, nil
+	}
+
+	provider, err := s.factory(vendor, model, apiKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s provider with caller credentials: %w", vendor, err)
+	}
+	return provider, nil
+}
+
+func toCredentialPolicyModel(entity store.CredentialPolicy) models.CredentialPolicy {
+	return models.CredentialPolicy{
+		KeyHash:   entity.KeyHash,
+		Allowed:   entity.Allowed,
+		UpdatedAt: entity.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service/llm"
+	"workspace-engine/internal/llm-router/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCredentials(t *testing.T) {
+	credentials, err := ParseCredentials([]string{"OpenAI=sk-1, anthropic = sk-2", "groq=gsk=3"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"openai": "sk-1", "anthropic": "sk-2", "groq": "gsk=3"}, credentials)
+
+	credentials, err = ParseCredentials(nil)
+	require.NoError(t, err)
+	assert.Nil(t, credentials)
+
+	for _, value := range []string{"sk-1", "openai=", "=sk-1"} {
+		_, err := ParseCredentials([]string{value})
+		assert.ErrorIs(t, err, ErrInvalidCredentials, value)
+		assert.NotContains(t, err.Error(), "sk-1")
+	}
+}
+
+func TestResolveTenant(t *testing.T) {
+	tenants := []config.TenantConfig{
+		{Name: "acme", APIKeys: []config.Secret{"key-acme", "key-shared"}},
+		{Name: "globex", APIKeys: []config.Secret{"key-shared"}},
+	}
+
+	tenant, err := ResolveTenant(tenants, "key-acme", "")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", tenant, "the only tenant of a key is used without a header")
+
+	tenant, err = ResolveTenant(tenants, "key-shared", "globex")
+	require.NoError(t, err)
+	assert.Equal(t, "globex", tenant)
+
+	tenant, err = ResolveTenant(tenants, "key-shared", "")
+	require.NoError(t, err)
+	assert.Empty(t, tenant)
+
+	_, err = ResolveTenant(tenants, "key-acme", "globex")
+	assert.ErrorIs(t, err, ErrTenantNotAllowed)
+	_, err = ResolveTenant(tenants, "key-other", "acme")
+	assert.ErrorIs(t, err, ErrTenantNotAllowed)
+}
+
+func TestCallerCredentials(t *testing.T) {
+	db, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+
+	shared := &scriptedProvider{model: "gpt-4", results: []string{"router account"}}
+	providers := map[string]llm.Provider{"openai_default": shared}
+	router := NewRouterService(providers, NewCatalogService(providers, nil, &config.Config{}), nil, nil)
+
+	type call struct{ vendor, model, apiKey string }
+	var calls []call
+	credentials := NewCredentialService(
+		db, func(vendor, model, apiKey string) (llm.Provider, error) {
+			calls = append(calls, call{vendor, model, apiKey})
+			return &scriptedProvider{model: model, results: []string{"caller account"}}, nil
+		},
+	)
+	router.SetCredentials(credentials)
+
+	withKeys := func(apiKey string, keys map[string]string) context.Context {
+		return WithCaller(context.Background(), Caller{APIKey: apiKey, Credentials: keys})
+	}
+	req := models.RouteRequest{Prompt: "hi"}
+
+	t.Run(
+		"DeniedWithoutPolicy", func(t *testing.T) {
+			_, err := router.Route(withKeys("tenant-key", map[string]string{"openai": "sk-tenant"}), req)
+			assert.ErrorIs(t, err, ErrCredentialsNotAllowed)
+			assert.Empty(t, calls)
+		},
+	)
+
+	t.Run(
+		"AllowedKeyUsesCallerCredentials", func(t *testing.T) {
+			policy, err := credentials.SetPolicy("tenant-key", true)
+			require.NoError(t, err)
+			assert.True(t, policy.Allowed)
+			assert.Equal(t, hashAPIKey("tenant-key"), policy.KeyHash)
+
+			resp, err := router.Route(withKeys("tenant-key", map[string]string{"openai": "sk-tenant"}), req)
+			require.NoError(t, err)
+			assert.Equal(t, "caller account", resp.Result)
+			assert.Equal(t, []call{{"openai", "gpt-4", "sk-tenant"}}, calls)
+			assert.Empty(t, shared.prompts)
+		},
+	)
+
+	t.Run(
+		"OtherVendorUsesRouterCredentials", func(t *testing.T) {
+			resp, err := router.Route(withKeys("tenant-key", map[string]string{"anthropic": "sk-ant"}), req)
+			require.NoError(t, err)
+			assert.Equal(t, "router account", resp.Result)
+		},
+	)
+
+	t.Run(
+		"DeniedAfterRevoke", func(t *testing.T) {
+			_, err := credentials.SetPolicy("tenant-key", false)
+			require.NoError(t, err)
+
+			_, err = router.Route(withKeys("tenant-key", map[string]string{"openai": "sk-tenant"}), req)
+			assert.ErrorIs(t, err, ErrCredentialsNotAllowed)
+
+			policies, err := credentials.Policies()
+			require.NoError(t, err)
+			require.Len(t, policies, 1)
+			assert.False(t, policies[0].Allowed)
+		},
+	)
+
+	t.Run(
+		"RequestsWithoutCredentials", func(t *testing.T) {
+			resp, err := router.Route(withKeys("other-key", nil), req)
+			require.NoError(t, err)
+			assert.Equal(t, "router account", resp.Result)
+		},
+	)
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"math"
+	"unicode/utf8"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/pkg/logger"
+)
+
+const (
+	// charsPerToken approximates the tokenizers of the supported vendors for English text
+	charsPerToken = 4
+	// imageTokens approximates an image of about a megapixel, which the vendors count as 765 to 1600
+	// tokens depending on how they tile it
+	imageTokens = 1000
+	// minHistorySamples is the number of past requests needed for a high confidence estimate
+	minHistorySamples = 5
+	defaultCurrency   = "USD"
+
+	outputSourceHistory   = "history"
+	outputSourceMaxTokens = "maxTokens"
+	outputSourceNone      = "none"
+)
+
+// Estimate predicts the cost of a request without sending it. Input tokens are counted from the
+// rendered prompt, output tokens come from the average of past requests to the same model and
+// template, or from the maxTokens parameter when there is not enough history.
+func (s *RouterService) Estimate(ctx context.Context, req models.RouteRequest) (*models.EstimateResponse, error) {
+	req, tmpl, err := s.applyTemplate(req)
+	if err != nil {
+		return nil, err
+	}
+
+	route, err := s.resolveProvider(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	info := s.modelInfo(route.key, route.provider)
+
+	estimate := &models.EstimateResponse{
+		Model:        info.ID,
+		Provider:     info.Provider,
+		Currency:     info.Pricing.Currency,
+		InputTokens:  requestTokens(req),
+		Confidence:   models.EstimateConfidenceNone,
+		OutputSource: outputSourceNone,
+	}
+	for _, message := range trimMessages(req, info.MaxTokens) {
+		estimate.InputTokens += countTokens(message.Content)
+	}
+	if estimate.Currency == "" {
+		estimate.Currency = defaultCurrency
+	}
+
+	maxTokens := maxTokensParam(req.Parameters)
+
+	average, samples := 0.0, 0
+	if s.usage != nil {
+		templateName := ""
+		if tmpl != nil {
+			templateName = tmpl.Name
+		}
+		average, samples, err = s.usage.AverageCompletion(info.ID, templateName)
+		if err != nil {
+			logger.WarnContext(ctx, "Failed to load usage history", "model", info.ID, "error", err)
+		}
+	}
+
+	switch {
+	case samples > 0:
+		estimate.OutputTokens = int(math.Round(average))
+		estimate.OutputSource = outputSourceHistory
+		estimate.Confidence = models.EstimateConfidenceMed
+		if samples >= minHistorySamples {
+			estimate.Confidence = models.EstimateConfidenceHigh
+		}
+		if maxTokens > 0 && estimate.OutputTokens > maxTokens {
+			estimate.OutputTokens = maxTokens
+		}
+	case maxTokens > 0:
+		// maxTokens is an upper bound, so the estimate is the most the request can cost
+		estimate.OutputTokens = maxTokens
+		estimate.OutputSource = outputSourceMaxTokens
+		estimate.Confidence = models.EstimateConfidenceMed
+	}
+
+	estimate.TotalTokens = estimate.InputTokens + estimate.OutputTokens
+
+	// Without pricing the amount would be misleading
+	if info.Pricing.InputPrice == 0 && info.Pricing.OutputPrice == 0 {
+		estimate.Confidence = models.EstimateConfidenceNone
+		return estimate, nil
+	}
+	estimate.Amount = float64(estimate.InputTokens)/1000*info.Pricing.InputPrice +
+		float64(estimate.OutputTokens)/1000*info.Pricing.OutputPrice
+
+	return estimate, nil
+}
+
+// countTokens approximates the number of tokens of a text
+func countTokens(text string) int {
+	chars := utf8.RuneCountInString(text)
+	if chars == 0 {
+		return 0
+	}
+	return (chars + charsPerToken - 1) / charsPerToken
+}
+
+// maxTokensParam reads the maxTokens parameter, which is a float64 when it was decoded from JSON
+func maxTokensParam(params map[string]interface{}) int {
+	switch tokens := params["maxTokens"].(type) {
+	case int:
+		return tokens
+	case float64:
+		return int(tokens)
+	}
+	return 0
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service/llm"
+	"workspace-engine/internal/llm-router/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimate(t *testing.T) {
+	db, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+
+	providers := map[string]llm.Provider{
+		"openai_default": &scriptedProvider{model: "gpt-4", results: []string{"ok"}},
+	}
+	cfg := &config.Config{
+		Providers: config.ProvidersConfig{
+			OpenAI: config.ProviderConfig{
+				Models: []config.ModelConfig{
+					{
+						Name:         "gpt-4",
+						InputPrice:   0.03,
+						OutputPrice:  0.06,
+						Capabilities: []string{"chat", models.CapabilityVision},
+					},
+				},
+			},
+		},
+	}
+	usage := NewUsageService(db)
+	router := NewRouterService(providers, NewCatalogService(providers, nil, cfg), nil, usage)
+
+	// 40 characters are counted as 10 tokens
+	req := models.RouteRequest{
+		Prompt:     "Summarize the release notes of version 2",
+		Parameters: map[string]interface{}{"maxTokens": float64(100)},
+	}
+
+	t.Run(
+		"MaxTokens", func(t *testing.T) {
+			estimate, err := router.Estimate(context.Background(), req)
+			require.NoError(t, err)
+			assert.Equal(t, "gpt-4", estimate.Model)
+			assert.Equal(t, 10, estimate.InputTokens)
+			assert.Equal(t, 100, estimate.OutputTokens)
+			assert.Equal(t, 110, estimate.TotalTokens)
+			assert.InDelta(t, 0.0063, estimate.Amount, 1e-9)
+			assert.Equal(t, "USD", estimate.Currency)
+			assert.Equal(t, models.EstimateConfidenceMed, estimate.Confidence)
+			assert.Equal(t, "maxTokens", estimate.OutputSource)
+		},
+	)
+
+	t.Run(
+		"History", func(t *testing.T) {
+			for i := 0; i < minHistorySamples; i++ {
+				require.NoError(
+					t, usage.Record(
+						Caller{},
+						&models.RouteResponse{
+							Model: "gpt-4",
+							Usage: models.Usage{PromptTokens: 10, CompletionTokens: 40 + i*5},
+						}, nil,
+					),
+				)
+			}
+
+			estimate, err := router.Estimate(context.Background(), req)
+			require.NoError(t, err)
+			assert.Equal(t, 50, estimate.OutputTokens)
+			assert.Equal(t, models.EstimateConfidenceHigh, estimate.Confidence)
+			assert.Equal(t, "history", estimate.OutputSource)
+		},
+	)
+
+	t.Run(
+		"Images", func(t *testing.T) {
+			image := models.ContentPart{Type: models.ContentPartImage, URL: "https://example.com/a.png"}
+			withImage := req
+			withImage.Content = []models.ContentPart{image, image}
+
+			estimate, err := router.Estimate(context.Background(), withImage)
+			require.NoError(t, err)
+			assert.Equal(t, 10+2*imageTokens, estimate.InputTokens)
+		},
+	)
+
+	t.Run(
+		"WithoutPricing", func(t *testing.T) {
+			router := newTestRouter(&scriptedProvider{model: "unpriced", results: []string{"ok"}})
+
+			estimate, err := router.Estimate(context.Background(), req)
+			require.NoError(t, err)
+			assert.Zero(t, estimate.Amount)
+			assert.Equal(t, models.EstimateConfidenceNone, estimate.Confidence)
+		},
+	)
+}
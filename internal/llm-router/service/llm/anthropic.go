@@ -5,35 +5,67 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
+	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"workspace-engine/internal/llm-router/models"
-
-	"github.com/google/uuid"
 )
 
 type AnthropicProvider struct {
-	apiKey  string
-	model   string
-	client  *http.Client
-	baseURL string
+	apiKey string
+	model  string
+	client *http.Client
+	// streamClient has no overall timeout, a stream lasts as long as the model generates. A stream
+	// fails instead when no data arrives for streamIdleTimeout.
+	streamClient      *http.Client
+	streamIdleTimeout time.Duration
+	baseURL           string
 }
 
 // AnthropicMessage represents the message format for Anthropic's API
 type AnthropicMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string           `json:"role"`
+	Content AnthropicContent `json:"content"`
+}
+
+// AnthropicContent is sent as a plain string unless the message has content blocks
+type AnthropicContent struct {
+	Text   string
+	Blocks []AnthropicContentBlock
+}
+
+// AnthropicContentBlock is a text, image or document block of a request message
+type AnthropicContentBlock struct {
+	Type   string           `json:"type"`
+	Text   string           `json:"text,omitempty"`
+	Source *AnthropicSource `json:"source,omitempty"`
+}
+
+// AnthropicSource is the base64 data or URL of an image or document block
+type AnthropicSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+func (c AnthropicContent) MarshalJSON() ([]byte, error) {
+	if len(c.Blocks) > 0 {
+		return json.Marshal(c.Blocks)
+	}
+	return json.Marshal(c.Text)
 }
 
 // AnthropicRequest represents the request structure for Anthropic's API
 type AnthropicRequest struct {
-	Model       string             `json:"model"`
+	Model string `json:"model"`
+	// System holds the system messages, which Anthropic does not accept in the message list
+	System      string             `json:"system,omitempty"`
 	Messages    []AnthropicMessage `json:"messages"`
 	MaxTokens   int                `json:"max_tokens,omitempty"`
 	Temperature float32            `json:"temperature,omitempty"`
@@ -63,23 +95,76 @@ type AnthropicUsage struct {
 	OutputTokens int `json:"output_tokens"`
 }
 
-// AnthropicStreamResponse represents a streaming response chunk
-type AnthropicStreamResponse struct {
-	Type       string         `json:"type"`
-	Content    []ContentBlock `json:"content"`
-	StopReason string         `json:"stop_reason,omitempty"`
-	Error      *struct {
-		Type    string `json:"type"`
-		Message string `json:"message"`
-	} `json:"error,omitempty"`
+// Anthropic stream event types
+const (
+	anthropicMessageStart      = "message_start"
+	anthropicContentBlockStart = "content_block_start"
+	anthropicContentBlockDelta = "content_block_delta"
+	anthropicContentBlockStop  = "content_block_stop"
+	anthropicMessageDelta      = "message_delta"
+	anthropicMessageStop       = "message_stop"
+	anthropicPing              = "ping"
+	anthropicError             = "error"
+)
+
+// AnthropicStreamEvent is an event of a streamed message. Only the fields of its type are set:
+// message_start carries the message with the input tokens, content_block_start and
+// content_block_delta the blocks of the content, message_delta the stop reason and the output
+// tokens, and error an error that ends the stream.
+type AnthropicStreamEvent struct {
+	Type         string                `json:"type"`
+	Message      *AnthropicResponse    `json:"message,omitempty"`
+	Index        int                   `json:"index"`
+	ContentBlock *ContentBlock         `json:"content_block,omitempty"`
+	Delta        *AnthropicStreamDelta `json:"delta,omitempty"`
+	Usage        *AnthropicUsage       `json:"usage,omitempty"`
+	Error        *AnthropicError       `json:"error,omitempty"`
+}
+
+// AnthropicStreamDelta is the change of a content block or, in message_delta events, of the message
+type AnthropicStreamDelta struct {
+	// Type is text_delta for text, other block types such as tool input and thinking have their own
+	Type         string `json:"type,omitempty"`
+	Text         string `json:"text,omitempty"`
+	StopReason   string `json:"stop_reason,omitempty"`
+	StopSequence string `json:"stop_sequence,omitempty"`
 }
 
+// AnthropicError is the error of an error event
+type AnthropicError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// anthropicErrorStatus maps the error types of error events to the HTTP status the API uses for
+// them, so errors of a stream are classified like those of a request
+var anthropicErrorStatus = map[string]int{
+	"invalid_request_error": http.StatusBadRequest,
+	"authentication_error":  http.StatusUnauthorized,
+	"permission_error":      http.StatusForbidden,
+	"not_found_error":       http.StatusNotFound,
+	"request_too_large":     http.StatusRequestEntityTooLarge,
+	"rate_limit_error":      http.StatusTooManyRequests,
+	"api_error":             http.StatusInternalServerError,
+	"overloaded_error":      529,
+}
+
+const (
+	// anthropicStreamHeaderTimeout bounds the wait for the response headers of a stream
+	anthropicStreamHeaderTimeout = 30 * time.Second
+	// anthropicStreamIdleTimeout bounds the wait for data between events. The API sends ping events
+	// while the model is slow to generate, so an idle stream has stalled.
+	anthropicStreamIdleTimeout = time.Minute
+)
+
 func NewAnthropicProvider(apiKey, model string) *AnthropicProvider {
 	return &AnthropicProvider{
-		apiKey:  apiKey,
-		model:   model,
-		client:  &http.Client{Timeout: 30 * time.Second},
-		baseURL: "https://api.anthropic.com/v1",
+		apiKey:            apiKey,
+		model:             model,
+		client:            newHTTPClient(30 * time.Second),
+		streamClient:      newStreamHTTPClient(anthropicStreamHeaderTimeout),
+		streamIdleTimeout: anthropicStreamIdleTimeout,
+		baseURL:           "https://api.anthropic.com/v1",
 	}
 }
 
@@ -104,15 +189,16 @@ func (p *AnthropicProvider) Generate(
 		}
 	}
 
+	system, messages, err := newAnthropicMessages(prompt, params)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create request
 	reqBody := AnthropicRequest{
-		Model: p.model,
-		Messages: []AnthropicMessage{
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
+		Model:       p.model,
+		System:      system,
+		Messages:    messages,
 		MaxTokens:   maxTokens,
 		Temperature: temperature,
 		TopP:        topP,
@@ -148,8 +234,7 @@ func (p *AnthropicProvider) Generate(
 
 	// Handle non-200 responses
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("anthropic api error: status %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError("anthropic", resp)
 	}
 
 	// Parse response
@@ -185,20 +270,69 @@ func (p *AnthropicProvider) Generate(
 	return result, nil
 }
 
+// newAnthropicMessages builds the system prompt and the conversation history followed by the user
+// message
+func newAnthropicMessages(prompt string, params map[string]interface{}) (string, []AnthropicMessage, error) {
+	userContent, err := newAnthropicContent(prompt, contentParts(params))
+	if err != nil {
+		return "", nil, err
+	}
+
+	var system []string
+	var messages []AnthropicMessage
+	for _, m := range history(params) {
+		if m.Role == models.MessageRoleSystem {
+			system = append(system, m.Content)
+			continue
+		}
+		messages = append(messages, AnthropicMessage{Role: m.Role, Content: AnthropicContent{Text: m.Content}})
+	}
+	messages = append(messages, AnthropicMessage{Role: "user", Content: userContent})
+	return strings.Join(system, "\n\n"), messages, nil
+}
+
+// newAnthropicContent builds the content of the user message from the prompt and content parts
+func newAnthropicContent(prompt string, parts []models.ContentPart) (AnthropicContent, error) {
+	content := AnthropicContent{Text: prompt}
+	if len(parts) == 0 {
+		return content, nil
+	}
+
+	if prompt != "" {
+		content.Blocks = append(content.Blocks, AnthropicContentBlock{Type: "text", Text: prompt})
+	}
+	for _, part := range parts {
+		switch part.Type {
+		case models.ContentPartText:
+			content.Blocks = append(content.Blocks, AnthropicContentBlock{Type: "text", Text: part.Text})
+		case models.ContentPartImage, models.ContentPartDocument:
+			source := &AnthropicSource{Type: "url", URL: part.URL}
+			if part.URL == "" {
+				source = &AnthropicSource{Type: "base64", MediaType: part.MediaType, Data: part.Data}
+			}
+			content.Blocks = append(content.Blocks, AnthropicContentBlock{Type: part.Type, Source: source})
+		default:
+			return content, fmt.Errorf("anthropic: %s content is not supported", part.Type)
+		}
+	}
+	return content, nil
+}
+
 func (p *AnthropicProvider) GenerateStream(
 	ctx context.Context, prompt string, params map[string]interface{},
 ) (<-chan models.StreamResponse, error) {
-	stream := make(chan models.StreamResponse)
+	system, messages, err := newAnthropicMessages(prompt, params)
+	if err != nil {
+		return nil, err
+	}
 
+	// Create request
 	reqBody := AnthropicRequest{
-		Model: p.model,
-		Messages: []AnthropicMessage{
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
-		Stream: true,
+		Model:     p.model,
+		System:    system,
+		Messages:  messages,
+		MaxTokens: MaxTokens,
+		Stream:    true,
 	}
 
 	// Apply parameters
@@ -222,79 +356,185 @@ func (p *AnthropicProvider) GenerateStream(
 	req.Header.Set("Accept", "text/event-stream")
 
 	// Make request
-	resp, err := p.client.Do(req)
+	resp, err := p.streamClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newAPIError("anthropic", resp)
+	}
 
+	body := newIdleReader(resp.Body, p.streamIdleTimeout)
+	stream := make(chan models.StreamResponse)
 	go func() {
 		defer close(stream)
-		defer resp.Body.Close()
-
-		reader := bufio.NewReader(resp.Body)
-		for {
-			line, err := reader.ReadBytes('\n')
-			if err != nil {
-				if err != io.EOF {
-					stream <- models.StreamResponse{
-						Error: fmt.Errorf("stream read error: %w", err),
-					}
-				}
-				return
+		defer body.Close()
+
+		send := func(msg models.StreamResponse) bool {
+			select {
+			case stream <- msg:
+				return true
+			case <-ctx.Done():
+				return false
 			}
+		}
 
-			// Skip empty lines
-			if len(line) <= 1 {
-				continue
-			}
+		if err := readAnthropicStream(body, send); err != nil {
+			send(models.StreamResponse{Error: err})
+		}
+	}()
 
-			// Parse SSE data
-			if !bytes.HasPrefix(line, []byte("data: ")) {
-				continue
-			}
+	return stream, nil
+}
 
-			data := bytes.TrimPrefix(line, []byte("data: "))
-			if bytes.Equal(data, []byte("[DONE]")) {
-				return
-			}
+// idleReader fails the reads of a response body once no data arrived for the timeout, so a vendor
+// that stalls in the middle of a stream does not hold the call open
+type idleReader struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	expired atomic.Bool
+}
 
-			var streamResp AnthropicStreamResponse
-			if err := json.Unmarshal(data, &streamResp); err != nil {
-				stream <- models.StreamResponse{
-					Error: fmt.Errorf("failed to unmarshal stream response: %w", err),
-				}
-				return
-			}
+func newIdleReader(body io.ReadCloser, timeout time.Duration) *idleReader {
+	r := &idleReader{body: body, timeout: timeout}
+	r.timer = time.AfterFunc(
+		timeout, func() {
+			r.expired.Store(true)
+			body.Close()
+		},
+	)
+	return r
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if r.expired.Load() {
+		return n, fmt.Errorf("no data for %s: %w", r.timeout, os.ErrDeadlineExceeded)
+	}
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
 
-			// Handle errors
-			if streamResp.Error != nil {
-				stream <- models.StreamResponse{
-					Error: fmt.Errorf("anthropic api error: %s", streamResp.Error.Message),
+func (r *idleReader) Close() error {
+	r.timer.Stop()
+	return r.body.Close()
+}
+
+// readAnthropicStream reads the events of a streamed message and sends a chunk for every text delta.
+// The last chunk is marked done and carries the stop reason and the usage. It returns the error of
+// an error event or of a stream that ends before the message is complete.
+func readAnthropicStream(body io.Reader, send func(models.StreamResponse) bool) error {
+	message := &anthropicStream{send: send}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var data []byte
+	for {
+		more := scanner.Scan()
+		line := scanner.Bytes()
+		// Events end with a blank line, their data may span several data lines
+		if more && len(line) > 0 {
+			if value, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+				if len(data) > 0 {
+					data = append(data, '\n')
 				}
-				return
+				data = append(data, bytes.TrimPrefix(value, []byte(" "))...)
 			}
+			continue
+		}
 
-			// Extract content
-			var content string
-			for _, block := range streamResp.Content {
-				if block.Type == "text" {
-					content += block.Text
-				}
+		if len(data) > 0 {
+			done, err := message.handle(data)
+			if done || err != nil {
+				return err
 			}
+			data = data[:0]
+		}
+		if !more {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("stream read error: %w", err)
+	}
+	return fmt.Errorf("stream ended before the message was complete: %w", io.ErrUnexpectedEOF)
+}
+
+// anthropicStream collects the state of a streamed message that is spread across its events
+type anthropicStream struct {
+	send       func(models.StreamResponse) bool
+	id         string
+	stopReason string
+	usage      models.Usage
+}
+
+// handle processes an event and tells whether the stream is over, either because the message is
+// complete or because the receiver went away
+func (s *anthropicStream) handle(data []byte) (bool, error) {
+	var event AnthropicStreamEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return true, fmt.Errorf("failed to unmarshal stream event: %w", err)
+	}
+
+	switch event.Type {
+	case anthropicMessageStart:
+		if event.Message != nil {
+			s.id = event.Message.ID
+			s.usage.PromptTokens = event.Message.Usage.InputTokens
+			s.usage.CompletionTokens = event.Message.Usage.OutputTokens
+		}
 
-			stream <- models.StreamResponse{
-				ID:      uuid.New().String(),
-				Content: content,
-				Done:    streamResp.StopReason != "",
+	case anthropicContentBlockStart:
+		// A text block usually starts empty
+		if block := event.ContentBlock; block != nil && block.Type == "text" && block.Text != "" {
+			return !s.send(models.StreamResponse{ID: s.id, Content: block.Text}), nil
+		}
+
+	case anthropicContentBlockDelta:
+		// Only text is streamed, tool input and thinking are not part of the response
+		if delta := event.Delta; delta != nil && delta.Type == "text_delta" && delta.Text != "" {
+			return !s.send(models.StreamResponse{ID: s.id, Content: delta.Text}), nil
+		}
+
+	case anthropicMessageDelta:
+		if event.Delta != nil && event.Delta.StopReason != "" {
+			s.stopReason = event.Delta.StopReason
+		}
+		// The usage of message_delta events is cumulative
+		if event.Usage != nil {
+			if event.Usage.InputTokens > 0 {
+				s.usage.PromptTokens = event.Usage.InputTokens
 			}
+			s.usage.CompletionTokens = event.Usage.OutputTokens
+		}
 
-			if streamResp.StopReason != "" {
-				return
+	case anthropicMessageStop:
+		usage := s.usage
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+		s.send(models.StreamResponse{ID: s.id, Done: true, FinishReason: s.stopReason, Usage: &usage})
+		return true, nil
+
+	case anthropicError:
+		apiErr := &APIError{Provider: "anthropic", StatusCode: http.StatusInternalServerError}
+		if event.Error != nil {
+			apiErr.Message = event.Error.Type + ": " + event.Error.Message
+			if status, ok := anthropicErrorStatus[event.Error.Type]; ok {
+				apiErr.StatusCode = status
 			}
 		}
-	}()
+		return true, apiErr
 
-	return stream, nil
+	case anthropicContentBlockStop, anthropicPing:
+		// Nothing of the response changes
+
+	default:
+		// Unknown event types are skipped, the API may add new ones
+	}
+	return false, nil
 }
 
 func (p *AnthropicProvider) GetModelInfo() models.ModelInfo {
@@ -316,6 +556,35 @@ func (p *AnthropicProvider) GetModelInfo() models.ModelInfo {
 	}
 }
 
+func (p *AnthropicProvider) ListModels(ctx context.Context) ([]models.ModelInfo, error) {
+	var list struct {
+		Data []struct {
+			ID          string `json:"id"`
+			DisplayName string `json:"display_name"`
+		} `json:"data"`
+	}
+
+	headers := map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": "2023-06-01",
+	}
+	if err := fetchModels(ctx, p.client, p.baseURL+"/models", headers, &list); err != nil {
+		return nil, fmt.Errorf("anthropic %w", err)
+	}
+
+	infos := make([]models.ModelInfo, 0, len(list.Data))
+	for _, model := range list.Data {
+		infos = append(
+			infos, models.ModelInfo{
+				ID:       model.ID,
+				Name:     model.DisplayName,
+				Provider: "Anthropic",
+			},
+		)
+	}
+	return infos, nil
+}
+
 func (p *AnthropicProvider) IsHealthy() bool {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -356,57 +625,3 @@ func (p *AnthropicProvider) applyParameters(req *AnthropicRequest, params map[st
 		req.Stop = stop
 	}
 }
-
-// Add retry mechanism
-func (p *AnthropicProvider) generateWithRetry(
-	ctx context.Context, prompt string, params map[string]interface{}, maxRetries int,
-) (*models.RouteResponse, error) {
-	var lastErr error
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		result, err := p.Generate(ctx, prompt, params)
-		if err == nil {
-			return result, nil
-		}
-
-		lastErr = err
-		if !isRetryableError(err) {
-			return nil, err
-		}
-
-		// Exponential backoff
-		waitTime := time.Duration(math.Pow(2, float64(attempt))) * time.Second
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-time.After(waitTime):
-			continue
-		}
-	}
-	return nil, fmt.Errorf("max retries exceeded: %w", lastErr)
-}
-
-// Helper function to determine if an error is retryable
-func (p *AnthropicProvider) isRetryableError(err error) bool {
-	if err == nil {
-		return false
-	}
-
-	// Check for rate limits and server errors
-	if strings.Contains(err.Error(), "429") || // Rate limit
-		strings.Contains(err.Error(), "500") || // Internal server error
-		strings.Contains(err.Error(), "503") { // Service unavailable
-		return true
-	}
-
-	// Check for context deadline exceeded
-	if errors.Is(err, context.DeadlineExceeded) {
-		return true
-	}
-
-	// Check for network errors
-	if netErr, ok := err.(interface{ Timeout() bool }); ok {
-		return netErr.Timeout()
-	}
-
-	return false
-}
@@ -0,0 +1,193 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"workspace-engine/internal/llm-router/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sentAnthropicRequest holds the fields of a request the tests check
+type sentAnthropicRequest struct {
+	MaxTokens int  `json:"max_tokens"`
+	Stream    bool `json:"stream"`
+}
+
+// newFixtureAnthropicProvider returns a provider whose API streams the recorded fixture
+func newFixtureAnthropicProvider(t *testing.T, fixture string) (*AnthropicProvider, *sentAnthropicRequest) {
+	data, err := os.ReadFile(filepath.Join("testdata", "anthropic", fixture))
+	require.NoError(t, err)
+
+	var sent sentAnthropicRequest
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/messages", r.URL.Path)
+				assert.Equal(t, "test-key", r.Header.Get("x-api-key"))
+				body, _ := io.ReadAll(r.Body)
+				assert.NoError(t, json.Unmarshal(body, &sent))
+
+				w.Header().Set("Content-Type", "text/event-stream")
+				_, _ = w.Write(data)
+			},
+		),
+	)
+	t.Cleanup(server.Close)
+
+	provider := NewAnthropicProvider("test-key", "claude-3-5-sonnet-20241022")
+	provider.baseURL = server.URL
+	return provider, &sent
+}
+
+// drain collects the text of a stream, its last chunk and the first error
+func drain(stream <-chan models.StreamResponse) (string, models.StreamResponse, error) {
+	var text strings.Builder
+	var last models.StreamResponse
+	for chunk := range stream {
+		if chunk.Error != nil {
+			return text.String(), last, chunk.Error
+		}
+		text.WriteString(chunk.Content)
+		last = chunk
+	}
+	return text.String(), last, nil
+}
+
+func TestAnthropicStream(t *testing.T) {
+	t.Run(
+		"Text", func(t *testing.T) {
+			provider, sent := newFixtureAnthropicProvider(t, "text.sse")
+			stream, err := provider.GenerateStream(context.Background(), "Hello", nil)
+			require.NoError(t, err)
+
+			text, last, err := drain(stream)
+			require.NoError(t, err)
+			assert.Equal(t, "Hello! How can I help you today?", text)
+			assert.True(t, last.Done)
+			assert.Equal(t, "msg_01XFDUDYJgAACzvnptvVoYEL", last.ID)
+			assert.Equal(t, "end_turn", last.FinishReason)
+			assert.Equal(t, &models.Usage{PromptTokens: 25, CompletionTokens: 15, TotalTokens: 40}, last.Usage)
+
+			assert.True(t, sent.Stream)
+			assert.Equal(t, MaxTokens, sent.MaxTokens)
+		},
+	)
+
+	t.Run(
+		"MaxTokens", func(t *testing.T) {
+			provider, sent := newFixtureAnthropicProvider(t, "max_tokens.sse")
+			stream, err := provider.GenerateStream(context.Background(), "Tell a story", map[string]interface{}{"maxTokens": 10})
+			require.NoError(t, err)
+
+			text, last, err := drain(stream)
+			require.NoError(t, err)
+			assert.Equal(t, "Once upon a time, in a quiet village", text)
+			assert.Equal(t, "max_tokens", last.FinishReason)
+			assert.Equal(t, 10, last.Usage.CompletionTokens)
+			assert.Equal(t, 10, sent.MaxTokens)
+		},
+	)
+
+	t.Run(
+		"ToolInputIsNotStreamed", func(t *testing.T) {
+			provider, _ := newFixtureAnthropicProvider(t, "tool_use.sse")
+			stream, err := provider.GenerateStream(context.Background(), "Weather?", nil)
+			require.NoError(t, err)
+
+			text, last, err := drain(stream)
+			require.NoError(t, err)
+			assert.Equal(t, "Let me check the weather.", text)
+			assert.Equal(t, "tool_use", last.FinishReason)
+			assert.Equal(t, 561, last.Usage.TotalTokens)
+		},
+	)
+
+	t.Run(
+		"ErrorEvent", func(t *testing.T) {
+			provider, _ := newFixtureAnthropicProvider(t, "overloaded.sse")
+			stream, err := provider.GenerateStream(context.Background(), "Hello", nil)
+			require.NoError(t, err)
+
+			text, _, err := drain(stream)
+			assert.Equal(t, "The", text)
+			var apiErr *APIError
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, 529, apiErr.StatusCode)
+			assert.Contains(t, apiErr.Message, "Overloaded")
+		},
+	)
+
+	t.Run(
+		"TruncatedStream", func(t *testing.T) {
+			provider, _ := newFixtureAnthropicProvider(t, "truncated.sse")
+			stream, err := provider.GenerateStream(context.Background(), "Hello", nil)
+			require.NoError(t, err)
+
+			text, last, err := drain(stream)
+			assert.Equal(t, "Partial", text)
+			assert.False(t, last.Done)
+			assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		},
+	)
+
+	t.Run(
+		"ReceiverGoesAway", func(t *testing.T) {
+			provider, _ := newFixtureAnthropicProvider(t, "text.sse")
+			ctx, cancel := context.WithCancel(context.Background())
+			stream, err := provider.GenerateStream(ctx, "Hello", nil)
+			require.NoError(t, err)
+
+			<-stream
+			cancel()
+			for range stream {
+			}
+		},
+	)
+
+	t.Run(
+		"StalledStream", func(t *testing.T) {
+			release := make(chan struct{})
+			server := httptest.NewServer(
+				http.HandlerFunc(
+					func(w http.ResponseWriter, r *http.Request) {
+						// The API of /stalled answers and then stalls, the other one never answers
+						if r.URL.Path == "/stalled/messages" {
+							w.Header().Set("Content-Type", "text/event-stream")
+							w.WriteHeader(http.StatusOK)
+							_, _ = io.WriteString(w, "event: ping\ndata: {\"type\": \"ping\"}\n\n")
+							w.(http.Flusher).Flush()
+						}
+						<-release
+					},
+				),
+			)
+			t.Cleanup(server.Close)
+			t.Cleanup(func() { close(release) })
+
+			provider := NewAnthropicProvider("test-key", "claude-3-5-sonnet-20241022")
+			provider.streamClient = newStreamHTTPClient(50 * time.Millisecond)
+			provider.streamIdleTimeout = 50 * time.Millisecond
+
+			provider.baseURL = server.URL
+			_, err := provider.GenerateStream(context.Background(), "Hello", nil)
+			assert.Error(t, err, "the response headers never arrive")
+
+			provider.baseURL = server.URL + "/stalled"
+			stream, err := provider.GenerateStream(context.Background(), "Hello", nil)
+			require.NoError(t, err)
+			_, _, err = drain(stream)
+			assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+		},
+	)
+}
@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"workspace-engine/internal/llm-router/models"
+)
+
+// ChatContent is the content of an OpenAI compatible chat message. It is sent as a plain string
+// unless the message has parts.
+type ChatContent struct {
+	Text  string
+	Parts []ChatContentPart
+}
+
+type ChatContentPart struct {
+	Type     string        `json:"type"`
+	Text     string        `json:"text,omitempty"`
+	ImageURL *ChatImageURL `json:"image_url,omitempty"`
+	File     *ChatFile     `json:"file,omitempty"`
+}
+
+type ChatImageURL struct {
+	URL string `json:"url"`
+}
+
+type ChatFile struct {
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data"`
+}
+
+func (c ChatContent) MarshalJSON() ([]byte, error) {
+	if len(c.Parts) > 0 {
+		return json.Marshal(c.Parts)
+	}
+	return json.Marshal(c.Text)
+}
+
+func (c *ChatContent) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &c.Text); err == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(data, &c.Parts); err != nil {
+		return err
+	}
+	var sb strings.Builder
+	for _, part := range c.Parts {
+		sb.WriteString(part.Text)
+	}
+	c.Text = sb.String()
+	return nil
+}
+
+// history returns the conversation the router passed along with the prompt
+func history(params map[string]interface{}) []models.Message {
+	messages, _ := params["messages"].([]models.Message)
+	return messages
+}
+
+// contentParts returns the multimodal parts the router passed along with the prompt
+func contentParts(params map[string]interface{}) []models.ContentPart {
+	parts, _ := params["content"].([]models.ContentPart)
+	return parts
+}
+
+// newChatContent builds the content of an OpenAI compatible user message. Providers that cannot
+// read documents pass false for documents.
+func newChatContent(prompt string, parts []models.ContentPart, documents bool) (ChatContent, error) {
+	if len(parts) == 0 {
+		return ChatContent{Text: prompt}, nil
+	}
+
+	content := ChatContent{Text: prompt}
+	if prompt != "" {
+		content.Parts = append(content.Parts, ChatContentPart{Type: "text", Text: prompt})
+	}
+	for _, part := range parts {
+		switch part.Type {
+		case models.ContentPartText:
+			content.Parts = append(content.Parts, ChatContentPart{Type: "text", Text: part.Text})
+		case models.ContentPartImage:
+			content.Parts = append(
+				content.Parts, ChatContentPart{Type: "image_url", ImageURL: &ChatImageURL{URL: partURL(part)}},
+			)
+		case models.ContentPartDocument:
+			if !documents {
+				return ChatContent{}, fmt.Errorf("document content is not supported")
+			}
+			content.Parts = append(content.Parts, ChatContentPart{Type: "file", File: &ChatFile{FileData: partURL(part)}})
+		default:
+			return ChatContent{}, fmt.Errorf("unsupported content part type: %s", part.Type)
+		}
+	}
+	return content, nil
+}
+
+// partURL returns the URL of a part, inlining base64 data as a data URL
+func partURL(part models.ContentPart) string {
+	if part.URL != "" {
+		return part.URL
+	}
+	return "data:" + part.MediaType + ";base64," + part.Data
+}
@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"encoding/json"
+	"testing"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentParts(t *testing.T) {
+	parts := []models.ContentPart{
+		{Type: models.ContentPartImage, URL: "https://example.com/screenshot.png"},
+		{Type: models.ContentPartDocument, Data: "JVBERi0=", MediaType: "application/pdf"},
+	}
+
+	t.Run(
+		"PlainPrompt", func(t *testing.T) {
+			content, err := newChatContent("Hello", nil, false)
+			require.NoError(t, err)
+			body, err := json.Marshal(content)
+			require.NoError(t, err)
+			assert.JSONEq(t, `"Hello"`, string(body))
+		},
+	)
+
+	t.Run(
+		"ChatParts", func(t *testing.T) {
+			content, err := newChatContent("Describe", parts, true)
+			require.NoError(t, err)
+			body, err := json.Marshal(content)
+			require.NoError(t, err)
+			assert.JSONEq(
+				t, `[
+					{"type": "text", "text": "Describe"},
+					{"type": "image_url", "image_url": {"url": "https://example.com/screenshot.png"}},
+					{"type": "file", "file": {"file_data": "data:application/pdf;base64,JVBERi0="}}
+				]`, string(body),
+			)
+
+			_, err = newChatContent("Describe", parts, false)
+			assert.Error(t, err, "documents are rejected by providers that cannot read them")
+		},
+	)
+
+	t.Run(
+		"ChatResponse", func(t *testing.T) {
+			var message GroqMessage
+			require.NoError(t, json.Unmarshal([]byte(`{"role": "assistant", "content": "Hi"}`), &message))
+			assert.Equal(t, "Hi", message.Content.Text)
+		},
+	)
+
+	t.Run(
+		"AnthropicBlocks", func(t *testing.T) {
+			content, err := newAnthropicContent("Describe", parts)
+			require.NoError(t, err)
+			body, err := json.Marshal(content)
+			require.NoError(t, err)
+			assert.JSONEq(
+				t, `[
+					{"type": "text", "text": "Describe"},
+					{"type": "image", "source": {"type": "url", "url": "https://example.com/screenshot.png"}},
+					{"type": "document", "source": {"type": "base64", "media_type": "application/pdf", "data": "JVBERi0="}}
+				]`, string(body),
+			)
+		},
+	)
+
+	t.Run(
+		"OpenAIMultiContent", func(t *testing.T) {
+			message, err := newOpenAIUserMessage("Describe", parts[:1])
+			require.NoError(t, err)
+			require.Len(t, message.MultiContent, 2)
+			assert.Equal(t, "https://example.com/screenshot.png", message.MultiContent[1].ImageURL.URL)
+		},
+	)
+
+	t.Run(
+		"SupportedParts", func(t *testing.T) {
+			openai := NewTracedProvider(NewRetryProvider(NewOpenAIProvider("sk", "gpt-4o"), config.RetryConfig{}))
+			assert.True(t, supportsContent(openai, models.ContentPartImage))
+			assert.False(t, supportsContent(openai, models.ContentPartDocument))
+			assert.False(t, supportsContent(NewGroqProvider("gsk", "llama"), models.ContentPartDocument))
+			assert.True(t, supportsContent(NewAnthropicProvider("sk", "claude"), models.ContentPartDocument))
+		},
+	)
+}
+
+func TestHistory(t *testing.T) {
+	params := map[string]interface{}{
+		"messages": []models.Message{
+			{Role: models.MessageRoleSystem, Content: "Be brief"},
+			{Role: models.MessageRoleUser, Content: "I am Ada"},
+			{Role: models.MessageRoleAssistant, Content: "Hi Ada"},
+		},
+	}
+
+	t.Run(
+		"OpenAICompatible", func(t *testing.T) {
+			messages, err := newGroqMessages("What is my name?", params)
+			require.NoError(t, err)
+
+			body, err := json.Marshal(messages)
+			require.NoError(t, err)
+			assert.JSONEq(
+				t, `[
+					{"role": "system", "content": "Be brief"},
+					{"role": "user", "content": "I am Ada"},
+					{"role": "assistant", "content": "Hi Ada"},
+					{"role": "user", "content": "What is my name?"}
+				]`, string(body),
+			)
+		},
+	)
+
+	t.Run(
+		"AnthropicSystem", func(t *testing.T) {
+			system, messages, err := newAnthropicMessages("What is my name?", params)
+			require.NoError(t, err)
+			assert.Equal(t, "Be brief", system)
+
+			body, err := json.Marshal(messages)
+			require.NoError(t, err)
+			assert.JSONEq(
+				t, `[
+					{"role": "user", "content": "I am Ada"},
+					{"role": "assistant", "content": "Hi Ada"},
+					{"role": "user", "content": "What is my name?"}
+				]`, string(body),
+			)
+		},
+	)
+}
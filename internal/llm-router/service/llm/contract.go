@@ -14,3 +14,40 @@ type Provider interface {
 	GetModelInfo() models.ModelInfo
 	IsHealthy() bool
 }
+
+// StructuredOutputProvider is implemented by providers that can constrain their output to JSON natively.
+// StructuredOutputMode returns the most specific response format the provider supports, either
+// models.ResponseFormatJSONSchema or models.ResponseFormatJSONObject.
+type StructuredOutputProvider interface {
+	StructuredOutputMode() string
+}
+
+// structuredOutputMode returns the native JSON mode of a provider, decorators use it to forward the
+// mode of the provider they wrap
+func structuredOutputMode(provider Provider) string {
+	if structured, ok := provider.(StructuredOutputProvider); ok {
+		return structured.StructuredOutputMode()
+	}
+	return ""
+}
+
+// ContentProvider is implemented by providers that can only send some types of content parts to
+// their vendor. Providers without it accept every type.
+type ContentProvider interface {
+	SupportsContent(partType string) bool
+}
+
+// supportsContent reports whether a provider accepts a type of content part, decorators use it to
+// forward the support of the provider they wrap
+func supportsContent(provider Provider, partType string) bool {
+	if content, ok := provider.(ContentProvider); ok {
+		return content.SupportsContent(partType)
+	}
+	return true
+}
+
+// ModelLister is implemented by providers that can discover the models available from their vendor.
+// Prices are per 1K tokens; zero values mean the vendor does not report them.
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]models.ModelInfo, error)
+}
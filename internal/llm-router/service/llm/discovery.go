@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// fetchModels performs a GET on a vendor models endpoint and decodes the JSON response into out
+func fetchModels(
+	ctx context.Context, client *http.Client, url string, headers map[string]string, out interface{},
+) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("models api error: status %d: %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// perThousandTokens converts a per token price reported as a decimal string to a price per 1K tokens
+func perThousandTokens(price string) float64 {
+	value, err := strconv.ParseFloat(price, 64)
+	if err != nil {
+		return 0
+	}
+	return value * 1000
+}
@@ -0,0 +1,117 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// APIError is returned by providers when the vendor API answers with an error status
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+	// RetryAfter is the delay requested by the Retry-After header, zero when there is none
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s api error: status %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// newAPIError reads the error response of a vendor API
+func newAPIError(provider string, resp *http.Response) *APIError {
+	body, _ := io.ReadAll(resp.Body)
+	return &APIError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Message:    string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// retryAfterError adds the delay of a Retry-After header to the error of a client library that drops
+// the headers of error responses
+type retryAfterError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
+
+// retryAfter returns the delay the vendor asked for with an error, zero when it asked for none
+func retryAfter(err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	var delayed *retryAfterError
+	if errors.As(err, &delayed) {
+		return delayed.retryAfter
+	}
+	return 0
+}
+
+// retryAfterHeader receives the Retry-After header of the error response of a call made through
+// retryAfterTransport
+type retryAfterHeader struct {
+	delay time.Duration
+}
+
+type retryAfterKey struct{}
+
+// withRetryAfterHeader returns a context whose calls through retryAfterTransport store the
+// Retry-After header of their error response in the returned header
+func withRetryAfterHeader(ctx context.Context) (context.Context, *retryAfterHeader) {
+	header := &retryAfterHeader{}
+	return context.WithValue(ctx, retryAfterKey{}, header), header
+}
+
+// wrap adds the delay the vendor asked for to the error of the call
+func (h *retryAfterHeader) wrap(err error) error {
+	if h.delay <= 0 {
+		return err
+	}
+	return &retryAfterError{err: err, retryAfter: h.delay}
+}
+
+// retryAfterTransport stores the Retry-After header of error responses in the retryAfterHeader of
+// the request context
+type retryAfterTransport struct {
+	base http.RoundTripper
+}
+
+func (t retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode < http.StatusBadRequest {
+		return resp, err
+	}
+	if header, ok := req.Context().Value(retryAfterKey{}).(*retryAfterHeader); ok {
+		header.delay = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return resp, nil
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
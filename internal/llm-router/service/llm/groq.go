@@ -5,12 +5,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
-	"strings"
 	"time"
 
 	"workspace-engine/internal/llm-router/models"
@@ -34,11 +31,18 @@ type GroqRequest struct {
 	TopP        float32       `json:"top_p,omitempty"`
 	Stream      bool          `json:"stream,omitempty"`
 	Stop        []string      `json:"stop,omitempty"`
+	// ResponseFormat enables the OpenAI compatible JSON mode
+	ResponseFormat *JSONResponseFormat `json:"response_format,omitempty"`
+}
+
+// JSONResponseFormat is the response_format field of OpenAI compatible chat completion APIs
+type JSONResponseFormat struct {
+	Type string `json:"type"`
 }
 
 type GroqMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string      `json:"role"`
+	Content ChatContent `json:"content"`
 }
 
 type GroqResponse struct {
@@ -66,9 +70,7 @@ func NewGroqProvider(apiKey, model string) *GroqProvider {
 	return &GroqProvider{
 		apiKey: apiKey,
 		model:  model,
-		client: &http.Client{
-			Timeout: 60 * time.Second,
-		},
+		client: newHTTPClient(60 * time.Second),
 	}
 }
 
@@ -93,15 +95,15 @@ func (p *GroqProvider) Generate(
 		}
 	}
 
+	messages, err := newGroqMessages(prompt, params)
+	if err != nil {
+		return nil, fmt.Errorf("groq: %w", err)
+	}
+
 	// Create request
 	reqBody := GroqRequest{
-		Model: p.model,
-		Messages: []GroqMessage{
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
+		Model:       p.model,
+		Messages:    messages,
 		MaxTokens:   maxTokens,
 		Temperature: temperature,
 		TopP:        topP,
@@ -112,6 +114,11 @@ func (p *GroqProvider) Generate(
 		reqBody.Stop = stopSequences
 	}
 
+	// Request JSON output if asked for
+	if _, ok := params["responseFormat"].(*models.ResponseFormat); ok {
+		reqBody.ResponseFormat = &JSONResponseFormat{Type: models.ResponseFormatJSONObject}
+	}
+
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
@@ -135,8 +142,7 @@ func (p *GroqProvider) Generate(
 
 	// Handle non-200 responses
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("groq api error: status %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError("groq", resp)
 	}
 
 	// Parse response
@@ -152,7 +158,7 @@ func (p *GroqProvider) Generate(
 	// Create response
 	result := &models.RouteResponse{
 		ID:     groqResp.ID,
-		Result: groqResp.Choices[0].Message.Content,
+		Result: groqResp.Choices[0].Message.Content.Text,
 		Model:  groqResp.Model,
 		Usage: models.Usage{
 			PromptTokens:     groqResp.Usage.PromptTokens,
@@ -175,15 +181,16 @@ func (p *GroqProvider) GenerateStream(
 ) (<-chan models.StreamResponse, error) {
 	stream := make(chan models.StreamResponse)
 
+	messages, err := newGroqMessages(prompt, params)
+	if err != nil {
+		return nil, fmt.Errorf("groq: %w", err)
+	}
+
+	// Create request
 	reqBody := GroqRequest{
-		Model: p.model,
-		Messages: []GroqMessage{
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
-		Stream: true,
+		Model:    p.model,
+		Messages: messages,
+		Stream:   true,
 	}
 
 	// Apply parameters
@@ -208,6 +215,10 @@ func (p *GroqProvider) GenerateStream(
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newAPIError("groq", resp)
+	}
 
 	go func() {
 		defer close(stream)
@@ -249,7 +260,7 @@ func (p *GroqProvider) GenerateStream(
 			if len(streamResp.Choices) > 0 {
 				stream <- models.StreamResponse{
 					ID:      streamResp.ID,
-					Content: streamResp.Choices[0].Message.Content,
+					Content: streamResp.Choices[0].Message.Content.Text,
 					Done:    streamResp.Choices[0].FinishReason != "",
 				}
 
@@ -281,6 +292,42 @@ func (p *GroqProvider) GetModelInfo() models.ModelInfo {
 	}
 }
 
+func (p *GroqProvider) StructuredOutputMode() string {
+	return models.ResponseFormatJSONObject
+}
+
+// SupportsContent accepts text and image parts, Groq reads no documents
+func (p *GroqProvider) SupportsContent(partType string) bool {
+	return partType == models.ContentPartText || partType == models.ContentPartImage
+}
+
+func (p *GroqProvider) ListModels(ctx context.Context) ([]models.ModelInfo, error) {
+	var list struct {
+		Data []struct {
+			ID            string `json:"id"`
+			ContextWindow int    `json:"context_window"`
+		} `json:"data"`
+	}
+
+	headers := map[string]string{"Authorization": "Bearer " + p.apiKey}
+	if err := fetchModels(ctx, p.client, groqBaseURL+"/models", headers, &list); err != nil {
+		return nil, fmt.Errorf("groq %w", err)
+	}
+
+	infos := make([]models.ModelInfo, 0, len(list.Data))
+	for _, model := range list.Data {
+		infos = append(
+			infos, models.ModelInfo{
+				ID:        model.ID,
+				Name:      model.ID,
+				Provider:  "groq",
+				MaxTokens: model.ContextWindow,
+			},
+		)
+	}
+	return infos, nil
+}
+
 func (p *GroqProvider) IsHealthy() bool {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -318,57 +365,21 @@ func (p *GroqProvider) applyParameters(req *GroqRequest, params map[string]inter
 	if stop, ok := params["stopSequences"].([]string); ok {
 		req.Stop = stop
 	}
-}
-
-// Helper function to handle retries
-func (p *GroqProvider) generateWithRetry(
-	ctx context.Context, prompt string, params map[string]interface{}, maxRetries int,
-) (*models.RouteResponse, error) {
-	var lastErr error
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		result, err := p.Generate(ctx, prompt, params)
-		if err == nil {
-			return result, nil
-		}
-
-		lastErr = err
-		if !p.isRetryableError(err) {
-			return nil, err
-		}
-
-		// Exponential backoff
-		waitTime := time.Duration(math.Pow(2, float64(attempt))) * time.Second
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-time.After(waitTime):
-			continue
-		}
+	if _, ok := params["responseFormat"].(*models.ResponseFormat); ok {
+		req.ResponseFormat = &JSONResponseFormat{Type: models.ResponseFormatJSONObject}
 	}
-	return nil, fmt.Errorf("max retries exceeded: %w", lastErr)
 }
 
-func (p *GroqProvider) isRetryableError(err error) bool {
-	if err == nil {
-		return false
-	}
-
-	// Check for rate limits and server errors
-	if strings.Contains(err.Error(), "429") || // Rate limit
-		strings.Contains(err.Error(), "500") || // Internal server error
-		strings.Contains(err.Error(), "503") { // Service unavailable
-		return true
-	}
-
-	// Check for context deadline exceeded
-	if errors.Is(err, context.DeadlineExceeded) {
-		return true
+// newGroqMessages builds the conversation history followed by the user message
+func newGroqMessages(prompt string, params map[string]interface{}) ([]GroqMessage, error) {
+	content, err := newChatContent(prompt, contentParts(params), false)
+	if err != nil {
+		return nil, err
 	}
 
-	// Check for network errors
-	if netErr, ok := err.(interface{ Timeout() bool }); ok {
-		return netErr.Timeout()
+	var messages []GroqMessage
+	for _, m := range history(params) {
+		messages = append(messages, GroqMessage{Role: m.Role, Content: ChatContent{Text: m.Content}})
 	}
-
-	return false
+	return append(messages, GroqMessage{Role: "user", Content: content}), nil
 }
@@ -0,0 +1,380 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/telemetry"
+	"workspace-engine/pkg/logger"
+
+	openai "github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const defaultKeyCooldown = time.Minute
+
+// PooledKey is a client of a vendor that authenticates with one key of a key pool
+type PooledKey struct {
+	Name     string
+	Weight   int
+	Provider Provider
+}
+
+// KeyPoolProvider spreads the calls of a model over several API keys of a vendor, so the calls
+// share the rate limits of all keys. A key that is rate limited cools down and the call moves on
+// to the next key; the rate limit error is only returned when every key has been tried. The pools
+// of the models of a vendor share the state of its keys, see ForModel.
+type KeyPoolProvider struct {
+	keys   []*poolKey
+	policy config.KeyRotationConfig
+
+	// mu guards the rotation and cooldown state of the keys, it is shared with the pools of the
+	// other models
+	mu *sync.Mutex
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// poolKey is a key of a pool with the client of the model of the pool
+type poolKey struct {
+	PooledKey
+	*keyState
+}
+
+// keyState is the rotation, cooldown and usage state of a key of a vendor
+type keyState struct {
+	// current is the running weight of the smooth weighted round robin
+	current         int
+	coolingUntil    time.Time
+	lastRateLimited time.Time
+	// stats is swapped when a reloaded pool takes over the statistics of its predecessor
+	stats atomic.Pointer[keyStats]
+}
+
+// keyStats counts the calls made with a key
+type keyStats struct {
+	requests         atomic.Int64
+	rateLimited      atomic.Int64
+	failures         atomic.Int64
+	promptTokens     atomic.Int64
+	completionTokens atomic.Int64
+}
+
+// NewKeyPoolProvider creates a pool of the given keys, zero fields of the policy and zero weights
+// take the defaults
+func NewKeyPoolProvider(keys []PooledKey, policy config.KeyRotationConfig) (*KeyPoolProvider, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("key pool needs at least one key")
+	}
+	if policy.Strategy == "" {
+		policy.Strategy = config.KeyRotationRoundRobin
+	}
+	if policy.Cooldown <= 0 {
+		policy.Cooldown = defaultKeyCooldown
+	}
+
+	pool := &KeyPoolProvider{policy: policy, mu: &sync.Mutex{}, now: time.Now}
+	for _, key := range keys {
+		if key.Weight <= 0 {
+			key.Weight = 1
+		}
+		pooled := &poolKey{PooledKey: key, keyState: &keyState{}}
+		pooled.stats.Store(&keyStats{})
+		pool.keys = append(pool.keys, pooled)
+	}
+	return pool, nil
+}
+
+// ForModel returns a pool of the same keys whose calls go through the clients of another model,
+// given in the order of the keys. The pools share the rotation, cooldowns and statistics of the
+// keys, so a key the vendor rate limits cools down for every model.
+func (p *KeyPoolProvider) ForModel(clients []Provider) (*KeyPoolProvider, error) {
+	if len(clients) != len(p.keys) {
+		return nil, fmt.Errorf("key pool has %d keys but got %d clients", len(p.keys), len(clients))
+	}
+
+	pool := &KeyPoolProvider{policy: p.policy, mu: p.mu, now: p.now}
+	for i, key := range p.keys {
+		pool.keys = append(
+			pool.keys, &poolKey{
+				PooledKey: PooledKey{Name: key.Name, Weight: key.Weight, Provider: clients[i]},
+				keyState:  key.keyState,
+			},
+		)
+	}
+	return pool, nil
+}
+
+func (p *KeyPoolProvider) Generate(
+	ctx context.Context, prompt string, params map[string]interface{},
+) (*models.RouteResponse, error) {
+	return withPooledKey(
+		ctx, p, func(key *poolKey) (*models.RouteResponse, error) {
+			resp, err := key.Provider.Generate(ctx, prompt, params)
+			if err == nil {
+				stats := key.stats.Load()
+				stats.promptTokens.Add(int64(resp.Usage.PromptTokens))
+				stats.completionTokens.Add(int64(resp.Usage.CompletionTokens))
+			}
+			return resp, err
+		},
+	)
+}
+
+// GenerateStream moves on to the next key while the stream is being established, a stream that
+// is rate limited midway is not restarted
+func (p *KeyPoolProvider) GenerateStream(
+	ctx context.Context, prompt string, params map[string]interface{},
+) (<-chan models.StreamResponse, error) {
+	return withPooledKey(
+		ctx, p, func(key *poolKey) (<-chan models.StreamResponse, error) {
+			return key.Provider.GenerateStream(ctx, prompt, params)
+		},
+	)
+}
+
+func (p *KeyPoolProvider) GetModelInfo() models.ModelInfo {
+	return p.keys[0].Provider.GetModelInfo()
+}
+
+// IsHealthy reports whether any key of the pool is healthy
+func (p *KeyPoolProvider) IsHealthy() bool {
+	for _, key := range p.keys {
+		if key.Provider.IsHealthy() {
+			return true
+		}
+	}
+	return false
+}
+
+// StructuredOutputMode forwards the native JSON mode of the clients, they all talk to one vendor
+func (p *KeyPoolProvider) StructuredOutputMode() string {
+	return structuredOutputMode(p.keys[0].Provider)
+}
+
+// SupportsContent forwards the content support of the clients, they all talk to one vendor
+func (p *KeyPoolProvider) SupportsContent(partType string) bool {
+	return supportsContent(p.keys[0].Provider, partType)
+}
+
+// ListModels lists the models of the vendor with the next key of the pool
+func (p *KeyPoolProvider) ListModels(ctx context.Context) ([]models.ModelInfo, error) {
+	return withPooledKey(
+		ctx, p, func(key *poolKey) ([]models.ModelInfo, error) {
+			lister, ok := key.Provider.(ModelLister)
+			if !ok {
+				return nil, fmt.Errorf("provider of key %s cannot list models", key.Name)
+			}
+			return lister.ListModels(ctx)
+		},
+	)
+}
+
+// Keys returns the usage and cooldown state of every key of the pool, counted over all models of
+// the vendor
+func (p *KeyPoolProvider) Keys() []models.ProviderKeyStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	statuses := make([]models.ProviderKeyStatus, 0, len(p.keys))
+	for _, key := range p.keys {
+		stats := key.stats.Load()
+		status := models.ProviderKeyStatus{
+			Name:             key.Name,
+			Weight:           key.Weight,
+			Requests:         stats.requests.Load(),
+			RateLimited:      stats.rateLimited.Load(),
+			Failures:         stats.failures.Load(),
+			PromptTokens:     stats.promptTokens.Load(),
+			CompletionTokens: stats.completionTokens.Load(),
+		}
+		if key.coolingUntil.After(now) {
+			status.CoolingDownUntil = key.coolingUntil.UTC().Format(time.RFC3339)
+		}
+		if !key.lastRateLimited.IsZero() {
+			status.LastRateLimited = key.lastRateLimited.UTC().Format(time.RFC3339)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Inherit takes over the statistics and cooldowns of the keys of a previous pool that have the
+// same name, so they survive configuration reloads
+func (p *KeyPoolProvider) Inherit(previous *KeyPoolProvider) {
+	type state struct {
+		coolingUntil    time.Time
+		lastRateLimited time.Time
+		stats           *keyStats
+	}
+	previous.mu.Lock()
+	byName := make(map[string]state, len(previous.keys))
+	for _, key := range previous.keys {
+		byName[key.Name] = state{key.coolingUntil, key.lastRateLimited, key.stats.Load()}
+	}
+	previous.mu.Unlock()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, key := range p.keys {
+		if old, ok := byName[key.Name]; ok {
+			key.coolingUntil = old.coolingUntil
+			key.lastRateLimited = old.lastRateLimited
+			key.stats.Store(old.stats)
+		}
+	}
+}
+
+// withPooledKey makes a call with the next key of the pool and repeats it with the following keys
+// as long as the vendor answers with a rate limit
+func withPooledKey[T any](ctx context.Context, p *KeyPoolProvider, call func(key *poolKey) (T, error)) (T, error) {
+	tried := make(map[*poolKey]bool, len(p.keys))
+	var result T
+	var err error
+	for key := p.pick(tried); key != nil; key = p.pick(tried) {
+		tried[key] = true
+		trace.SpanFromContext(ctx).SetAttributes(telemetry.AttrAPIKeyName.String(key.Name))
+
+		result, err = call(key)
+		stats := key.stats.Load()
+		stats.requests.Add(1)
+		if err == nil {
+			return result, nil
+		}
+
+		retryAfter, limited := rateLimited(err)
+		if !limited {
+			stats.failures.Add(1)
+			return result, err
+		}
+		stats.rateLimited.Add(1)
+		p.coolDown(key, retryAfter)
+
+		if ctx.Err() != nil {
+			return result, err
+		}
+		logger.DebugContext(ctx, "API key is rate limited", "key", key.Name, "error", err)
+	}
+	return result, err
+}
+
+// pick returns the next key that has not been tried and is not cooling down. When every key cools
+// down, the first call goes to the key whose cooldown ends first and further calls are not made.
+// It returns nil when no key is left.
+func (p *KeyPoolProvider) pick(tried map[*poolKey]bool) *poolKey {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	var available []*poolKey
+	var soonest *poolKey
+	for _, key := range p.keys {
+		if tried[key] {
+			continue
+		}
+		if !key.coolingUntil.After(now) {
+			available = append(available, key)
+		} else if soonest == nil || key.coolingUntil.Before(soonest.coolingUntil) {
+			soonest = key
+		}
+	}
+	if len(available) == 0 {
+		if len(tried) > 0 {
+			return nil
+		}
+		return soonest
+	}
+
+	if p.policy.Strategy == config.KeyRotationLeastRateLimited {
+		available = leastRateLimited(available)
+	}
+	return nextWeighted(available)
+}
+
+// leastRateLimited returns the keys that were rate limited longest ago, keys that never were come
+// first
+func leastRateLimited(keys []*poolKey) []*poolKey {
+	oldest := keys[0].lastRateLimited
+	for _, key := range keys[1:] {
+		if key.lastRateLimited.Before(oldest) {
+			oldest = key.lastRateLimited
+		}
+	}
+
+	var least []*poolKey
+	for _, key := range keys {
+		if key.lastRateLimited.Equal(oldest) {
+			least = append(least, key)
+		}
+	}
+	return least
+}
+
+// nextWeighted picks a key with the smooth weighted round robin, which interleaves the keys in
+// proportion to their weights
+func nextWeighted(keys []*poolKey) *poolKey {
+	total := 0
+	var best *poolKey
+	for _, key := range keys {
+		key.current += key.Weight
+		total += key.Weight
+		if best == nil || key.current > best.current {
+			best = key
+		}
+	}
+	best.current -= total
+	return best
+}
+
+// coolDown skips a rate limited key for the delay requested by the vendor or the configured cooldown
+func (p *KeyPoolProvider) coolDown(key *poolKey, retryAfter time.Duration) {
+	if retryAfter <= 0 {
+		retryAfter = p.policy.Cooldown
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	key.lastRateLimited = now
+	key.coolingUntil = now.Add(retryAfter)
+}
+
+// rateLimited tells whether the vendor rejected a call because its key exceeded a rate limit, and
+// the delay the vendor asked for, if any
+func rateLimited(err error) (time.Duration, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter, apiErr.StatusCode == http.StatusTooManyRequests
+	}
+	var openAIErr *openai.APIError
+	if errors.As(err, &openAIErr) {
+		return retryAfter(err), openAIErr.HTTPStatusCode == http.StatusTooManyRequests
+	}
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return retryAfter(err), requestErr.HTTPStatusCode == http.StatusTooManyRequests
+	}
+	return 0, false
+}
+
+// KeyPoolOf returns the key pool a provider wraps, looking through the retry and tracing decorators
+func KeyPoolOf(provider Provider) (*KeyPoolProvider, bool) {
+	for {
+		switch p := provider.(type) {
+		case *KeyPoolProvider:
+			return p, true
+		case interface{ Unwrap() Provider }:
+			provider = p.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+}
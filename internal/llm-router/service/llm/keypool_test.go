@@ -0,0 +1,224 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// namedProvider answers with the name of its key and fails with the given errors first
+type namedProvider struct {
+	flakyProvider
+	name string
+}
+
+func (p *namedProvider) Generate(
+	ctx context.Context, prompt string, params map[string]interface{},
+) (*models.RouteResponse, error) {
+	resp, err := p.flakyProvider.Generate(ctx, prompt, params)
+	if err != nil {
+		return nil, err
+	}
+	resp.Result = p.name
+	return resp, nil
+}
+
+func newTestKeyPool(
+	t *testing.T, policy config.KeyRotationConfig, keys ...PooledKey,
+) (*KeyPoolProvider, *time.Time) {
+	pool, err := NewKeyPoolProvider(keys, policy)
+	require.NoError(t, err)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	pool.now = func() time.Time { return now }
+	return pool, &now
+}
+
+func generateWithPool(t *testing.T, pool *KeyPoolProvider, calls int) []string {
+	var used []string
+	for i := 0; i < calls; i++ {
+		resp, err := pool.Generate(context.Background(), "hi", nil)
+		require.NoError(t, err)
+		used = append(used, resp.Result)
+	}
+	return used
+}
+
+func TestKeyPoolProvider(t *testing.T) {
+	rateLimit := &APIError{Provider: "stub", StatusCode: http.StatusTooManyRequests}
+
+	t.Run(
+		"RotatesByWeight", func(t *testing.T) {
+			pool, _ := newTestKeyPool(
+				t, config.KeyRotationConfig{},
+				PooledKey{Name: "a", Weight: 2, Provider: &namedProvider{name: "a"}},
+				PooledKey{Name: "b", Provider: &namedProvider{name: "b"}},
+			)
+			assert.Equal(t, []string{"a", "b", "a", "a", "b", "a"}, generateWithPool(t, pool, 6))
+		},
+	)
+
+	t.Run(
+		"RateLimitedKeysCoolDown", func(t *testing.T) {
+			limited := &namedProvider{name: "a", flakyProvider: flakyProvider{errs: []error{rateLimit}}}
+			pool, now := newTestKeyPool(
+				t, config.KeyRotationConfig{Cooldown: time.Minute},
+				PooledKey{Name: "a", Provider: limited},
+				PooledKey{Name: "b", Provider: &namedProvider{name: "b"}},
+			)
+
+			assert.Equal(t, []string{"b", "b", "b"}, generateWithPool(t, pool, 3))
+			assert.Equal(t, 1, limited.calls)
+
+			keys := pool.Keys()
+			assert.EqualValues(t, 1, keys[0].RateLimited)
+			assert.NotEmpty(t, keys[0].CoolingDownUntil)
+			assert.EqualValues(t, 3, keys[1].Requests)
+			assert.EqualValues(t, 9, keys[1].PromptTokens)
+
+			*now = now.Add(time.Minute)
+			assert.Contains(t, generateWithPool(t, pool, 2), "a")
+			assert.Empty(t, pool.Keys()[0].CoolingDownUntil)
+		},
+	)
+
+	t.Run(
+		"RetryAfterSetsTheCooldown", func(t *testing.T) {
+			limited := &namedProvider{
+				name:          "a",
+				flakyProvider: flakyProvider{errs: []error{&APIError{StatusCode: 429, RetryAfter: 5 * time.Second}}},
+			}
+			pool, now := newTestKeyPool(
+				t, config.KeyRotationConfig{Cooldown: time.Hour},
+				PooledKey{Name: "a", Provider: limited},
+				PooledKey{Name: "b", Provider: &namedProvider{name: "b"}},
+			)
+			generateWithPool(t, pool, 1)
+			assert.Equal(t, now.Add(5*time.Second).Format(time.RFC3339), pool.Keys()[0].CoolingDownUntil)
+		},
+	)
+
+	t.Run(
+		"ModelsShareTheKeys", func(t *testing.T) {
+			limited := &namedProvider{name: "a", flakyProvider: flakyProvider{errs: []error{rateLimit}}}
+			pool, _ := newTestKeyPool(
+				t, config.KeyRotationConfig{Cooldown: time.Minute},
+				PooledKey{Name: "a", Provider: limited},
+				PooledKey{Name: "b", Provider: &namedProvider{name: "b"}},
+			)
+			other := &namedProvider{name: "other a"}
+			otherModel, err := pool.ForModel([]Provider{other, &namedProvider{name: "other b"}})
+			require.NoError(t, err)
+
+			// Key a is rate limited on the first model and cools down for the other one as well
+			assert.Equal(t, []string{"b"}, generateWithPool(t, pool, 1))
+			assert.Equal(t, []string{"other b", "other b"}, generateWithPool(t, otherModel, 2))
+			assert.Zero(t, other.calls)
+			assert.EqualValues(t, 3, otherModel.Keys()[1].Requests)
+
+			_, err = pool.ForModel([]Provider{other})
+			assert.Error(t, err)
+		},
+	)
+
+	t.Run(
+		"AllKeysRateLimited", func(t *testing.T) {
+			a := &namedProvider{name: "a", flakyProvider: flakyProvider{errs: []error{rateLimit, rateLimit}}}
+			b := &namedProvider{name: "b", flakyProvider: flakyProvider{errs: []error{rateLimit}}}
+			pool, now := newTestKeyPool(
+				t, config.KeyRotationConfig{Cooldown: time.Minute},
+				PooledKey{Name: "a", Provider: a},
+				PooledKey{Name: "b", Provider: b},
+			)
+
+			_, err := pool.Generate(context.Background(), "hi", nil)
+			assert.ErrorIs(t, err, rateLimit)
+			assert.Equal(t, 1, a.calls)
+			assert.Equal(t, 1, b.calls)
+
+			// While every key cools down, only the key that is available first is tried
+			*now = now.Add(30 * time.Second)
+			_, err = pool.Generate(context.Background(), "hi", nil)
+			assert.ErrorIs(t, err, rateLimit)
+			assert.Equal(t, 2, a.calls)
+			assert.Equal(t, 1, b.calls)
+		},
+	)
+
+	t.Run(
+		"OtherErrorsAreReturned", func(t *testing.T) {
+			failing := &namedProvider{
+				name:          "a",
+				flakyProvider: flakyProvider{errs: []error{&APIError{StatusCode: http.StatusBadRequest}}},
+			}
+			pool, _ := newTestKeyPool(
+				t, config.KeyRotationConfig{},
+				PooledKey{Name: "a", Provider: failing},
+				PooledKey{Name: "b", Provider: &namedProvider{name: "b"}},
+			)
+			_, err := pool.Generate(context.Background(), "hi", nil)
+			assert.Error(t, err)
+			assert.EqualValues(t, 1, pool.Keys()[0].Failures)
+			assert.Zero(t, pool.Keys()[0].RateLimited)
+		},
+	)
+
+	t.Run(
+		"LeastRateLimited", func(t *testing.T) {
+			limited := &namedProvider{name: "a", flakyProvider: flakyProvider{errs: []error{rateLimit}}}
+			pool, now := newTestKeyPool(
+				t, config.KeyRotationConfig{Strategy: config.KeyRotationLeastRateLimited, Cooldown: time.Second},
+				PooledKey{Name: "a", Provider: limited},
+				PooledKey{Name: "b", Provider: &namedProvider{name: "b"}},
+			)
+			generateWithPool(t, pool, 1)
+
+			// Once the cooldown is over, the key that was never rate limited is still preferred
+			*now = now.Add(time.Minute)
+			assert.Equal(t, []string{"b", "b", "b"}, generateWithPool(t, pool, 3))
+		},
+	)
+
+	t.Run(
+		"ReloadedPoolsInheritTheKeys", func(t *testing.T) {
+			limited := &namedProvider{name: "a", flakyProvider: flakyProvider{errs: []error{rateLimit}}}
+			previous, _ := newTestKeyPool(
+				t, config.KeyRotationConfig{},
+				PooledKey{Name: "a", Provider: limited},
+				PooledKey{Name: "b", Provider: &namedProvider{name: "b"}},
+			)
+			generateWithPool(t, previous, 1)
+
+			pool, _ := newTestKeyPool(
+				t, config.KeyRotationConfig{},
+				PooledKey{Name: "a", Provider: &namedProvider{name: "a"}},
+				PooledKey{Name: "c", Provider: &namedProvider{name: "c"}},
+			)
+			pool.Inherit(previous)
+
+			keys := pool.Keys()
+			assert.EqualValues(t, 1, keys[0].RateLimited)
+			assert.NotEmpty(t, keys[0].CoolingDownUntil)
+			assert.Zero(t, keys[1].Requests)
+			assert.Equal(t, []string{"c", "c"}, generateWithPool(t, pool, 2))
+		},
+	)
+
+	t.Run(
+		"KeyPoolOf", func(t *testing.T) {
+			pool, _ := newTestKeyPool(t, config.KeyRotationConfig{}, PooledKey{Name: "a", Provider: &stubProvider{}})
+			found, ok := KeyPoolOf(NewTracedProvider(NewRetryProvider(pool, config.RetryConfig{})))
+			require.True(t, ok)
+			assert.Same(t, pool, found)
+
+			_, ok = KeyPoolOf(NewTracedProvider(&stubProvider{}))
+			assert.False(t, ok)
+		},
+	)
+}
@@ -5,8 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"math"
-	"net"
 	"time"
 
 	"workspace-engine/internal/llm-router/models"
@@ -26,10 +24,18 @@ type OpenAIProvider struct {
 }
 
 func NewOpenAIProvider(apiKey, model string) *OpenAIProvider {
-	client := openai.NewClient(apiKey)
+	return newOpenAIProvider(openai.DefaultConfig(apiKey), model)
+}
+
+// newOpenAIProvider creates a provider on the API of the config. go-openai drops the headers of
+// error responses, the transport keeps their Retry-After header.
+func newOpenAIProvider(cfg openai.ClientConfig, model string) *OpenAIProvider {
+	httpClient := newHTTPClient(0)
+	httpClient.Transport = retryAfterTransport{base: httpClient.Transport}
+	cfg.HTTPClient = httpClient
 
 	return &OpenAIProvider{
-		client: client,
+		client: openai.NewClientWithConfig(cfg),
 		model:  model,
 	}
 }
@@ -61,6 +67,11 @@ func (p *OpenAIProvider) Generate(
 		}
 	}
 
+	messages, err := newOpenAIMessages(prompt, params)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create request
 	req := openai.ChatCompletionRequest{
 		Model:            p.model,
@@ -69,12 +80,7 @@ func (p *OpenAIProvider) Generate(
 		TopP:             topP,
 		PresencePenalty:  presencePenalty,
 		FrequencyPenalty: frequencyPenalty,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: prompt,
-			},
-		},
+		Messages:         messages,
 	}
 
 	// Add stop sequences if provided
@@ -82,6 +88,11 @@ func (p *OpenAIProvider) Generate(
 		req.Stop = stopSequences
 	}
 
+	// Request native JSON output if asked for
+	if format, ok := params["responseFormat"].(*models.ResponseFormat); ok {
+		req.ResponseFormat = toOpenAIResponseFormat(format)
+	}
+
 	// Create timeout context if specified
 	if timeout, ok := params["timeout"].(int); ok && timeout > 0 {
 		var cancel context.CancelFunc
@@ -90,9 +101,10 @@ func (p *OpenAIProvider) Generate(
 	}
 
 	// Make API call
+	ctx, header := withRetryAfterHeader(ctx)
 	resp, err := p.client.CreateChatCompletion(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("openai api error: %w", err)
+		return nil, header.wrap(fmt.Errorf("openai api error: %w", err))
 	}
 
 	// Extract response content
@@ -122,8 +134,8 @@ func (p *OpenAIProvider) Generate(
 
 func (p *OpenAIProvider) GetModelInfo() models.ModelInfo {
 	return models.ModelInfo{
-		ID:       "gpt-4",
-		Name:     "GPT-4",
+		ID:       p.model,
+		Name:     p.model,
 		Provider: "OpenAI",
 		Capabilities: []string{
 			"text-generation",
@@ -138,71 +150,84 @@ func (p *OpenAIProvider) GetModelInfo() models.ModelInfo {
 	}
 }
 
+func (p *OpenAIProvider) ListModels(ctx context.Context) ([]models.ModelInfo, error) {
+	list, err := p.client.ListModels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("openai api error: %w", err)
+	}
+
+	infos := make([]models.ModelInfo, 0, len(list.Models))
+	for _, model := range list.Models {
+		infos = append(
+			infos, models.ModelInfo{
+				ID:       model.ID,
+				Name:     model.ID,
+				Provider: "OpenAI",
+			},
+		)
+	}
+	return infos, nil
+}
+
+func (p *OpenAIProvider) StructuredOutputMode() string {
+	return models.ResponseFormatJSONSchema
+}
+
+// SupportsContent accepts text and image parts, the chat API takes no documents
+func (p *OpenAIProvider) SupportsContent(partType string) bool {
+	return partType == models.ContentPartText || partType == models.ContentPartImage
+}
+
 func (p *OpenAIProvider) IsHealthy() bool {
 	// Implement health check
 	return true
 }
 
-// Add error retry handling
-func (p *OpenAIProvider) generateWithRetry(
-	ctx context.Context, prompt string, params map[string]interface{}, maxRetries int,
-) (*models.RouteResponse, error) {
-	var lastErr error
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		result, err := p.Generate(ctx, prompt, params)
-		if err == nil {
-			return result, nil
-		}
-
-		lastErr = err
-		// Check if error is retryable
-		if !isRetryableError(err) {
-			return nil, err
-		}
+// newOpenAIMessages builds the conversation history followed by the user message
+func newOpenAIMessages(prompt string, params map[string]interface{}) ([]openai.ChatCompletionMessage, error) {
+	message, err := newOpenAIUserMessage(prompt, contentParts(params))
+	if err != nil {
+		return nil, err
+	}
 
-		// Wait before retrying with exponential backoff
-		waitTime := time.Duration(math.Pow(2, float64(attempt))) * time.Second
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-time.After(waitTime):
-			continue
-		}
+	var messages []openai.ChatCompletionMessage
+	for _, m := range history(params) {
+		messages = append(messages, openai.ChatCompletionMessage{Role: m.Role, Content: m.Content})
 	}
-	return nil, fmt.Errorf("max retries exceeded: %w", lastErr)
+	return append(messages, message), nil
 }
 
-// Helper function to determine if an error is retryable
-func isRetryableError(err error) bool {
-	// Check for rate limits, temporary server errors, etc.
-	if err == nil {
-		return false
-	}
-
-	// Check for specific OpenAI API errors
-	var apiErr *openai.APIError
-	if errors.As(err, &apiErr) {
-		switch apiErr.HTTPStatusCode {
-		case 429: // Rate limit
-			return true
-		case 500, 502, 503, 504: // Server errors
-			return true
-		default:
-			return false
-		}
+// newOpenAIUserMessage builds the user message, using multi content when there are content parts
+func newOpenAIUserMessage(prompt string, parts []models.ContentPart) (openai.ChatCompletionMessage, error) {
+	message := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser}
+	if len(parts) == 0 {
+		message.Content = prompt
+		return message, nil
 	}
 
-	// Check for context deadline exceeded
-	if errors.Is(err, context.DeadlineExceeded) {
-		return true
+	if prompt != "" {
+		message.MultiContent = append(
+			message.MultiContent, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: prompt},
+		)
 	}
-
-	// Check for network errors
-	if netErr, ok := err.(net.Error); ok {
-		return netErr.Temporary()
+	for _, part := range parts {
+		switch part.Type {
+		case models.ContentPartText:
+			message.MultiContent = append(
+				message.MultiContent, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: part.Text},
+			)
+		case models.ContentPartImage:
+			message.MultiContent = append(
+				message.MultiContent, openai.ChatMessagePart{
+					Type:     openai.ChatMessagePartTypeImageURL,
+					ImageURL: &openai.ChatMessageImageURL{URL: partURL(part)},
+				},
+			)
+		default:
+			return message, fmt.Errorf("openai: %s content is not supported", part.Type)
+		}
 	}
-
-	return false
+	return message, nil
 }
 
 // Add streaming support
@@ -211,18 +236,24 @@ func (p *OpenAIProvider) GenerateStream(
 ) (<-chan models.StreamResponse, error) {
 	stream := make(chan models.StreamResponse)
 
+	messages, err := newOpenAIMessages(prompt, params)
+	if err != nil {
+		return nil, err
+	}
+
 	req := openai.ChatCompletionRequest{
 		Model:    p.model,
-		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: prompt}},
+		Messages: messages,
 		Stream:   true,
 	}
 
 	// Apply parameters similar to non-streaming version
 	applyParameters(&req, params)
 
+	ctx, header := withRetryAfterHeader(ctx)
 	streamResp, err := p.client.CreateChatCompletionStream(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create stream: %w", err)
+		return nil, header.wrap(fmt.Errorf("failed to create stream: %w", err))
 	}
 
 	go func() {
@@ -279,4 +310,27 @@ func applyParameters(req *openai.ChatCompletionRequest, params map[string]interf
 	if stop, ok := params["stopSequences"].([]string); ok {
 		req.Stop = stop
 	}
+	if format, ok := params["responseFormat"].(*models.ResponseFormat); ok {
+		req.ResponseFormat = toOpenAIResponseFormat(format)
+	}
+}
+
+func toOpenAIResponseFormat(format *models.ResponseFormat) *openai.ChatCompletionResponseFormat {
+	if format.Type != models.ResponseFormatJSONSchema || len(format.Schema) == 0 {
+		return &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		}
+	}
+
+	name := format.Name
+	if name == "" {
+		name = "response"
+	}
+	return &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   name,
+			Schema: format.Schema,
+		},
+	}
 }
@@ -33,11 +33,13 @@ type OpenRouterRequest struct {
 	Stream      bool                   `json:"stream,omitempty"`
 	Stop        []string               `json:"stop,omitempty"`
 	Headers     map[string]interface{} `json:"headers,omitempty"`
+	// ResponseFormat enables the OpenAI compatible JSON mode
+	ResponseFormat *JSONResponseFormat `json:"response_format,omitempty"`
 }
 
 type OpenRouterMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string      `json:"role"`
+	Content ChatContent `json:"content"`
 }
 
 type OpenRouterResponse struct {
@@ -68,7 +70,7 @@ func NewOpenRouterProvider(apiKey, model string, httpHeaders map[string]string)
 	return &OpenRouterProvider{
 		apiKey:      apiKey,
 		model:       model,
-		client:      &http.Client{Timeout: 30 * time.Second},
+		client:      newHTTPClient(30 * time.Second),
 		httpHeaders: httpHeaders,
 	}
 }
@@ -94,15 +96,15 @@ func (p *OpenRouterProvider) Generate(
 		}
 	}
 
+	messages, err := newOpenRouterMessages(prompt, params)
+	if err != nil {
+		return nil, fmt.Errorf("openrouter: %w", err)
+	}
+
 	// Create request
 	reqBody := OpenRouterRequest{
-		Model: p.model,
-		Messages: []OpenRouterMessage{
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
+		Model:       p.model,
+		Messages:    messages,
 		MaxTokens:   maxTokens,
 		Temperature: temperature,
 		TopP:        topP,
@@ -114,6 +116,11 @@ func (p *OpenRouterProvider) Generate(
 		reqBody.Stop = stopSequences
 	}
 
+	// Request JSON output if asked for
+	if _, ok := params["responseFormat"].(*models.ResponseFormat); ok {
+		reqBody.ResponseFormat = &JSONResponseFormat{Type: models.ResponseFormatJSONObject}
+	}
+
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
@@ -142,8 +149,7 @@ func (p *OpenRouterProvider) Generate(
 
 	// Handle non-200 responses
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("openrouter api error: status %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError("openrouter", resp)
 	}
 
 	// Parse response
@@ -181,16 +187,17 @@ func (p *OpenRouterProvider) GenerateStream(
 ) (<-chan models.StreamResponse, error) {
 	stream := make(chan models.StreamResponse)
 
+	messages, err := newOpenRouterMessages(prompt, params)
+	if err != nil {
+		return nil, fmt.Errorf("openrouter: %w", err)
+	}
+
+	// Create request
 	reqBody := OpenRouterRequest{
-		Model: p.model,
-		Messages: []OpenRouterMessage{
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
-		Stream:  true,
-		Headers: p.getRequestHeaders(),
+		Model:    p.model,
+		Messages: messages,
+		Stream:   true,
+		Headers:  p.getRequestHeaders(),
 	}
 
 	// Apply parameters
@@ -220,6 +227,10 @@ func (p *OpenRouterProvider) GenerateStream(
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newAPIError("openrouter", resp)
+	}
 
 	go func() {
 		defer close(stream)
@@ -293,6 +304,47 @@ func (p *OpenRouterProvider) GetModelInfo() models.ModelInfo {
 	}
 }
 
+func (p *OpenRouterProvider) StructuredOutputMode() string {
+	return models.ResponseFormatJSONObject
+}
+
+func (p *OpenRouterProvider) ListModels(ctx context.Context) ([]models.ModelInfo, error) {
+	var list struct {
+		Data []struct {
+			ID            string `json:"id"`
+			Name          string `json:"name"`
+			ContextLength int    `json:"context_length"`
+			Pricing       struct {
+				Prompt     string `json:"prompt"`
+				Completion string `json:"completion"`
+			} `json:"pricing"`
+		} `json:"data"`
+	}
+
+	headers := map[string]string{"Authorization": "Bearer " + p.apiKey}
+	if err := fetchModels(ctx, p.client, openRouterBaseURL+"/models", headers, &list); err != nil {
+		return nil, fmt.Errorf("openrouter %w", err)
+	}
+
+	infos := make([]models.ModelInfo, 0, len(list.Data))
+	for _, model := range list.Data {
+		infos = append(
+			infos, models.ModelInfo{
+				ID:        model.ID,
+				Name:      model.Name,
+				Provider:  "openrouter",
+				MaxTokens: model.ContextLength,
+				Pricing: models.Pricing{
+					InputPrice:  perThousandTokens(model.Pricing.Prompt),
+					OutputPrice: perThousandTokens(model.Pricing.Completion),
+					Currency:    "USD",
+				},
+			},
+		)
+	}
+	return infos, nil
+}
+
 func (p *OpenRouterProvider) IsHealthy() bool {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -338,4 +390,21 @@ func (p *OpenRouterProvider) applyParameters(req *OpenRouterRequest, params map[
 	if stop, ok := params["stopSequences"].([]string); ok {
 		req.Stop = stop
 	}
+	if _, ok := params["responseFormat"].(*models.ResponseFormat); ok {
+		req.ResponseFormat = &JSONResponseFormat{Type: models.ResponseFormatJSONObject}
+	}
+}
+
+// newOpenRouterMessages builds the conversation history followed by the user message
+func newOpenRouterMessages(prompt string, params map[string]interface{}) ([]OpenRouterMessage, error) {
+	content, err := newChatContent(prompt, contentParts(params), true)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []OpenRouterMessage
+	for _, m := range history(params) {
+		messages = append(messages, OpenRouterMessage{Role: m.Role, Content: ChatContent{Text: m.Content}})
+	}
+	return append(messages, OpenRouterMessage{Role: "user", Content: content}), nil
 }
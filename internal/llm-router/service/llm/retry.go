@@ -0,0 +1,209 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"time"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/pkg/logger"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	defaultMaxAttempts    = 3
+	defaultInitialBackoff = 500 * time.Millisecond
+	defaultMaxBackoff     = 30 * time.Second
+	defaultMultiplier     = 2
+	defaultJitter         = 0.2
+)
+
+// defaultRetryableStatusCodes are timeouts, rate limits, server errors and the Anthropic overloaded
+// status
+var defaultRetryableStatusCodes = []int{408, 429, 500, 502, 503, 504, 529}
+
+// RetryProvider wraps a provider and retries failed calls with a jittered exponential backoff.
+// Streams are retried until they are established, a stream that fails midway is not restarted.
+type RetryProvider struct {
+	Provider
+	policy    config.RetryConfig
+	retryable map[int]bool
+
+	// wait and random are replaced in tests
+	wait   func(ctx context.Context, delay time.Duration) error
+	random func() float64
+}
+
+// NewRetryProvider wraps the provider with the given policy, zero fields take the defaults
+func NewRetryProvider(provider Provider, policy config.RetryConfig) *RetryProvider {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = defaultMaxAttempts
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = defaultInitialBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = defaultMaxBackoff
+	}
+	if policy.Multiplier <= 0 {
+		policy.Multiplier = defaultMultiplier
+	}
+	if policy.Jitter == nil {
+		jitter := defaultJitter
+		policy.Jitter = &jitter
+	}
+	if len(policy.RetryableStatusCodes) == 0 {
+		policy.RetryableStatusCodes = defaultRetryableStatusCodes
+	}
+
+	retryable := make(map[int]bool, len(policy.RetryableStatusCodes))
+	for _, code := range policy.RetryableStatusCodes {
+		retryable[code] = true
+	}
+
+	return &RetryProvider{
+		Provider:  provider,
+		policy:    policy,
+		retryable: retryable,
+		wait:      sleep,
+		random:    rand.Float64,
+	}
+}
+
+func (p *RetryProvider) Generate(
+	ctx context.Context, prompt string, params map[string]interface{},
+) (*models.RouteResponse, error) {
+	return retry(
+		ctx, p, func(ctx context.Context) (*models.RouteResponse, error) {
+			return p.Provider.Generate(ctx, prompt, params)
+		},
+	)
+}
+
+func (p *RetryProvider) GenerateStream(
+	ctx context.Context, prompt string, params map[string]interface{},
+) (<-chan models.StreamResponse, error) {
+	return retry(
+		ctx, p, func(ctx context.Context) (<-chan models.StreamResponse, error) {
+			return p.Provider.GenerateStream(ctx, prompt, params)
+		},
+	)
+}
+
+// StructuredOutputMode forwards the native JSON mode of the wrapped provider
+func (p *RetryProvider) StructuredOutputMode() string {
+	return structuredOutputMode(p.Provider)
+}
+
+// SupportsContent forwards the content support of the wrapped provider
+func (p *RetryProvider) SupportsContent(partType string) bool {
+	return supportsContent(p.Provider, partType)
+}
+
+// Unwrap returns the wrapped provider
+func (p *RetryProvider) Unwrap() Provider {
+	return p.Provider
+}
+
+func retry[T any](ctx context.Context, p *RetryProvider, call func(ctx context.Context) (T, error)) (T, error) {
+	model := p.Provider.GetModelInfo().ID
+	for attempt := 0; ; attempt++ {
+		attemptCtx, span := startAttemptSpan(ctx, model, attempt)
+		result, err := call(attemptCtx)
+		endAttemptSpan(span, err)
+		if err == nil {
+			return result, nil
+		}
+
+		delay, ok := p.nextDelay(ctx, err, attempt)
+		if !ok {
+			return result, err
+		}
+
+		logger.DebugContext(
+			ctx, "Retrying provider call", "model", model, "attempt", attempt+1, "delay", delay, "error", err,
+		)
+		if waitErr := p.wait(ctx, delay); waitErr != nil {
+			return result, err
+		}
+	}
+}
+
+// nextDelay returns how long to wait before the next attempt, or false when the call must not be
+// retried because the error is permanent, the attempts are used up or the context deadline would
+// pass before the next attempt starts
+func (p *RetryProvider) nextDelay(ctx context.Context, err error, attempt int) (time.Duration, bool) {
+	if attempt+1 >= p.policy.MaxAttempts || ctx.Err() != nil {
+		return 0, false
+	}
+
+	retryAfter, ok := p.classify(err)
+	// A vendor asking for a longer delay than the policy allows is not worth waiting for
+	if !ok || retryAfter > p.policy.MaxBackoff {
+		return 0, false
+	}
+
+	delay := p.backoff(attempt)
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+		return 0, false
+	}
+	return delay, true
+}
+
+// classify tells whether an error is worth retrying and the delay the vendor asked for, if any
+func (p *RetryProvider) classify(err error) (time.Duration, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter, p.retryable[apiErr.StatusCode]
+	}
+
+	var openAIErr *openai.APIError
+	if errors.As(err, &openAIErr) {
+		return retryAfter(err), p.retryable[openAIErr.HTTPStatusCode]
+	}
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return retryAfter(err), p.retryable[requestErr.HTTPStatusCode]
+	}
+
+	// A timeout of the attempt itself, the caller's context is checked before
+	if errors.Is(err, context.DeadlineExceeded) {
+		return 0, true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return 0, true
+	}
+	return 0, false
+}
+
+func (p *RetryProvider) backoff(attempt int) time.Duration {
+	delay := float64(p.policy.InitialBackoff) * math.Pow(p.policy.Multiplier, float64(attempt))
+	if delay > float64(p.policy.MaxBackoff) {
+		delay = float64(p.policy.MaxBackoff)
+	}
+	delay -= delay * *p.policy.Jitter * p.random()
+	return time.Duration(delay)
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
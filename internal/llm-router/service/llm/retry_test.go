@@ -0,0 +1,187 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyProvider fails with the given errors before it succeeds
+type flakyProvider struct {
+	stubProvider
+	errs  []error
+	calls int
+}
+
+func (p *flakyProvider) Generate(
+	ctx context.Context, prompt string, params map[string]interface{},
+) (*models.RouteResponse, error) {
+	p.calls++
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		return nil, err
+	}
+	return p.stubProvider.Generate(ctx, prompt, params)
+}
+
+func newTestRetryProvider(provider Provider, policy config.RetryConfig) (*RetryProvider, *[]time.Duration) {
+	var delays []time.Duration
+	retrying := NewRetryProvider(provider, policy)
+	retrying.random = func() float64 { return 0 }
+	retrying.wait = func(_ context.Context, delay time.Duration) error {
+		delays = append(delays, delay)
+		return nil
+	}
+	return retrying, &delays
+}
+
+func TestRetryProvider(t *testing.T) {
+	unavailable := &APIError{Provider: "stub", StatusCode: http.StatusServiceUnavailable}
+	policy := config.RetryConfig{MaxAttempts: 3, InitialBackoff: time.Second, Multiplier: 2}
+
+	t.Run(
+		"RetriesWithBackoff", func(t *testing.T) {
+			provider := &flakyProvider{errs: []error{unavailable, unavailable}}
+			retrying, delays := newTestRetryProvider(provider, policy)
+
+			_, err := retrying.Generate(context.Background(), "hi", nil)
+			require.NoError(t, err)
+			assert.Equal(t, 3, provider.calls)
+			assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, *delays)
+		},
+	)
+
+	t.Run(
+		"GivesUpAfterMaxAttempts", func(t *testing.T) {
+			provider := &flakyProvider{errs: []error{unavailable, unavailable, unavailable}}
+			retrying, _ := newTestRetryProvider(provider, policy)
+
+			_, err := retrying.Generate(context.Background(), "hi", nil)
+			assert.ErrorIs(t, err, unavailable)
+			assert.Equal(t, 3, provider.calls)
+		},
+	)
+
+	t.Run(
+		"DoesNotRetryClientErrors", func(t *testing.T) {
+			provider := &flakyProvider{errs: []error{&APIError{Provider: "stub", StatusCode: http.StatusBadRequest}}}
+			retrying, _ := newTestRetryProvider(provider, policy)
+
+			_, err := retrying.Generate(context.Background(), "hi", nil)
+			assert.Error(t, err)
+			assert.Equal(t, 1, provider.calls)
+		},
+	)
+
+	t.Run(
+		"HonorsRetryAfter", func(t *testing.T) {
+			limited := &APIError{Provider: "stub", StatusCode: http.StatusTooManyRequests, RetryAfter: 5 * time.Second}
+			provider := &flakyProvider{errs: []error{limited}}
+			retrying, delays := newTestRetryProvider(provider, policy)
+
+			_, err := retrying.Generate(context.Background(), "hi", nil)
+			require.NoError(t, err)
+			assert.Equal(t, []time.Duration{5 * time.Second}, *delays)
+		},
+	)
+
+	t.Run(
+		"GivesUpOnRetryAfterBeyondMaxBackoff", func(t *testing.T) {
+			limited := &APIError{Provider: "stub", StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}
+			provider := &flakyProvider{errs: []error{limited}}
+			retrying, delays := newTestRetryProvider(provider, policy)
+
+			_, err := retrying.Generate(context.Background(), "hi", nil)
+			assert.ErrorIs(t, err, limited)
+			assert.Equal(t, 1, provider.calls)
+			assert.Empty(t, *delays)
+		},
+	)
+
+	t.Run(
+		"HonorsOpenAIRetryAfter", func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(
+				http.HandlerFunc(
+					func(w http.ResponseWriter, r *http.Request) {
+						calls++
+						w.Header().Set("Content-Type", "application/json")
+						w.Header().Set("Retry-After", "3")
+						w.WriteHeader(http.StatusTooManyRequests)
+						_, _ = io.WriteString(w, `{"error": {"message": "Rate limit reached", "type": "requests"}}`)
+					},
+				),
+			)
+			t.Cleanup(server.Close)
+
+			cfg := openai.DefaultConfig("sk")
+			cfg.BaseURL = server.URL
+			retrying, delays := newTestRetryProvider(newOpenAIProvider(cfg, "gpt-4o"), policy)
+
+			_, err := retrying.Generate(context.Background(), "hi", nil)
+			var apiErr *openai.APIError
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, 3, calls)
+			assert.Equal(t, []time.Duration{3 * time.Second, 3 * time.Second}, *delays)
+
+			_, err = retrying.GenerateStream(context.Background(), "hi", nil)
+			require.Error(t, err)
+			assert.Equal(t, 3*time.Second, retryAfter(err))
+		},
+	)
+
+	t.Run(
+		"StopsBeforeContextDeadline", func(t *testing.T) {
+			provider := &flakyProvider{errs: []error{unavailable}}
+			retrying, delays := newTestRetryProvider(provider, policy)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			_, err := retrying.Generate(ctx, "hi", nil)
+			assert.ErrorIs(t, err, unavailable)
+			assert.Empty(t, *delays)
+		},
+	)
+
+	t.Run(
+		"JitterShortensBackoff", func(t *testing.T) {
+			jitter := 0.5
+			retrying := NewRetryProvider(&stubProvider{}, config.RetryConfig{InitialBackoff: time.Second, Jitter: &jitter})
+			retrying.random = func() float64 { return 1 }
+			assert.Equal(t, 500*time.Millisecond, retrying.backoff(0))
+		},
+	)
+
+	t.Run(
+		"ZeroJitterIsKept", func(t *testing.T) {
+			jitter := 0.0
+			retrying := NewRetryProvider(&stubProvider{}, config.RetryConfig{InitialBackoff: time.Second, Jitter: &jitter})
+			retrying.random = func() float64 { return 1 }
+			assert.Equal(t, time.Second, retrying.backoff(0))
+
+			defaults := NewRetryProvider(&stubProvider{}, config.RetryConfig{InitialBackoff: time.Second})
+			defaults.random = func() float64 { return 1 }
+			assert.Equal(t, 800*time.Millisecond, defaults.backoff(0))
+		},
+	)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 3*time.Second, parseRetryAfter("3", now))
+	assert.Equal(t, 90*time.Second, parseRetryAfter("Mon, 01 Jan 2024 12:01:30 GMT", now))
+	assert.Zero(t, parseRetryAfter("soon", now))
+	assert.Zero(t, parseRetryAfter("", now))
+}
@@ -0,0 +1,170 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/telemetry"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("workspace-engine/internal/llm-router/service/llm")
+
+// newHTTPClient returns an HTTP client whose outbound calls are traced
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	}
+}
+
+// newStreamHTTPClient returns a traced HTTP client for streams. It has no overall timeout, since a
+// stream lasts as long as the model generates, but gives up on responses whose headers do not
+// arrive within headerTimeout.
+func newStreamHTTPClient(headerTimeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = headerTimeout
+	return &http.Client{Transport: otelhttp.NewTransport(transport)}
+}
+
+// TracedProvider wraps a provider and records a span for every Generate and GenerateStream call
+type TracedProvider struct {
+	Provider
+}
+
+func NewTracedProvider(provider Provider) *TracedProvider {
+	return &TracedProvider{Provider: provider}
+}
+
+func (p *TracedProvider) Generate(
+	ctx context.Context, prompt string, params map[string]interface{},
+) (*models.RouteResponse, error) {
+	ctx, span := p.startSpan(ctx, "llm.generate")
+	defer span.End()
+
+	resp, err := p.Provider.Generate(ctx, prompt, params)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(
+		telemetry.AttrResponseModel.String(resp.Model),
+		telemetry.AttrInputTokens.Int(resp.Usage.PromptTokens),
+		telemetry.AttrOutputTokens.Int(resp.Usage.CompletionTokens),
+	)
+	span.SetStatus(codes.Ok, "")
+	return resp, nil
+}
+
+// GenerateStream keeps the span open until the stream is drained. Chunks are dropped once the
+// context is done, so an abandoned stream does not block the forwarding goroutine.
+func (p *TracedProvider) GenerateStream(
+	ctx context.Context, prompt string, params map[string]interface{},
+) (<-chan models.StreamResponse, error) {
+	ctx, span := p.startSpan(ctx, "llm.generate_stream")
+
+	upstream, err := p.Provider.GenerateStream(ctx, prompt, params)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		return nil, err
+	}
+
+	stream := make(chan models.StreamResponse)
+	go func() {
+		defer close(stream)
+		defer span.End()
+
+		chunks := 0
+		status := codes.Ok
+		for msg := range upstream {
+			chunks++
+			if msg.Error != nil {
+				span.RecordError(msg.Error)
+				span.SetStatus(codes.Error, msg.Error.Error())
+				status = codes.Error
+			}
+			if msg.Usage != nil {
+				span.SetAttributes(
+					telemetry.AttrInputTokens.Int(msg.Usage.PromptTokens),
+					telemetry.AttrOutputTokens.Int(msg.Usage.CompletionTokens),
+				)
+			}
+			select {
+			case stream <- msg:
+			case <-ctx.Done():
+				// The consumer is gone, the upstream is drained so its goroutine can finish
+				if status == codes.Ok {
+					span.RecordError(ctx.Err())
+					span.SetStatus(codes.Error, ctx.Err().Error())
+					status = codes.Error
+				}
+			}
+		}
+
+		span.SetAttributes(telemetry.AttrStreamChunkCount.Int(chunks))
+		if status == codes.Ok {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	return stream, nil
+}
+
+// StructuredOutputMode forwards the native JSON mode of the wrapped provider
+func (p *TracedProvider) StructuredOutputMode() string {
+	return structuredOutputMode(p.Provider)
+}
+
+// SupportsContent forwards the content support of the wrapped provider
+func (p *TracedProvider) SupportsContent(partType string) bool {
+	return supportsContent(p.Provider, partType)
+}
+
+// Unwrap returns the wrapped provider
+func (p *TracedProvider) Unwrap() Provider {
+	return p.Provider
+}
+
+func (p *TracedProvider) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	info := p.Provider.GetModelInfo()
+	return tracer.Start(
+		ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			telemetry.AttrProvider.String(info.Provider),
+			telemetry.AttrRequestModel.String(info.ID),
+		),
+	)
+}
+
+// startAttemptSpan records a single attempt of a retried call
+func startAttemptSpan(ctx context.Context, model string, attempt int) (context.Context, trace.Span) {
+	return tracer.Start(
+		ctx, "llm.attempt",
+		trace.WithAttributes(
+			telemetry.AttrRequestModel.String(model),
+			telemetry.AttrAttempt.Int(attempt+1),
+		),
+	)
+}
+
+// endAttemptSpan ends an attempt span with the outcome of the attempt
+func endAttemptSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetStatus(codes.Ok, "")
+	}
+	span.End()
+}
@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/telemetry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type stubProvider struct {
+	err    error
+	chunks []models.StreamResponse
+}
+
+func (p *stubProvider) Generate(context.Context, string, map[string]interface{}) (*models.RouteResponse, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &models.RouteResponse{
+		Model: "stub-model",
+		Usage: models.Usage{PromptTokens: 3, CompletionTokens: 4, TotalTokens: 7},
+	}, nil
+}
+
+func (p *stubProvider) GenerateStream(
+	context.Context, string, map[string]interface{},
+) (<-chan models.StreamResponse, error) {
+	stream := make(chan models.StreamResponse, len(p.chunks))
+	for _, chunk := range p.chunks {
+		stream <- chunk
+	}
+	close(stream)
+	return stream, nil
+}
+
+func (p *stubProvider) GetModelInfo() models.ModelInfo {
+	return models.ModelInfo{ID: "stub-model", Provider: "stub"}
+}
+
+func (p *stubProvider) IsHealthy() bool {
+	return true
+}
+
+func TestTracedProvider(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	t.Run(
+		"Generate", func(t *testing.T) {
+			_, err := NewTracedProvider(&stubProvider{}).Generate(context.Background(), "hi", nil)
+			require.NoError(t, err)
+
+			spans := recorder.Ended()
+			require.NotEmpty(t, spans)
+			span := spans[len(spans)-1]
+			assert.Equal(t, "llm.generate", span.Name())
+			assert.Equal(t, codes.Ok, span.Status().Code)
+			assert.Contains(t, span.Attributes(), telemetry.AttrProvider.String("stub"))
+			assert.Contains(t, span.Attributes(), telemetry.AttrInputTokens.Int(3))
+			assert.Contains(t, span.Attributes(), telemetry.AttrOutputTokens.Int(4))
+		},
+	)
+
+	t.Run(
+		"GenerateError", func(t *testing.T) {
+			_, err := NewTracedProvider(&stubProvider{err: errors.New("boom")}).Generate(context.Background(), "hi", nil)
+			require.Error(t, err)
+
+			spans := recorder.Ended()
+			assert.Equal(t, codes.Error, spans[len(spans)-1].Status().Code)
+		},
+	)
+
+	t.Run(
+		"GenerateStream", func(t *testing.T) {
+			provider := NewTracedProvider(
+				&stubProvider{
+					chunks: []models.StreamResponse{{Content: "a"}, {Content: "b", Done: true}},
+				},
+			)
+			stream, err := provider.GenerateStream(context.Background(), "hi", nil)
+			require.NoError(t, err)
+
+			var content string
+			for chunk := range stream {
+				content += chunk.Content
+			}
+			assert.Equal(t, "ab", content)
+
+			spans := recorder.Ended()
+			span := spans[len(spans)-1]
+			assert.Equal(t, "llm.generate_stream", span.Name())
+			assert.Contains(t, span.Attributes(), telemetry.AttrStreamChunkCount.Int(2))
+		},
+	)
+
+	t.Run(
+		"AbandonedStream", func(t *testing.T) {
+			provider := NewTracedProvider(
+				&stubProvider{
+					chunks: []models.StreamResponse{{Content: "a"}, {Content: "b"}, {Content: "c", Done: true}},
+				},
+			)
+			ctx, cancel := context.WithCancel(context.Background())
+			stream, err := provider.GenerateStream(ctx, "hi", nil)
+			require.NoError(t, err)
+
+			// The consumer reads one chunk and goes away, the span still ends
+			assert.Equal(t, "a", (<-stream).Content)
+			cancel()
+			require.Eventually(
+				t, func() bool {
+					spans := recorder.Ended()
+					return spans[len(spans)-1].Name() == "llm.generate_stream" &&
+						spans[len(spans)-1].Status().Code == codes.Error
+				}, time.Second, time.Millisecond,
+			)
+		},
+	)
+}
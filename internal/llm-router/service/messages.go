@@ -0,0 +1,102 @@
+package service
+
+import (
+	"fmt"
+
+	"workspace-engine/internal/llm-router/models"
+)
+
+// validateMessages checks the roles and contents of the conversation history
+func validateMessages(messages []models.Message) error {
+	for i, message := range messages {
+		switch message.Role {
+		case models.MessageRoleSystem, models.MessageRoleUser, models.MessageRoleAssistant:
+		default:
+			return fmt.Errorf("%w: message %d has unknown role %q", ErrInvalidContent, i, message.Role)
+		}
+		if message.Content == "" {
+			return fmt.Errorf("%w: message %d has no content", ErrInvalidContent, i)
+		}
+	}
+	return nil
+}
+
+// trimMessages drops the oldest messages of the history until the history, the prompt and the
+// completion fit into the context window of the model. System messages are always kept, and the
+// trimmed history never starts with an assistant message. Without a known context window the
+// history is not trimmed.
+func trimMessages(req models.RouteRequest, contextWindow int) []models.Message {
+	if contextWindow <= 0 || len(req.Messages) == 0 {
+		return req.Messages
+	}
+
+	// Reserve room for the completion, a quarter of the window unless maxTokens is given
+	reserved := maxTokensParam(req.Parameters)
+	if reserved <= 0 {
+		reserved = contextWindow / 4
+	}
+	budget := contextWindow - reserved - requestTokens(req)
+	for _, message := range req.Messages {
+		if message.Role == models.MessageRoleSystem {
+			budget -= countTokens(message.Content)
+		}
+	}
+
+	// Walk back from the newest message, older messages are dropped once one does not fit
+	keep := make([]bool, len(req.Messages))
+	first, full := len(req.Messages), false
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		message := req.Messages[i]
+		if message.Role == models.MessageRoleSystem {
+			keep[i] = true
+			continue
+		}
+		tokens := countTokens(message.Content)
+		if full || tokens > budget {
+			full = true
+			continue
+		}
+		budget -= tokens
+		keep[i] = true
+		first = i
+	}
+
+	var trimmed []models.Message
+	for i, message := range req.Messages {
+		if !keep[i] {
+			continue
+		}
+		if message.Role == models.MessageRoleAssistant && i == first {
+			continue
+		}
+		trimmed = append(trimmed, message)
+	}
+	return trimmed
+}
+
+// contextTokens approximates the part of the context window a request needs however its history is
+// trimmed: the prompt and text parts, the system messages and the completion when maxTokens is set
+func contextTokens(req models.RouteRequest) int {
+	tokens := requestTokens(req)
+	for _, message := range req.Messages {
+		if message.Role == models.MessageRoleSystem {
+			tokens += countTokens(message.Content)
+		}
+	}
+	if maxTokens := maxTokensParam(req.Parameters); maxTokens > 0 {
+		tokens += maxTokens
+	}
+	return tokens
+}
+
+// requestTokens approximates the number of tokens of the prompt and the content parts of a request
+func requestTokens(req models.RouteRequest) int {
+	tokens := countTokens(req.Prompt)
+	for _, part := range req.Content {
+		tokens += countTokens(part.Text)
+		if part.Type == models.ContentPartImage {
+			tokens += imageTokens
+		}
+	}
+	return tokens
+}
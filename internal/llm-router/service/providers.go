@@ -0,0 +1,419 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service/llm"
+)
+
+const defaultProviderWeight = 1
+
+var ErrProviderNotFound = errors.New("provider not found")
+
+// ProviderRegistry holds the provider instances of the router together with their runtime state.
+// Readers work on an immutable snapshot that is swapped atomically on every change, so routing
+// never blocks on the admin API.
+type ProviderRegistry struct {
+	catalog *CatalogService
+
+	// mu serializes writers, readers only load the snapshot
+	mu       sync.Mutex
+	snapshot atomic.Pointer[map[string]*providerEntry]
+}
+
+type providerEntry struct {
+	key      string
+	provider llm.Provider
+	status   string
+	weight   int
+	stats    *providerStats
+}
+
+// providerStats is shared by all snapshots of an entry, so it survives state changes
+type providerStats struct {
+	inFlight     atomic.Int64
+	requests     atomic.Int64
+	failures     atomic.Int64
+	totalLatency atomic.Int64
+
+	mu        sync.Mutex
+	lastError string
+	healthy   bool
+	lastProbe time.Time
+}
+
+// NewProviderRegistry enables all providers with the default weight
+func NewProviderRegistry(providers map[string]llm.Provider, catalog *CatalogService) *ProviderRegistry {
+	entries := make(map[string]*providerEntry, len(providers))
+	for key, provider := range providers {
+		entries[key] = &providerEntry{
+			key:      key,
+			provider: provider,
+			status:   models.ProviderEnabled,
+			weight:   defaultProviderWeight,
+			stats:    &providerStats{healthy: true},
+		}
+	}
+
+	r := &ProviderRegistry{catalog: catalog}
+	r.snapshot.Store(&entries)
+	return r
+}
+
+// Get returns the provider stored under the key
+func (r *ProviderRegistry) Get(key string) (llm.Provider, bool) {
+	entry, ok := r.entries()[key]
+	if !ok {
+		return nil, false
+	}
+	return entry.provider, true
+}
+
+// Providers returns all provider instances by key
+func (r *ProviderRegistry) Providers() map[string]llm.Provider {
+	entries := r.entries()
+	providers := make(map[string]llm.Provider, len(entries))
+	for key, entry := range entries {
+		providers[key] = entry.provider
+	}
+	return providers
+}
+
+// Status returns the runtime state of a provider instance
+func (r *ProviderRegistry) Status(key string) (models.ProviderStatus, error) {
+	entry, ok := r.entries()[key]
+	if !ok {
+		return models.ProviderStatus{}, fmt.Errorf("%w: %s", ErrProviderNotFound, key)
+	}
+	return entry.toModel(), nil
+}
+
+// List returns the runtime state of all provider instances ordered by key
+func (r *ProviderRegistry) List() []models.ProviderStatus {
+	entries := r.entries()
+	statuses := make([]models.ProviderStatus, 0, len(entries))
+	for _, entry := range entries {
+		statuses = append(statuses, entry.toModel())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Key < statuses[j].Key })
+	return statuses
+}
+
+// ProviderChange holds changes to a provider instance that are applied together. Nil fields are
+// left as they are.
+type ProviderChange struct {
+	Provider llm.Provider
+	Status   *string
+	Weight   *int
+}
+
+// Apply publishes all changes of a provider instance in a single snapshot, so readers see either
+// none or all of them. The catalog is updated when the provider is replaced.
+func (r *ProviderRegistry) Apply(key string, change ProviderChange) error {
+	switch {
+	case change.Status != nil && !validProviderStatus(*change.Status):
+		return fmt.Errorf("unknown provider status: %s", *change.Status)
+	case change.Weight != nil && *change.Weight < 0:
+		return errors.New("weight must not be negative")
+	}
+
+	err := r.update(
+		key, func(entry *providerEntry) {
+			if change.Provider != nil {
+				entry.provider = change.Provider
+			}
+			if change.Weight != nil {
+				entry.weight = *change.Weight
+			}
+			if change.Status != nil {
+				entry.status = *change.Status
+			}
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if change.Provider != nil && r.catalog != nil {
+		r.catalog.SetProviders(r.Providers())
+	}
+	return nil
+}
+
+// SetStatus enables, disables or drains a provider instance. A draining instance gets no new
+// requests and becomes disabled once its in-flight requests have finished.
+func (r *ProviderRegistry) SetStatus(key, status string) error {
+	return r.Apply(key, ProviderChange{Status: &status})
+}
+
+// SetWeight changes the share of automatically routed requests a provider instance receives
+func (r *ProviderRegistry) SetWeight(key string, weight int) error {
+	return r.Apply(key, ProviderChange{Weight: &weight})
+}
+
+// Replace swaps the provider of an instance, keeping its state, and updates the model catalog
+func (r *ProviderRegistry) Replace(key string, provider llm.Provider) error {
+	return r.Apply(key, ProviderChange{Provider: provider})
+}
+
+// Reload replaces the provider instances, e.g. after the configuration changed. Instances whose key
+// remains keep their status, weight and statistics, as do the API keys of their key pools. New
+// instances are enabled with the default weight. Requests in flight finish on the instances they
+// started with. The catalog is not updated.
+func (r *ProviderRegistry) Reload(providers map[string]llm.Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current := r.entries()
+	next := make(map[string]*providerEntry, len(providers))
+	for key, provider := range providers {
+		if entry, ok := current[key]; ok {
+			inheritKeyPool(provider, entry.provider)
+			updated := *entry
+			updated.provider = provider
+			next[key] = &updated
+			continue
+		}
+		next[key] = &providerEntry{
+			key:      key,
+			provider: provider,
+			status:   models.ProviderEnabled,
+			weight:   defaultProviderWeight,
+			stats:    &providerStats{healthy: true},
+		}
+	}
+	r.snapshot.Store(&next)
+}
+
+// inheritKeyPool carries the state of the API keys over to the key pool of a new provider
+func inheritKeyPool(provider, previous llm.Provider) {
+	pool, ok := llm.KeyPoolOf(provider)
+	if !ok {
+		return
+	}
+	if previousPool, ok := llm.KeyPoolOf(previous); ok && previousPool != pool {
+		pool.Inherit(previousPool)
+	}
+}
+
+// Probe runs the health check of a provider instance and stores the result
+func (r *ProviderRegistry) Probe(key string) (models.ProviderStatus, error) {
+	entry, ok := r.entries()[key]
+	if !ok {
+		return models.ProviderStatus{}, fmt.Errorf("%w: %s", ErrProviderNotFound, key)
+	}
+
+	healthy := entry.provider.IsHealthy()
+
+	entry.stats.mu.Lock()
+	entry.stats.healthy = healthy
+	entry.stats.lastProbe = time.Now()
+	entry.stats.mu.Unlock()
+
+	return r.Status(key)
+}
+
+// Candidates returns the keys of the enabled instances with a weight, in weighted random order
+func (r *ProviderRegistry) Candidates(keys []string) []string {
+	entries := r.entries()
+
+	var candidates []weightedKey
+	for _, key := range keys {
+		entry, ok := entries[key]
+		if !ok || entry.status != models.ProviderEnabled || entry.weight <= 0 {
+			continue
+		}
+		candidates = append(candidates, weightedKey{key: key, weight: entry.weight})
+	}
+	return weightedOrder(candidates)
+}
+
+type weightedKey struct {
+	key    string
+	weight int
+}
+
+// weightedOrder returns the keys in random order, where the chance of a key to come first is
+// proportional to its weight
+func weightedOrder(candidates []weightedKey) []string {
+	remaining := append([]weightedKey(nil), candidates...)
+	total := 0
+	for _, c := range remaining {
+		total += c.weight
+	}
+
+	ordered := make([]string, 0, len(remaining))
+	for len(remaining) > 0 {
+		pick := rand.Intn(total)
+		for i, c := range remaining {
+			if pick < c.weight {
+				ordered = append(ordered, c.key)
+				total -= c.weight
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+			pick -= c.weight
+		}
+	}
+	return ordered
+}
+
+// Enabled tells whether an instance accepts new requests
+func (r *ProviderRegistry) Enabled(key string) bool {
+	entry, ok := r.entries()[key]
+	return ok && entry.status == models.ProviderEnabled
+}
+
+// Track counts a request to a provider instance. The returned function records its outcome.
+func (r *ProviderRegistry) Track(key string) func(err error) {
+	entry, ok := r.entries()[key]
+	if !ok {
+		return func(error) {}
+	}
+
+	stats := entry.stats
+	stats.inFlight.Add(1)
+	start := time.Now()
+
+	return func(err error) {
+		stats.requests.Add(1)
+		stats.totalLatency.Add(int64(time.Since(start)))
+		if err != nil {
+			stats.failures.Add(1)
+			stats.mu.Lock()
+			stats.lastError = err.Error()
+			stats.mu.Unlock()
+		}
+
+		if stats.inFlight.Add(-1) == 0 {
+			r.finishDrain(key)
+		}
+	}
+}
+
+// trackStream reports the outcome of a streamed request once the stream has been drained, together
+// with the streamed content and the last chunk, which carries the usage when the provider reports it
+func trackStream(
+	ctx context.Context, upstream <-chan models.StreamResponse,
+	done func(content string, last models.StreamResponse, err error),
+) <-chan models.StreamResponse {
+	stream := make(chan models.StreamResponse)
+	go func() {
+		defer close(stream)
+
+		var content strings.Builder
+		var last models.StreamResponse
+		var err error
+		for msg := range upstream {
+			if msg.Error != nil {
+				err = msg.Error
+			}
+			content.WriteString(msg.Content)
+			last = msg
+			select {
+			case stream <- msg:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+		done(content.String(), last, err)
+	}()
+	return stream
+}
+
+// finishDrain disables a draining instance once it has no requests in flight. The status is read
+// under the writer lock, so a drain published after the last request finished is not missed.
+func (r *ProviderRegistry) finishDrain(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.entries()[key]
+	if !ok || entry.status != models.ProviderDraining || entry.stats.inFlight.Load() != 0 {
+		return
+	}
+	r.publish(key, func(entry *providerEntry) { entry.status = models.ProviderDisabled })
+}
+
+func (r *ProviderRegistry) entries() map[string]*providerEntry {
+	return *r.snapshot.Load()
+}
+
+// update applies a change to a copy of an entry and publishes a new snapshot. A draining entry
+// without requests in flight is disabled right away.
+func (r *ProviderRegistry) update(key string, change func(entry *providerEntry)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.entries()[key]; !ok {
+		return fmt.Errorf("%w: %s", ErrProviderNotFound, key)
+	}
+	r.publish(key, change)
+
+	// Requests that finished before the drain was published found no draining entry to disable.
+	// Every later one disables it under the lock, so checking once more here covers the gap.
+	entry := r.entries()[key]
+	if entry.status == models.ProviderDraining && entry.stats.inFlight.Load() == 0 {
+		r.publish(key, func(entry *providerEntry) { entry.status = models.ProviderDisabled })
+	}
+	return nil
+}
+
+// publish swaps in a snapshot with a changed copy of an entry, the caller holds mu
+func (r *ProviderRegistry) publish(key string, change func(entry *providerEntry)) {
+	current := r.entries()
+	updated := *current[key]
+	change(&updated)
+
+	next := make(map[string]*providerEntry, len(current))
+	for k, v := range current {
+		next[k] = v
+	}
+	next[key] = &updated
+	r.snapshot.Store(&next)
+}
+
+func (e *providerEntry) toModel() models.ProviderStatus {
+	info := e.provider.GetModelInfo()
+	status := models.ProviderStatus{
+		Key:      e.key,
+		Vendor:   providerVendor(e.key),
+		Model:    info.ID,
+		Status:   e.status,
+		Weight:   e.weight,
+		InFlight: e.stats.inFlight.Load(),
+		Requests: e.stats.requests.Load(),
+		Failures: e.stats.failures.Load(),
+	}
+	if status.Requests > 0 {
+		average := time.Duration(e.stats.totalLatency.Load() / status.Requests)
+		status.AverageLatencyMs = float64(average) / float64(time.Millisecond)
+	}
+
+	e.stats.mu.Lock()
+	defer e.stats.mu.Unlock()
+	status.Healthy = e.stats.healthy
+	status.LastError = e.stats.lastError
+	if !e.stats.lastProbe.IsZero() {
+		status.LastProbe = e.stats.lastProbe.UTC().Format(time.RFC3339)
+	}
+	if pool, ok := llm.KeyPoolOf(e.provider); ok {
+		status.Keys = pool.Keys()
+	}
+	return status
+}
+
+func validProviderStatus(status string) bool {
+	switch status {
+	case models.ProviderEnabled, models.ProviderDisabled, models.ProviderDraining:
+		return true
+	}
+	return false
+}
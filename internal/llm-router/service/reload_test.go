@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service/llm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReload(t *testing.T) {
+	old := &blockingProvider{release: make(chan struct{})}
+	providers := map[string]llm.Provider{"openai_default": old}
+	catalog := NewCatalogService(providers, nil, &config.Config{})
+	router := NewRouterService(providers, catalog, nil, nil)
+	require.NoError(t, router.Providers().SetWeight("openai_default", 3))
+
+	// A request in flight when the configuration changes
+	inFlight := make(chan *models.RouteResponse)
+	go func() {
+		resp, _ := router.Route(context.Background(), models.RouteRequest{Prompt: "before"})
+		inFlight <- resp
+	}()
+	require.Eventually(t, func() bool { return old.callCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	replacement := &scriptedProvider{model: "gpt-4", results: []string{"after"}}
+	added := &scriptedProvider{model: "llama3-70b", results: []string{"groq"}}
+	cfg := &config.Config{
+		Routing: config.RoutingConfig{
+			Rules: []config.RoutingRule{
+				{Name: "groq", Match: config.RuleMatch{Tenants: []string{"acme"}}, Model: "llama3-70b"},
+			},
+			Coalesce: true,
+		},
+	}
+	require.NoError(
+		t, router.Reload(
+			map[string]llm.Provider{"openai_default": replacement, "groq_default": added}, nil, cfg,
+		),
+	)
+
+	t.Run(
+		"RequestsInFlightFinishOnTheOldProvider", func(t *testing.T) {
+			old.release <- struct{}{}
+			resp := <-inFlight
+			require.NotNil(t, resp)
+			assert.Equal(t, "before", resp.Result)
+		},
+	)
+
+	t.Run(
+		"ProvidersKeepTheirState", func(t *testing.T) {
+			status, err := router.Providers().Status("openai_default")
+			require.NoError(t, err)
+			assert.Equal(t, 3, status.Weight)
+			assert.Equal(t, "gpt-4", status.Model)
+			assert.EqualValues(t, 1, status.Requests)
+
+			status, err = router.Providers().Status("groq_default")
+			require.NoError(t, err)
+			assert.Equal(t, models.ProviderEnabled, status.Status)
+		},
+	)
+
+	t.Run(
+		"NewRequestsUseTheNewConfiguration", func(t *testing.T) {
+			resp, err := router.Route(context.Background(), models.RouteRequest{Prompt: "hello", PreferredModel: "gpt-4"})
+			require.NoError(t, err)
+			assert.Equal(t, "after", resp.Result)
+
+			ctx := WithCaller(context.Background(), Caller{Tenant: "acme"})
+			resp, err = router.Route(ctx, models.RouteRequest{Prompt: "hello"})
+			require.NoError(t, err)
+			assert.Equal(t, "groq", resp.Result)
+
+			_, _, ok := catalog.Resolve("blocking")
+			assert.False(t, ok)
+			assert.NotNil(t, router.flights.Load())
+		},
+	)
+
+	t.Run(
+		"KeyPoolsKeepTheirKeys", func(t *testing.T) {
+			newPool := func(result string) llm.Provider {
+				pool, err := llm.NewKeyPoolProvider(
+					[]llm.PooledKey{{Name: "primary", Provider: &scriptedProvider{model: "gpt-4", results: []string{result}}}},
+					config.KeyRotationConfig{},
+				)
+				require.NoError(t, err)
+				return llm.NewTracedProvider(pool)
+			}
+			registry := NewProviderRegistry(map[string]llm.Provider{"openai_default": newPool("first")}, nil)
+			provider, _ := registry.Get("openai_default")
+			_, err := provider.Generate(context.Background(), "hello", nil)
+			require.NoError(t, err)
+
+			registry.Reload(map[string]llm.Provider{"openai_default": newPool("second")})
+			status, err := registry.Status("openai_default")
+			require.NoError(t, err)
+			require.Len(t, status.Keys, 1)
+			assert.Equal(t, "primary", status.Keys[0].Name)
+			assert.EqualValues(t, 1, status.Keys[0].Requests)
+		},
+	)
+
+	t.Run(
+		"InvalidRulesAreNotApplied", func(t *testing.T) {
+			invalid := &config.Config{
+				Routing: config.RoutingConfig{
+					Rules: []config.RoutingRule{{Name: "broken", Match: config.RuleMatch{PromptRegex: "("}, Model: "gpt-4"}},
+				},
+			}
+			err := router.Reload(map[string]llm.Provider{"openai_default": old}, nil, invalid)
+			assert.Error(t, err)
+
+			provider, ok := router.Providers().Get("openai_default")
+			require.True(t, ok)
+			assert.Same(t, replacement, provider)
+		},
+	)
+}
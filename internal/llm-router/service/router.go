@@ -3,65 +3,623 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"workspace-engine/internal/llm-router/config"
 	"workspace-engine/internal/llm-router/models"
 	"workspace-engine/internal/llm-router/service/llm"
+	"workspace-engine/internal/llm-router/store"
+	"workspace-engine/internal/llm-router/telemetry"
+	"workspace-engine/pkg/logger"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
 )
 
+var tracer = otel.Tracer("workspace-engine/internal/llm-router/service")
+
 type RouterService struct {
-	providers map[string]llm.Provider
+	providers   *ProviderRegistry
+	catalog     *CatalogService
+	templates   *TemplateService
+	usage       *UsageService
+	shadows     *ShadowService
+	credentials *CredentialService
+	flights     atomic.Pointer[flightGroup]
+	rules       atomic.Pointer[RuleEngine]
+
+	// reloading is held for writing while a configuration is applied and for reading while a
+	// request is routed, so a route never mixes the providers and rules of two configurations
+	reloading sync.RWMutex
 }
 
-func NewRouterService(providers map[string]llm.Provider) *RouterService {
+func NewRouterService(
+	providers map[string]llm.Provider,
+	catalog *CatalogService,
+	templates *TemplateService,
+	usage *UsageService,
+) *RouterService {
 	return &RouterService{
-		providers: providers,
+		providers: NewProviderRegistry(providers, catalog),
+		catalog:   catalog,
+		templates: templates,
+		usage:     usage,
 	}
 }
 
+// SetShadows enables the shadow requests of the routing rules
+func (s *RouterService) SetShadows(shadows *ShadowService) {
+	s.shadows = shadows
+}
+
+// SetCredentials lets allowed callers send requests with their own provider API keys
+func (s *RouterService) SetCredentials(credentials *CredentialService) {
+	s.credentials = credentials
+}
+
+// SetCoalescing lets concurrent identical requests share one provider call. Calls in flight keep
+// being shared when coalescing stays enabled.
+func (s *RouterService) SetCoalescing(enabled bool) {
+	if !enabled {
+		s.flights.Store(nil)
+	} else if s.flights.Load() == nil {
+		s.flights.Store(newFlightGroup())
+	}
+}
+
+// SetRules replaces the routing rules, nil routes every request automatically
+func (s *RouterService) SetRules(rules *RuleEngine) {
+	s.rules.Store(rules)
+}
+
+// Reload applies a new configuration. The providers, the model catalog and the routing rules are
+// replaced together, requests in flight finish on the providers they were routed to.
+func (s *RouterService) Reload(
+	providers map[string]llm.Provider, listers map[string]llm.ModelLister, cfg *config.Config,
+) error {
+	rules, err := NewRuleEngine(cfg.Routing)
+	if err != nil {
+		return err
+	}
+
+	s.reloading.Lock()
+	defer s.reloading.Unlock()
+
+	s.catalog.Reload(providers, listers, cfg)
+	s.providers.Reload(providers)
+	s.SetRules(rules)
+	s.SetCoalescing(cfg.Routing.Coalesce)
+	return nil
+}
+
 func (s *RouterService) Route(ctx context.Context, req models.RouteRequest) (*models.RouteResponse, error) {
-	provider := s.selectProvider(req)
-	if provider == nil {
-		return nil, errors.New("no suitable provider found")
+	req, tmpl, err := s.applyTemplate(req)
+	if err != nil {
+		return nil, err
+	}
+
+	route, err := s.resolveProvider(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if flights, key, ok := s.coalesceKey(ctx, route, req); ok {
+		resp, shared, err := flights.do(
+			ctx, key, func(ctx context.Context) (*models.RouteResponse, error) {
+				return s.complete(ctx, route, req, tmpl)
+			},
+		)
+		if err != nil || !shared {
+			return resp, err
+		}
+		return coalescedResponse(resp), nil
+	}
+	return s.complete(ctx, route, req, tmpl)
+}
+
+// complete sends a routed request, falling back to the next model when its rule allows, and
+// records its usage
+func (s *RouterService) complete(
+	ctx context.Context, route *routeDecision, req models.RouteRequest, tmpl *models.PromptTemplate,
+) (*models.RouteResponse, error) {
+	var resp *models.RouteResponse
+	var provider llm.Provider
+	var err error
+	start := time.Now()
+	for {
+		if provider, err = s.callerProvider(ctx, route); err != nil {
+			return nil, err
+		}
+		done := s.providers.Track(route.key)
+		resp, err = s.generate(ctx, route.key, provider, req)
+		done(err)
+		if err == nil || !s.fallback(ctx, route, err) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.mirror(ctx, route, req, resp, time.Since(start))
+
+	if route.upgradedFrom != "" {
+		if resp.Metadata == nil {
+			resp.Metadata = map[string]interface{}{}
+		}
+		resp.Metadata["upgraded_from"] = route.upgradedFrom
+	}
+	if tmpl != nil {
+		if resp.Metadata == nil {
+			resp.Metadata = map[string]interface{}{}
+		}
+		resp.Metadata["template"] = tmpl.Name
+		resp.Metadata["template_version"] = tmpl.Version
 	}
 
-	return provider.Generate(ctx, req.Prompt, req.Parameters)
+	s.recordUsage(ctx, resp, tmpl)
+	return resp, nil
+}
+
+// recordUsage stores the usage of a request, a failure is only logged
+func (s *RouterService) recordUsage(ctx context.Context, resp *models.RouteResponse, tmpl *models.PromptTemplate) {
+	if s.usage == nil {
+		return
+	}
+	if err := s.usage.Record(CallerFromContext(ctx), resp, tmpl); err != nil {
+		logger.WarnContext(ctx, "Failed to record usage", "error", err)
+	}
+}
+
+// generate sends the request to a provider, with the conversation history trimmed to the context
+// window of its model
+func (s *RouterService) generate(
+	ctx context.Context, key string, provider llm.Provider, req models.RouteRequest,
+) (*models.RouteResponse, error) {
+	req.Messages = trimMessages(req, s.modelInfo(key, provider).MaxTokens)
+	if req.ResponseFormat != nil {
+		return s.generateStructured(ctx, provider, req)
+	}
+	return provider.Generate(ctx, req.Prompt, providerParams(req))
 }
 
 func (s *RouterService) RouteStream(ctx context.Context, req models.RouteRequest) (
 	<-chan models.StreamResponse, error,
 ) {
-	provider := s.selectProvider(req)
-	if provider == nil {
+	req, tmpl, err := s.applyTemplate(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.ResponseFormat != nil {
+		return nil, errors.New("structured output is not supported for streaming requests")
+	}
+
+	route, err := s.resolveProvider(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if flights, key, ok := s.coalesceKey(ctx, route, req); ok {
+		stream, _, err := flights.stream(
+			ctx, key, func(ctx context.Context) (<-chan models.StreamResponse, error) {
+				return s.openStream(ctx, route, req, tmpl)
+			},
+		)
+		return stream, err
+	}
+	return s.openStream(ctx, route, req, tmpl)
+}
+
+// openStream establishes the stream of a routed request. Once the last chunk has arrived its usage
+// is recorded and the request is mirrored to the shadow model of its rule.
+func (s *RouterService) openStream(
+	ctx context.Context, route *routeDecision, req models.RouteRequest, tmpl *models.PromptTemplate,
+) (<-chan models.StreamResponse, error) {
+	// A stream falls back to the next model only while it is being established
+	start := time.Now()
+	for {
+		provider, err := s.callerProvider(ctx, route)
+		if err != nil {
+			return nil, err
+		}
+		done := s.providers.Track(route.key)
+		streamReq := req
+		streamReq.Messages = trimMessages(req, s.modelInfo(route.key, route.provider).MaxTokens)
+		stream, err := provider.GenerateStream(ctx, req.Prompt, providerParams(streamReq))
+		if err == nil {
+			if route.upgradedFrom != "" {
+				stream = withMetadata(ctx, stream, map[string]interface{}{"upgraded_from": route.upgradedFrom})
+			}
+			model := s.modelInfo(route.key, route.provider).ID
+			return trackStream(
+				ctx, stream, func(content string, last models.StreamResponse, err error) {
+					done(err)
+					if err != nil || !last.Done {
+						return
+					}
+					resp := streamedResponse(route.key, model, content, last)
+					s.mirror(ctx, route, req, resp, time.Since(start))
+					s.recordUsage(ctx, resp, tmpl)
+				},
+			), nil
+		}
+		done(err)
+		if !s.fallback(ctx, route, err) {
+			return nil, err
+		}
+	}
+}
+
+// withMetadata adds the metadata to the first chunk of a stream
+func withMetadata(
+	ctx context.Context, upstream <-chan models.StreamResponse, metadata map[string]interface{},
+) <-chan models.StreamResponse {
+	stream := make(chan models.StreamResponse)
+	go func() {
+		defer close(stream)
+
+		first := true
+		for msg := range upstream {
+			if first {
+				msg.Metadata = metadata
+				first = false
+			}
+			select {
+			case stream <- msg:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return stream
+}
+
+// streamedResponse is the response of a finished stream as far as usage and shadow records need it
+func streamedResponse(key, model, content string, last models.StreamResponse) *models.RouteResponse {
+	resp := &models.RouteResponse{
+		ID:       last.ID,
+		Result:   content,
+		Model:    model,
+		Metadata: map[string]interface{}{"provider": providerVendor(key)},
+	}
+	if last.Usage != nil {
+		resp.Usage = *last.Usage
+	}
+	return resp
+}
+
+// DryRun returns how a request would be routed without sending it
+func (s *RouterService) DryRun(ctx context.Context, req models.RouteRequest) (*models.RoutePlan, error) {
+	req, _, err := s.applyTemplate(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateRequest(req); err != nil {
+		return nil, err
+	}
+
+	s.reloading.RLock()
+	defer s.reloading.RUnlock()
+
+	route := s.planRoute(ctx, req, requiredCapabilities(req))
+	plan := &models.RoutePlan{
+		Rule:       route.rule,
+		Strategy:   route.strategy,
+		Candidates: route.keys,
+		Shadow:     route.shadow,
+		Reason:     route.reason,
+	}
+	if plan.Candidates == nil {
+		plan.Candidates = []string{}
+	}
+	if !s.nextProvider(ctx, route) {
+		plan.Reason = "no suitable provider found"
+		return plan, nil
+	}
+
+	plan.ProviderKey = route.key
+	plan.Model = s.modelInfo(route.key, route.provider).ID
+	plan.UpgradedFrom = route.upgradedFrom
+	return plan, nil
+}
+
+func (s *RouterService) applyTemplate(req models.RouteRequest) (models.RouteRequest, *models.PromptTemplate, error) {
+	if req.Template == nil {
+		return req, nil, nil
+	}
+	if s.templates == nil {
+		return req, nil, errors.New("prompt templates are not available")
+	}
+	return s.templates.Apply(req)
+}
+
+// routeDecision is the outcome of routing a request: the provider keys it may be sent to, in
+// order of preference, and the provider currently selected among them
+type routeDecision struct {
+	rule     string
+	strategy string
+	reason   string
+	keys     []string
+	next     int
+	// shadow is the model the request is mirrored to
+	shadow string
+	// tokens, required and content are the context window, the capabilities and the content parts a
+	// model needs to handle for the request
+	tokens   int
+	required []string
+	content  []models.ContentPart
+
+	key      string
+	provider llm.Provider
+	// upgradedFrom is the model the selected provider replaced because the request did not fit
+	upgradedFrom string
+}
+
+// resolveProvider validates the request content and selects the provider that serves it
+func (s *RouterService) resolveProvider(ctx context.Context, req models.RouteRequest) (*routeDecision, error) {
+	if err := validateRequest(req); err != nil {
+		return nil, err
+	}
+	if err := s.credentials.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	s.reloading.RLock()
+	defer s.reloading.RUnlock()
+
+	required := requiredCapabilities(req)
+	route := s.planRoute(ctx, req, required)
+	if !s.nextProvider(ctx, route) {
+		if len(required) > 0 {
+			return nil, fmt.Errorf(
+				"%w: no model has the %v capabilities and reads the request content", ErrUnsupportedContent, required,
+			)
+		}
 		return nil, errors.New("no suitable provider found")
 	}
 
-	return provider.GenerateStream(ctx, req.Prompt, req.Parameters)
+	// A preferred model is not filtered by capabilities and content while planning
+	info := s.modelInfo(route.key, route.provider)
+	if !hasCapabilities(info, required) {
+		return nil, fmt.Errorf("%w: %s lacks the %v capabilities", ErrUnsupportedContent, info.ID, required)
+	}
+	if part := unsupportedPart(route.provider, req.Content); part != "" {
+		return nil, fmt.Errorf("%w: %s cannot read %s parts", ErrUnsupportedContent, info.ID, part)
+	}
+	return route, nil
 }
 
-func (s *RouterService) selectProvider(req models.RouteRequest) llm.Provider {
+// planRoute decides which providers a request may be sent to. An available preferred model wins,
+// then the first matching routing rule, and requests without either are spread across all enabled
+// providers by weight.
+func (s *RouterService) planRoute(ctx context.Context, req models.RouteRequest, required []string) *routeDecision {
+	_, span := tracer.Start(ctx, "router.select_provider")
+	defer span.End()
+	span.SetAttributes(telemetry.AttrPreferredModel.String(req.PreferredModel))
+
+	route := s.selectProviders(ctx, req, required)
+	route.tokens = contextTokens(req)
+	route.required = required
+	route.content = req.Content
+	span.SetAttributes(
+		telemetry.AttrRoutingRule.String(route.rule),
+		telemetry.AttrRouteStrategy.String(route.strategy),
+	)
+	if len(route.keys) > 0 {
+		span.SetAttributes(telemetry.AttrProviderKey.String(route.keys[0]))
+	} else {
+		span.SetStatus(codes.Error, "no suitable provider found")
+	}
+	return route
+}
+
+func (s *RouterService) selectProviders(
+	ctx context.Context, req models.RouteRequest, required []string,
+) *routeDecision {
+	// A preferred model whose provider was disabled is routed like a request without preference
 	if req.PreferredModel != "" {
-		if provider, ok := s.providers[req.PreferredModel]; ok {
-			return provider
+		if _, key, ok := s.catalog.Resolve(req.PreferredModel); ok && s.providers.Enabled(key) {
+			return &routeDecision{strategy: models.RouteStrategyPreferred, keys: []string{key}}
 		}
 	}
 
-	// TODO: Implement provider selection logic based on requirements
-	// This is a simplified version
-	for _, provider := range s.providers {
-		if provider.IsHealthy() {
-			return provider
+	caller := CallerFromContext(ctx)
+	route := &routeDecision{strategy: models.RouteStrategyAutomatic}
+	if rule := s.rules.Load().Match(req, caller); rule != nil {
+		route.rule = rule.Name
+		route.shadow = rule.shadowModel(caller)
+		for _, model := range rule.models(caller) {
+			info, key, ok := s.catalog.Resolve(model)
+			if ok && s.providers.Enabled(key) && s.canServe(key, info, required, req.Content) &&
+				!contains(route.keys, key) {
+				route.keys = append(route.keys, key)
+			}
 		}
+		if len(route.keys) > 0 {
+			route.strategy = rule.strategy()
+			return route
+		}
+		route.reason = fmt.Sprintf("no model of rule %s is available, routing automatically", rule.Name)
 	}
 
-	return nil
+	for _, key := range s.providers.Candidates(s.catalog.ProviderKeys()) {
+		if info, _, _ := s.catalog.Resolve(key); s.canServe(key, info, required, req.Content) {
+			route.keys = append(route.keys, key)
+		}
+	}
+	return route
 }
 
-func (s *RouterService) GetAvailableModels() []models.ModelInfo {
-	var infos []models.ModelInfo
-	for _, provider := range s.providers {
-		infos = append(infos, provider.GetModelInfo())
+// canServe reports whether a provider instance has the capabilities and can send the content parts
+// a request needs
+func (s *RouterService) canServe(
+	key string, info models.ModelInfo, required []string, parts []models.ContentPart,
+) bool {
+	if !hasCapabilities(info, required) {
+		return false
+	}
+	provider, ok := s.providers.Get(key)
+	return ok && unsupportedPart(provider, parts) == ""
+}
+
+// nextProvider selects the next healthy provider of the route, upgraded to a larger model when the
+// request does not fit into its context window. A preferred model is used without checking its
+// health.
+func (s *RouterService) nextProvider(ctx context.Context, route *routeDecision) bool {
+	for route.next < len(route.keys) {
+		key := route.keys[route.next]
+		route.next++
+
+		provider, ok := s.providers.Get(key)
+		if ok && (route.strategy == models.RouteStrategyPreferred || provider.IsHealthy()) {
+			route.key = key
+			route.provider = provider
+			route.upgradedFrom = ""
+			s.upgradeModel(ctx, route)
+			return true
+		}
 	}
-	return infos
+	return false
+}
+
+// callerProvider returns the provider the selected model of a route is called with, which uses the
+// API key of the caller when it sent one for the vendor. Health checks and statistics stay with the
+// shared provider instance.
+func (s *RouterService) callerProvider(ctx context.Context, route *routeDecision) (llm.Provider, error) {
+	return s.credentials.provider(ctx, route.key, s.modelInfo(route.key, route.provider).ID, route.provider)
+}
+
+// upgradeModel moves a request that does not fit into the context window of the selected model to
+// the first of its configured upgrades that is enabled, healthy, has the required capabilities and
+// fits. Without such a model the request stays with the selected model.
+func (s *RouterService) upgradeModel(ctx context.Context, route *routeDecision) {
+	info := s.modelInfo(route.key, route.provider)
+	tokens, required := route.tokens, route.required
+	if info.MaxTokens <= 0 || tokens <= info.MaxTokens {
+		return
+	}
+
+	for _, upgrade := range s.rules.Load().Upgrades() {
+		if _, key, ok := s.catalog.Resolve(upgrade.Model); !ok || key != route.key {
+			continue
+		}
+		for _, model := range upgrade.To {
+			target, key, ok := s.catalog.Resolve(model)
+			if !ok || target.MaxTokens < tokens || !s.canServe(key, target, required, route.content) ||
+				!s.providers.Enabled(key) {
+				continue
+			}
+			if provider, ok := s.providers.Get(key); ok && provider.IsHealthy() {
+				logger.InfoContext(
+					ctx, "Upgrading to a model with a larger context window",
+					"from", info.ID, "to", target.ID, "tokens", tokens,
+				)
+				route.upgradedFrom = info.ID
+				route.key = key
+				route.provider = provider
+				return
+			}
+		}
+		break
+	}
+	logger.WarnContext(
+		ctx, "Request exceeds the context window and no upgrade fits",
+		"model", info.ID, "tokens", tokens, "context_window", info.MaxTokens,
+	)
+}
+
+// fallback moves a request routed by a fallback rule on to the next model after a failure
+func (s *RouterService) fallback(ctx context.Context, route *routeDecision, err error) bool {
+	if route.strategy != models.RouteStrategyFallback || ctx.Err() != nil {
+		return false
+	}
+
+	failed := route.key
+	if !s.nextProvider(ctx, route) {
+		return false
+	}
+	logger.WarnContext(
+		ctx, "Falling back to the next model", "rule", route.rule, "failed", failed, "next", route.key, "error", err,
+	)
+	return true
+}
+
+// mirror sends a copy of a routed request to the shadow model of its rule in the background and
+// stores both responses. Shadow requests are not recorded as usage. Requests sent with provider
+// API keys of the caller are not mirrored, the shadow request would be billed to the router.
+func (s *RouterService) mirror(
+	ctx context.Context, route *routeDecision, req models.RouteRequest, primary *models.RouteResponse,
+	latency time.Duration,
+) {
+	if s.shadows == nil || route.shadow == "" || len(CallerFromContext(ctx).Credentials) > 0 {
+		return
+	}
+	_, key, ok := s.catalog.Resolve(route.shadow)
+	if !ok || key == route.key || !s.providers.Enabled(key) {
+		logger.DebugContext(ctx, "Skipping shadow request", "rule", route.rule, "model", route.shadow)
+		return
+	}
+	provider, _ := s.providers.Get(key)
+
+	record := &store.ShadowRecord{
+		RequestID:        primary.ID,
+		Rule:             route.rule,
+		Prompt:           req.Prompt,
+		PrimaryModel:     primary.Model,
+		PrimaryResult:    primary.Result,
+		PrimaryLatencyMs: latency.Milliseconds(),
+		PrimaryTokens:    primary.Usage.TotalTokens,
+		ShadowModel:      route.shadow,
+	}
+	s.shadows.Go(
+		ctx, func(ctx context.Context) {
+			done := s.providers.Track(key)
+			start := time.Now()
+			resp, err := s.generate(ctx, key, provider, req)
+			done(err)
+
+			record.ShadowLatencyMs = time.Since(start).Milliseconds()
+			if err != nil {
+				record.ShadowError = err.Error()
+			} else {
+				record.ShadowModel = resp.Model
+				record.ShadowResult = resp.Result
+				record.ShadowTokens = resp.Usage.TotalTokens
+			}
+			if err := s.shadows.Record(record); err != nil {
+				logger.WarnContext(ctx, "Failed to record shadow result", "rule", route.rule, "error", err)
+			}
+		},
+	)
+}
+
+// modelInfo returns the catalog entry of a provider instance
+func (s *RouterService) modelInfo(key string, provider llm.Provider) models.ModelInfo {
+	info, _, ok := s.catalog.Resolve(key)
+	if !ok {
+		info = provider.GetModelInfo()
+	}
+	return info
+}
+
+// Providers returns the provider instances the router routes to
+func (s *RouterService) Providers() *ProviderRegistry {
+	return s.providers
+}
+
+// EnabledModel returns the catalog entry of a model given by id or alias when the vendor lists it
+// and its provider instance is enabled
+func (s *RouterService) EnabledModel(model string) (models.ModelInfo, bool) {
+	info, key, ok := s.catalog.Resolve(model)
+	if !ok || !info.Available || !s.providers.Enabled(key) {
+		return models.ModelInfo{}, false
+	}
+	return info, true
+}
+
+func (s *RouterService) GetAvailableModels() []models.ModelInfo {
+	return s.catalog.Models()
 }
 
 func (s *RouterService) GetHealth() models.HealthStatus {
@@ -70,7 +628,7 @@ func (s *RouterService) GetHealth() models.HealthStatus {
 	}
 
 	allHealthy := true
-	for _, provider := range s.providers {
+	for _, provider := range s.providers.Providers() {
 		info := provider.GetModelInfo()
 		isHealthy := provider.IsHealthy()
 		if !isHealthy {
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"regexp"
+	"unicode/utf8"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+)
+
+// RuleEngine evaluates the routing rules of the configuration against requests
+type RuleEngine struct {
+	rules    []routingRule
+	upgrades []config.ModelUpgrade
+}
+
+type routingRule struct {
+	config.RoutingRule
+	regex *regexp.Regexp
+	// keyHashes are the hashes of the API keys the rule matches, so callers that only carry the
+	// hash of their key match too
+	keyHashes []string
+}
+
+// NewRuleEngine compiles the routing rules, keeping their order
+func NewRuleEngine(routing config.RoutingConfig) (*RuleEngine, error) {
+	rules := make([]routingRule, 0, len(routing.Rules))
+	for _, rule := range routing.Rules {
+		compiled := routingRule{RoutingRule: rule}
+		for _, apiKey := range rule.Match.APIKeys {
+			compiled.keyHashes = append(compiled.keyHashes, hashAPIKey(apiKey))
+		}
+		if rule.Match.PromptRegex != "" {
+			regex, err := regexp.Compile(rule.Match.PromptRegex)
+			if err != nil {
+				return nil, fmt.Errorf("invalid prompt_regex of rule %s: %w", rule.Name, err)
+			}
+			compiled.regex = regex
+		}
+		rules = append(rules, compiled)
+	}
+	return &RuleEngine{rules: rules, upgrades: routing.Upgrades}, nil
+}
+
+// Match returns the first rule matching the request, or nil when no rule matches
+func (e *RuleEngine) Match(req models.RouteRequest, caller Caller) *routingRule {
+	if e == nil {
+		return nil
+	}
+
+	required := requiredCapabilities(req)
+	for i := range e.rules {
+		if e.rules[i].matches(req, caller, required) {
+			return &e.rules[i]
+		}
+	}
+	return nil
+}
+
+// Upgrades returns the models requests move to when they do not fit into the context window of a
+// model
+func (e *RuleEngine) Upgrades() []config.ModelUpgrade {
+	if e == nil {
+		return nil
+	}
+	return e.upgrades
+}
+
+func (r *routingRule) matches(req models.RouteRequest, caller Caller, required []string) bool {
+	match := r.Match
+	if len(r.keyHashes) > 0 && !contains(r.keyHashes, caller.owner()) {
+		return false
+	}
+	if len(match.Tenants) > 0 && !contains(match.Tenants, caller.Tenant) {
+		return false
+	}
+
+	length := utf8.RuneCountInString(req.Prompt)
+	if length < match.MinPromptLength || (match.MaxPromptLength > 0 && length > match.MaxPromptLength) {
+		return false
+	}
+
+	for _, capability := range match.Capabilities {
+		if !contains(required, capability) {
+			return false
+		}
+	}
+
+	// Header names are compared case-insensitively, config keys are lowercased when loaded
+	for name, value := range match.Headers {
+		values := caller.Headers.Values(name)
+		if len(values) == 0 || (value != "" && !contains(values, value)) {
+			return false
+		}
+	}
+
+	return r.regex == nil || r.regex.MatchString(req.Prompt)
+}
+
+func (r *routingRule) strategy() string {
+	switch {
+	case len(r.Weighted) > 0:
+		return models.RouteStrategyWeighted
+	case len(r.Fallback) > 0:
+		return models.RouteStrategyFallback
+	default:
+		return models.RouteStrategyModel
+	}
+}
+
+// models returns the models of the rule in the order they are tried. The first model of a
+// weighted rule is assigned to the caller's API key, the remaining ones follow in random order.
+func (r *routingRule) models(caller Caller) []string {
+	switch r.strategy() {
+	case models.RouteStrategyWeighted:
+		weighted := make([]weightedKey, 0, len(r.Weighted))
+		for _, model := range r.Weighted {
+			weighted = append(weighted, weightedKey{key: model.Model, weight: model.Weight})
+		}
+		return stickyWeightedOrder(weighted, assignment(caller, r.Name))
+	case models.RouteStrategyFallback:
+		return r.Fallback
+	default:
+		return []string{r.Model}
+	}
+}
+
+// shadowModel returns the model the request is mirrored to, or an empty string when the caller is
+// not among the share of the rule's traffic that is mirrored. Like a split, the share is assigned to
+// API keys, with a salt of its own so it does not line up with the split of the rule.
+func (r *routingRule) shadowModel(caller Caller) string {
+	if r.Shadow.Model == "" || assignment(caller, r.Name+"/shadow")*100 >= r.Shadow.Percent {
+		return ""
+	}
+	return r.Shadow.Model
+}
+
+// assignment maps a caller to a number in [0, 1) that stays the same for an API key and a salt,
+// so a caller keeps getting the same share of a split. Callers without an API key get a random
+// number.
+func assignment(caller Caller, salt string) float64 {
+	if caller.APIKey == "" && caller.KeyHash == "" {
+		return rand.Float64()
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(salt))
+	hash.Write([]byte{0})
+	hash.Write([]byte(caller.owner()))
+	return float64(hash.Sum64()>>11) / (1 << 53)
+}
+
+// stickyWeightedOrder puts the key whose share of the total weight contains the point first and
+// orders the remaining keys by weight at random
+func stickyWeightedOrder(candidates []weightedKey, point float64) []string {
+	total := 0
+	for _, c := range candidates {
+		total += c.weight
+	}
+
+	pick := point * float64(total)
+	for i, c := range candidates {
+		if pick < float64(c.weight) || i == len(candidates)-1 {
+			rest := append(append([]weightedKey(nil), candidates[:i]...), candidates[i+1:]...)
+			return append([]string{c.key}, weightedOrder(rest)...)
+		}
+		pick -= float64(c.weight)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
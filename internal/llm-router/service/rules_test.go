@@ -0,0 +1,335 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service/llm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingProvider fails every request
+type failingProvider struct {
+	scriptedProvider
+	calls int
+}
+
+func (p *failingProvider) Generate(context.Context, string, map[string]interface{}) (*models.RouteResponse, error) {
+	p.calls++
+	return nil, errors.New("provider unavailable")
+}
+
+func TestRuleEngineMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		match   config.RuleMatch
+		req     models.RouteRequest
+		caller  Caller
+		matches bool
+	}{
+		{
+			name:    "api key",
+			match:   config.RuleMatch{APIKeys: []string{"key-1", "key-2"}},
+			caller:  Caller{APIKey: "key-2"},
+			matches: true,
+		},
+		{
+			name:   "other api key",
+			match:  config.RuleMatch{APIKeys: []string{"key-1"}},
+			caller: Caller{APIKey: "key-2"},
+		},
+		{
+			name:    "tenant",
+			match:   config.RuleMatch{Tenants: []string{"acme"}},
+			caller:  Caller{Tenant: "acme"},
+			matches: true,
+		},
+		{
+			name:    "prompt length",
+			match:   config.RuleMatch{MinPromptLength: 5, MaxPromptLength: 10},
+			req:     models.RouteRequest{Prompt: "hello"},
+			matches: true,
+		},
+		{
+			name:  "prompt too long",
+			match: config.RuleMatch{MaxPromptLength: 10},
+			req:   models.RouteRequest{Prompt: strings.Repeat("a", 11)},
+		},
+		{
+			name:  "requested capability",
+			match: config.RuleMatch{Capabilities: []string{models.CapabilityVision}},
+			req: models.RouteRequest{
+				Content: []models.ContentPart{{Type: models.ContentPartImage, URL: "https://example.com/a.png"}},
+			},
+			matches: true,
+		},
+		{
+			name:  "missing capability",
+			match: config.RuleMatch{Capabilities: []string{models.CapabilityVision}},
+			req:   models.RouteRequest{Prompt: "hello"},
+		},
+		{
+			name:    "header with any value",
+			match:   config.RuleMatch{Headers: map[string]string{"x-team": ""}},
+			caller:  Caller{Headers: http.Header{"X-Team": {"research"}}},
+			matches: true,
+		},
+		{
+			name:   "header with other value",
+			match:  config.RuleMatch{Headers: map[string]string{"x-team": "sales"}},
+			caller: Caller{Headers: http.Header{"X-Team": {"research"}}},
+		},
+		{
+			name:    "prompt regex",
+			match:   config.RuleMatch{PromptRegex: `(?i)^translate\b`},
+			req:     models.RouteRequest{Prompt: "Translate this to German"},
+			matches: true,
+		},
+		{
+			name:   "all conditions",
+			match:  config.RuleMatch{Tenants: []string{"acme"}, PromptRegex: "translate"},
+			req:    models.RouteRequest{Prompt: "translate this"},
+			caller: Caller{Tenant: "other"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				rules, err := NewRuleEngine(
+					config.RoutingConfig{Rules: []config.RoutingRule{{Name: "rule", Match: tt.match, Model: "gpt-4"}}},
+				)
+				require.NoError(t, err)
+				assert.Equal(t, tt.matches, rules.Match(tt.req, tt.caller) != nil)
+			},
+		)
+	}
+}
+
+func TestRouteRules(t *testing.T) {
+	primary := &failingProvider{scriptedProvider: scriptedProvider{model: "primary-model"}}
+	secondary := &scriptedProvider{model: "secondary-model", results: []string{"secondary"}}
+	providers := map[string]llm.Provider{"openai_primary": primary, "anthropic_secondary": secondary}
+	router := NewRouterService(providers, NewCatalogService(providers, nil, &config.Config{}), nil, nil)
+
+	rules, err := NewRuleEngine(
+		config.RoutingConfig{
+			Rules: []config.RoutingRule{
+				{
+					Name:     "acme",
+					Match:    config.RuleMatch{Tenants: []string{"acme"}},
+					Fallback: []string{"primary-model", "secondary-model"},
+				},
+				{
+					Name:  "missing",
+					Match: config.RuleMatch{Tenants: []string{"other"}},
+					Model: "unknown-model",
+				},
+			},
+		},
+	)
+	require.NoError(t, err)
+	router.SetRules(rules)
+
+	acme := WithCaller(context.Background(), Caller{Tenant: "acme"})
+
+	t.Run(
+		"FallsBackAfterFailure", func(t *testing.T) {
+			resp, err := router.Route(acme, models.RouteRequest{Prompt: "hello"})
+			require.NoError(t, err)
+			assert.Equal(t, "secondary", resp.Result)
+			assert.Equal(t, 1, primary.calls)
+		},
+	)
+
+	t.Run(
+		"DryRun", func(t *testing.T) {
+			plan, err := router.DryRun(acme, models.RouteRequest{Prompt: "hello"})
+			require.NoError(t, err)
+			assert.Equal(t, "acme", plan.Rule)
+			assert.Equal(t, models.RouteStrategyFallback, plan.Strategy)
+			assert.Equal(t, []string{"openai_primary", "anthropic_secondary"}, plan.Candidates)
+			assert.Equal(t, "openai_primary", plan.ProviderKey)
+			assert.Equal(t, "primary-model", plan.Model)
+		},
+	)
+
+	t.Run(
+		"DryRunWithoutRule", func(t *testing.T) {
+			plan, err := router.DryRun(context.Background(), models.RouteRequest{Prompt: "hello"})
+			require.NoError(t, err)
+			assert.Empty(t, plan.Rule)
+			assert.Equal(t, models.RouteStrategyAutomatic, plan.Strategy)
+			assert.Len(t, plan.Candidates, 2)
+		},
+	)
+
+	t.Run(
+		"UnavailableRuleModel", func(t *testing.T) {
+			ctx := WithCaller(context.Background(), Caller{Tenant: "other"})
+			plan, err := router.DryRun(ctx, models.RouteRequest{Prompt: "hello"})
+			require.NoError(t, err)
+			assert.Equal(t, "missing", plan.Rule)
+			assert.Equal(t, models.RouteStrategyAutomatic, plan.Strategy)
+			assert.NotEmpty(t, plan.Reason)
+		},
+	)
+
+	t.Run(
+		"PreferredModelWins", func(t *testing.T) {
+			plan, err := router.DryRun(acme, models.RouteRequest{Prompt: "hello", PreferredModel: "secondary-model"})
+			require.NoError(t, err)
+			assert.Empty(t, plan.Rule)
+			assert.Equal(t, models.RouteStrategyPreferred, plan.Strategy)
+			assert.Equal(t, "anthropic_secondary", plan.ProviderKey)
+		},
+	)
+}
+
+func TestStickyWeightedRule(t *testing.T) {
+	rules, err := NewRuleEngine(
+		config.RoutingConfig{
+			Rules: []config.RoutingRule{
+				{
+					Name:     "rollout",
+					Weighted: []config.WeightedModel{{Model: "stable", Weight: 95}, {Model: "candidate", Weight: 5}},
+				},
+			},
+		},
+	)
+	require.NoError(t, err)
+	rule := rules.Match(models.RouteRequest{Prompt: "hello"}, Caller{})
+	require.NotNil(t, rule)
+
+	t.Run(
+		"SameModelPerAPIKey", func(t *testing.T) {
+			caller := Caller{APIKey: "key-1"}
+			first := rule.models(caller)[0]
+			for i := 0; i < 20; i++ {
+				assert.Equal(t, first, rule.models(caller)[0])
+			}
+		},
+	)
+
+	t.Run(
+		"SplitsAcrossAPIKeys", func(t *testing.T) {
+			candidate := 0
+			for i := 0; i < 2000; i++ {
+				if rule.models(Caller{APIKey: fmt.Sprintf("key-%d", i)})[0] == "candidate" {
+					candidate++
+				}
+			}
+			assert.InDelta(t, 100, candidate, 40)
+		},
+	)
+}
+
+func TestContextWindowUpgrade(t *testing.T) {
+	small := &scriptedProvider{model: "small", results: []string{"small"}}
+	large := &scriptedProvider{model: "large", results: []string{"large"}}
+	broken := &failingProvider{scriptedProvider: scriptedProvider{model: "broken"}}
+	providers := map[string]llm.Provider{"openai_small": small, "openai_large": large, "openai_broken": broken}
+	cfg := &config.Config{
+		Providers: config.ProvidersConfig{
+			OpenAI: config.ProviderConfig{
+				Models: []config.ModelConfig{{Name: "small", MaxTokens: 100}, {Name: "large", MaxTokens: 1000}},
+			},
+		},
+	}
+	router := NewRouterService(providers, NewCatalogService(providers, nil, cfg), nil, nil)
+
+	rules, err := NewRuleEngine(
+		config.RoutingConfig{
+			Rules: []config.RoutingRule{
+				{
+					Name:     "acme",
+					Match:    config.RuleMatch{Tenants: []string{"acme"}},
+					Fallback: []string{"broken", "small"},
+				},
+			},
+			Upgrades: []config.ModelUpgrade{{Model: "small", To: []string{"large"}}},
+		},
+	)
+	require.NoError(t, err)
+	router.SetRules(rules)
+
+	t.Run(
+		"FittingRequestKeepsTheModel", func(t *testing.T) {
+			resp, err := router.Route(context.Background(), models.RouteRequest{Prompt: "hello", PreferredModel: "small"})
+			require.NoError(t, err)
+			assert.Equal(t, "small", resp.Result)
+			assert.NotContains(t, resp.Metadata, "upgraded_from")
+		},
+	)
+
+	t.Run(
+		"LongPromptIsUpgraded", func(t *testing.T) {
+			req := models.RouteRequest{Prompt: strings.Repeat("word ", 100), PreferredModel: "small"}
+			resp, err := router.Route(context.Background(), req)
+			require.NoError(t, err)
+			assert.Equal(t, "large", resp.Result)
+			assert.Equal(t, "small", resp.Metadata["upgraded_from"])
+
+			plan, err := router.DryRun(context.Background(), req)
+			require.NoError(t, err)
+			assert.Equal(t, "openai_large", plan.ProviderKey)
+			assert.Equal(t, "small", plan.UpgradedFrom)
+		},
+	)
+
+	t.Run(
+		"FallbackIsUpgraded", func(t *testing.T) {
+			acme := WithCaller(context.Background(), Caller{Tenant: "acme"})
+			resp, err := router.Route(acme, models.RouteRequest{Prompt: strings.Repeat("word ", 100)})
+			require.NoError(t, err)
+			assert.Equal(t, 1, broken.calls)
+			assert.Equal(t, "large", resp.Result)
+			assert.Equal(t, "small", resp.Metadata["upgraded_from"])
+		},
+	)
+
+	t.Run(
+		"StreamReportsTheUpgrade", func(t *testing.T) {
+			req := models.RouteRequest{Prompt: strings.Repeat("word ", 100), PreferredModel: "small"}
+			stream, err := router.RouteStream(context.Background(), req)
+			require.NoError(t, err)
+
+			first := <-stream
+			assert.Equal(t, "large", first.Content)
+			assert.Equal(t, "small", first.Metadata["upgraded_from"])
+			for range stream {
+			}
+		},
+	)
+
+	t.Run(
+		"CompletionCountsTowardsTheWindow", func(t *testing.T) {
+			req := models.RouteRequest{
+				Prompt:         "hello",
+				PreferredModel: "small",
+				Parameters:     map[string]interface{}{"maxTokens": float64(200)},
+			}
+			resp, err := router.Route(context.Background(), req)
+			require.NoError(t, err)
+			assert.Equal(t, "large", resp.Result)
+		},
+	)
+
+	t.Run(
+		"NoUpgradeFits", func(t *testing.T) {
+			req := models.RouteRequest{Prompt: strings.Repeat("word ", 1000), PreferredModel: "small"}
+			resp, err := router.Route(context.Background(), req)
+			require.NoError(t, err)
+			assert.Equal(t, "small", resp.Result)
+			assert.NotContains(t, resp.Metadata, "upgraded_from")
+		},
+	)
+}
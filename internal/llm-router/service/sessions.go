@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/store"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrSessionConflict is returned for a turn that raced another turn of the same session
+	ErrSessionConflict = errors.New("another message was added to the session concurrently")
+)
+
+// SessionService keeps conversations on the server, so clients only send the new message of a
+// turn. Every session belongs to the API key that created it.
+type SessionService struct {
+	db     *gorm.DB
+	router *RouterService
+}
+
+func NewSessionService(db *gorm.DB, router *RouterService) *SessionService {
+	return &SessionService{
+		db:     db,
+		router: router,
+	}
+}
+
+// Create starts an empty session
+func (s *SessionService) Create(apiKey string, req models.SessionRequest) (*models.Session, error) {
+	entity := store.Session{
+		ID:     uuid.New().String(),
+		Owner:  hashAPIKey(apiKey),
+		Title:  req.Title,
+		Model:  req.Model,
+		System: req.System,
+	}
+	if len(req.Parameters) > 0 {
+		params, err := json.Marshal(req.Parameters)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameters: %w", err)
+		}
+		entity.Parameters = string(params)
+	}
+
+	if err := s.db.Create(&entity).Error; err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	return toSessionModel(entity, 0), nil
+}
+
+// List returns the sessions of an API key, most recently used first
+func (s *SessionService) List(apiKey string) ([]models.Session, error) {
+	var entities []store.Session
+	err := s.db.Where("owner = ?", hashAPIKey(apiKey)).Order("updated_at desc").Find(&entities).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	counts, err := s.messageCounts(entities)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]models.Session, 0, len(entities))
+	for _, entity := range entities {
+		sessions = append(sessions, *toSessionModel(entity, counts[entity.ID]))
+	}
+	return sessions, nil
+}
+
+// Get returns a session without its messages
+func (s *SessionService) Get(apiKey, id string) (*models.Session, error) {
+	entity, err := s.load(apiKey, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&store.SessionMessage{}).Where("session_id = ?", id).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to count session messages: %w", err)
+	}
+	return toSessionModel(*entity, int(count)), nil
+}
+
+// Export returns a session with its whole transcript
+func (s *SessionService) Export(apiKey, id string) (*models.Session, error) {
+	entity, err := s.load(apiKey, id)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := s.messages(id)
+	if err != nil {
+		return nil, err
+	}
+
+	session := toSessionModel(*entity, len(messages))
+	session.Messages = make([]models.SessionMessage, 0, len(messages))
+	for _, message := range messages {
+		session.Messages = append(
+			session.Messages, models.SessionMessage{
+				Role:      message.Role,
+				Content:   message.Content,
+				Model:     message.Model,
+				CreatedAt: message.CreatedAt.UTC().Format(time.RFC3339),
+			},
+		)
+	}
+	return session, nil
+}
+
+// Delete removes a session and its transcript
+func (s *SessionService) Delete(apiKey, id string) error {
+	if _, err := s.load(apiKey, id); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(
+		func(tx *gorm.DB) error {
+			if err := tx.Where("session_id = ?", id).Delete(&store.SessionMessage{}).Error; err != nil {
+				return fmt.Errorf("failed to delete session messages: %w", err)
+			}
+			if err := tx.Delete(&store.Session{ID: id}).Error; err != nil {
+				return fmt.Errorf("failed to delete session: %w", err)
+			}
+			return nil
+		},
+	)
+}
+
+// Send routes a user message together with the stored transcript and appends both the message
+// and the reply to the session. A failed turn leaves the transcript unchanged, and so does a turn
+// that another turn of the session overtook while it was routed.
+func (s *SessionService) Send(
+	ctx context.Context, apiKey, id string, turn models.SessionTurn,
+) (*models.RouteResponse, error) {
+	if turn.Content == "" {
+		return nil, fmt.Errorf("%w: the message has no content", ErrInvalidContent)
+	}
+
+	entity, err := s.load(apiKey, id)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.messages(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []models.Message
+	if entity.System != "" {
+		history = append(history, models.Message{Role: models.MessageRoleSystem, Content: entity.System})
+	}
+	for _, message := range stored {
+		history = append(history, models.Message{Role: message.Role, Content: message.Content})
+	}
+
+	params := map[string]interface{}{}
+	if entity.Parameters != "" {
+		if err := json.Unmarshal([]byte(entity.Parameters), &params); err != nil {
+			return nil, fmt.Errorf("failed to decode session parameters: %w", err)
+		}
+	}
+	for k, v := range turn.Parameters {
+		params[k] = v
+	}
+
+	resp, err := s.router.Route(
+		ctx, models.RouteRequest{
+			Prompt:         turn.Content,
+			PreferredModel: entity.Model,
+			Parameters:     params,
+			Messages:       history,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(
+		func(tx *gorm.DB) error {
+			result := tx.Model(&store.Session{}).Where("id = ? AND turns = ?", id, entity.Turns).Updates(
+				map[string]interface{}{"turns": gorm.Expr("turns + 1"), "updated_at": time.Now()},
+			)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrSessionConflict
+			}
+
+			messages := []store.SessionMessage{
+				{SessionID: id, Role: models.MessageRoleUser, Content: turn.Content},
+				{SessionID: id, Role: models.MessageRoleAssistant, Content: resp.Result, Model: resp.Model},
+			}
+			return tx.Create(&messages).Error
+		},
+	)
+	if errors.Is(err, ErrSessionConflict) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to store session messages: %w", err)
+	}
+
+	if resp.Metadata == nil {
+		resp.Metadata = map[string]interface{}{}
+	}
+	resp.Metadata["session"] = id
+	return resp, nil
+}
+
+// load returns a session of the API key, sessions of other keys are reported as not found
+func (s *SessionService) load(apiKey, id string) (*store.Session, error) {
+	var entity store.Session
+	err := s.db.Where("id = ? AND owner = ?", id, hashAPIKey(apiKey)).First(&entity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	return &entity, nil
+}
+
+func (s *SessionService) messages(id string) ([]store.SessionMessage, error) {
+	var messages []store.SessionMessage
+	if err := s.db.Where("session_id = ?", id).Order("id").Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("failed to load session messages: %w", err)
+	}
+	return messages, nil
+}
+
+func (s *SessionService) messageCounts(sessions []store.Session) (map[string]int, error) {
+	counts := make(map[string]int, len(sessions))
+	if len(sessions) == 0 {
+		return counts, nil
+	}
+
+	ids := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.ID)
+	}
+
+	var rows []struct {
+		SessionID string
+		Count     int
+	}
+	err := s.db.Model(&store.SessionMessage{}).
+		Select("session_id, count(*) as count").
+		Where("session_id IN ?", ids).
+		Group("session_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count session messages: %w", err)
+	}
+	for _, row := range rows {
+		counts[row.SessionID] = row.Count
+	}
+	return counts, nil
+}
+
+func toSessionModel(entity store.Session, messageCount int) *models.Session {
+	session := &models.Session{
+		ID:           entity.ID,
+		Title:        entity.Title,
+		Model:        entity.Model,
+		System:       entity.System,
+		MessageCount: messageCount,
+		CreatedAt:    entity.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:    entity.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+	if entity.Parameters != "" {
+		_ = json.Unmarshal([]byte(entity.Parameters), &session.Parameters)
+	}
+	return session
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessions(t *testing.T) {
+	db, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+
+	provider := &scriptedProvider{model: "chat-model", results: []string{"Hi Ada", "Your name is Ada"}}
+	sessions := NewSessionService(db, newTestRouter(provider))
+
+	session, err := sessions.Create("key-1", models.SessionRequest{Title: "Intro", System: "Be brief"})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	t.Run(
+		"SendsTranscript", func(t *testing.T) {
+			resp, err := sessions.Send(ctx, "key-1", session.ID, models.SessionTurn{Content: "I am Ada"})
+			require.NoError(t, err)
+			assert.Equal(t, "Hi Ada", resp.Result)
+			assert.Equal(t, session.ID, resp.Metadata["session"])
+
+			_, err = sessions.Send(ctx, "key-1", session.ID, models.SessionTurn{Content: "What is my name?"})
+			require.NoError(t, err)
+
+			assert.Equal(t, "What is my name?", provider.prompts[1])
+			assert.Equal(
+				t, []models.Message{
+					{Role: models.MessageRoleSystem, Content: "Be brief"},
+					{Role: models.MessageRoleUser, Content: "I am Ada"},
+					{Role: models.MessageRoleAssistant, Content: "Hi Ada"},
+				}, provider.params[1]["messages"],
+			)
+		},
+	)
+
+	t.Run(
+		"Export", func(t *testing.T) {
+			exported, err := sessions.Export("key-1", session.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 4, exported.MessageCount)
+			require.Len(t, exported.Messages, 4)
+			assert.Equal(t, models.MessageRoleAssistant, exported.Messages[3].Role)
+			assert.Equal(t, "Your name is Ada", exported.Messages[3].Content)
+		},
+	)
+
+	t.Run(
+		"ScopedToAPIKey", func(t *testing.T) {
+			_, err := sessions.Get("key-2", session.ID)
+			assert.ErrorIs(t, err, ErrSessionNotFound)
+
+			list, err := sessions.List("key-2")
+			require.NoError(t, err)
+			assert.Empty(t, list)
+
+			list, err = sessions.List("key-1")
+			require.NoError(t, err)
+			require.Len(t, list, 1)
+			assert.Equal(t, 4, list[0].MessageCount)
+		},
+	)
+
+	t.Run(
+		"ConcurrentTurnsAreNotInterleaved", func(t *testing.T) {
+			blocking := &blockingProvider{release: make(chan struct{})}
+			sessions := NewSessionService(db, newTestRouter(blocking))
+			session, err := sessions.Create("key-1", models.SessionRequest{})
+			require.NoError(t, err)
+
+			errs := make(chan error, 2)
+			for _, content := range []string{"first", "second"} {
+				go func(content string) {
+					_, err := sessions.Send(ctx, "key-1", session.ID, models.SessionTurn{Content: content})
+					errs <- err
+				}(content)
+			}
+			// Both turns read the empty transcript before either is stored
+			require.Eventually(
+				t, func() bool { return blocking.callCount() == 2 }, time.Second, time.Millisecond,
+			)
+			close(blocking.release)
+
+			var conflicts int
+			for i := 0; i < 2; i++ {
+				if err := <-errs; err != nil {
+					assert.ErrorIs(t, err, ErrSessionConflict)
+					conflicts++
+				}
+			}
+			assert.Equal(t, 1, conflicts)
+
+			exported, err := sessions.Export("key-1", session.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 2, exported.MessageCount)
+		},
+	)
+
+	t.Run(
+		"Delete", func(t *testing.T) {
+			assert.ErrorIs(t, sessions.Delete("key-2", session.ID), ErrSessionNotFound)
+			require.NoError(t, sessions.Delete("key-1", session.ID))
+			_, err := sessions.Get("key-1", session.ID)
+			assert.ErrorIs(t, err, ErrSessionNotFound)
+		},
+	)
+}
+
+func TestTrimMessages(t *testing.T) {
+	// Every message is 10 tokens
+	message := func(role string) models.Message {
+		return models.Message{Role: role, Content: "0123456789012345678901234567890123456789"}
+	}
+	req := models.RouteRequest{
+		Prompt: "0123456789012345678901234567890123456789",
+		Messages: []models.Message{
+			message(models.MessageRoleSystem),
+			message(models.MessageRoleUser),
+			message(models.MessageRoleAssistant),
+			message(models.MessageRoleUser),
+			message(models.MessageRoleAssistant),
+		},
+		Parameters: map[string]interface{}{"maxTokens": 10},
+	}
+
+	t.Run(
+		"FitsWindow", func(t *testing.T) {
+			assert.Equal(t, req.Messages, trimMessages(req, 100))
+		},
+	)
+
+	t.Run(
+		"DropsOldestKeepsSystem", func(t *testing.T) {
+			// 50 tokens leave room for the system message and the last two messages
+			trimmed := trimMessages(req, 50)
+			assert.Equal(t, []models.Message{req.Messages[0], req.Messages[3], req.Messages[4]}, trimmed)
+		},
+	)
+
+	t.Run(
+		"NeverStartsWithAssistant", func(t *testing.T) {
+			// 40 tokens leave room for the system message and the last message only
+			trimmed := trimMessages(req, 40)
+			assert.Equal(t, []models.Message{req.Messages[0]}, trimmed)
+		},
+	)
+
+	t.Run(
+		"UnknownWindow", func(t *testing.T) {
+			assert.Equal(t, req.Messages, trimMessages(req, 0))
+		},
+	)
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/store"
+	"workspace-engine/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultShadowConcurrency = 8
+	defaultShadowTimeout     = time.Minute
+	defaultShadowLimit       = 100
+	defaultShadowRetention   = 7 * 24 * time.Hour
+	shadowPruneInterval      = time.Hour
+)
+
+// ShadowService runs the shadow requests of the routing rules in the background and stores their
+// responses next to the responses the callers got. Results are deleted once they are older than the
+// retention, and stored without prompts and responses when redaction is on.
+type ShadowService struct {
+	db        *gorm.DB
+	slots     chan struct{}
+	timeout   time.Duration
+	retention time.Duration
+	redact    bool
+	wg        sync.WaitGroup
+}
+
+func NewShadowService(db *gorm.DB, cfg config.ShadowConfig) *ShadowService {
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultShadowConcurrency
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultShadowTimeout
+	}
+	retention := cfg.Retention
+	if retention <= 0 {
+		retention = defaultShadowRetention
+	}
+
+	return &ShadowService{
+		db:        db,
+		slots:     make(chan struct{}, concurrency),
+		timeout:   timeout,
+		retention: retention,
+		redact:    cfg.Redact,
+	}
+}
+
+// Start deletes expired shadow results every hour until the context is done
+func (s *ShadowService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(shadowPruneInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.Prune(time.Now()); err != nil {
+				logger.WarnContext(ctx, "Failed to delete expired shadow results", "error", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Prune deletes the shadow results that are older than the retention at the given time
+func (s *ShadowService) Prune(now time.Time) error {
+	err := s.db.Where("created_at < ?", now.Add(-s.retention)).Delete(&store.ShadowRecord{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to delete shadow results: %w", err)
+	}
+	return nil
+}
+
+// Go runs a shadow request detached from the caller's request. The request is dropped when the
+// maximum number of shadow requests is already in flight, so shadows never queue up.
+func (s *ShadowService) Go(ctx context.Context, run func(ctx context.Context)) bool {
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		logger.WarnContext(ctx, "Dropping shadow request, too many in flight")
+		return false
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() { <-s.slots }()
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.timeout)
+		defer cancel()
+		run(ctx)
+	}()
+	return true
+}
+
+// Wait blocks until the running shadow requests have finished
+func (s *ShadowService) Wait() {
+	s.wg.Wait()
+}
+
+// Record stores the outcome of a shadow request
+func (s *ShadowService) Record(record *store.ShadowRecord) error {
+	if s.redact {
+		record.Prompt = ""
+		record.PrimaryResult = ""
+		record.ShadowResult = ""
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		return fmt.Errorf("failed to record shadow result: %w", err)
+	}
+	return nil
+}
+
+// List returns the stored shadow results, newest first
+func (s *ShadowService) List(filter models.ShadowFilter) ([]models.ShadowResult, error) {
+	query := s.db.Model(&store.ShadowRecord{})
+	if filter.Rule != "" {
+		query = query.Where("rule = ?", filter.Rule)
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultShadowLimit
+	}
+
+	var records []store.ShadowRecord
+	if err := query.Order("created_at desc, id desc").Limit(limit).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to list shadow results: %w", err)
+	}
+
+	results := make([]models.ShadowResult, 0, len(records))
+	for _, record := range records {
+		results = append(
+			results, models.ShadowResult{
+				ID:               record.ID,
+				RequestID:        record.RequestID,
+				Rule:             record.Rule,
+				Prompt:           record.Prompt,
+				PrimaryModel:     record.PrimaryModel,
+				PrimaryResult:    record.PrimaryResult,
+				PrimaryLatencyMs: record.PrimaryLatencyMs,
+				PrimaryTokens:    record.PrimaryTokens,
+				ShadowModel:      record.ShadowModel,
+				ShadowResult:     record.ShadowResult,
+				ShadowError:      record.ShadowError,
+				ShadowLatencyMs:  record.ShadowLatencyMs,
+				ShadowTokens:     record.ShadowTokens,
+				CreatedAt:        record.CreatedAt.UTC().Format(time.RFC3339),
+			},
+		)
+	}
+	return results, nil
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service/llm"
+	"workspace-engine/internal/llm-router/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShadowTraffic(t *testing.T) {
+	db, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+
+	primary := &scriptedProvider{model: "primary-model", results: []string{"primary"}}
+	candidate := &scriptedProvider{model: "candidate-model", results: []string{"candidate"}}
+	providers := map[string]llm.Provider{"openai_primary": primary, "anthropic_candidate": candidate}
+	router := NewRouterService(providers, NewCatalogService(providers, nil, &config.Config{}), nil, nil)
+	shadows := NewShadowService(db, config.ShadowConfig{})
+	router.SetShadows(shadows)
+
+	rules, err := NewRuleEngine(
+		config.RoutingConfig{
+			Rules: []config.RoutingRule{
+				{
+					Name:   "rollout",
+					Model:  "primary-model",
+					Shadow: config.ShadowTarget{Model: "candidate-model", Percent: 100},
+				},
+			},
+		},
+	)
+	require.NoError(t, err)
+	router.SetRules(rules)
+
+	ctx := WithCaller(context.Background(), Caller{APIKey: "key-1"})
+
+	t.Run(
+		"StoresBothResponses", func(t *testing.T) {
+			resp, err := router.Route(ctx, models.RouteRequest{Prompt: "hello"})
+			require.NoError(t, err)
+			assert.Equal(t, "primary", resp.Result)
+			shadows.Wait()
+
+			results, err := shadows.List(models.ShadowFilter{Rule: "rollout"})
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			assert.Equal(t, resp.ID, results[0].RequestID)
+			assert.Equal(t, "hello", results[0].Prompt)
+			assert.Equal(t, "primary", results[0].PrimaryResult)
+			assert.Equal(t, "candidate", results[0].ShadowResult)
+			assert.Empty(t, results[0].ShadowError)
+		},
+	)
+
+	t.Run(
+		"SkipsCallersWithTheirOwnKeys", func(t *testing.T) {
+			credentials := NewCredentialService(
+				db, func(vendor, model, apiKey string) (llm.Provider, error) {
+					return &scriptedProvider{model: model, results: []string{"own key"}}, nil
+				},
+			)
+			_, err := credentials.SetPolicy("key-byok", true)
+			require.NoError(t, err)
+			router.SetCredentials(credentials)
+			defer router.SetCredentials(nil)
+
+			byok := WithCaller(context.Background(), Caller{APIKey: "key-byok", Credentials: map[string]string{"openai": "sk"}})
+			resp, err := router.Route(byok, models.RouteRequest{Prompt: "mine"})
+			require.NoError(t, err)
+			assert.Equal(t, "own key", resp.Result)
+			shadows.Wait()
+
+			results, err := shadows.List(models.ShadowFilter{Rule: "rollout"})
+			require.NoError(t, err)
+			for _, result := range results {
+				assert.NotEqual(t, "mine", result.Prompt)
+			}
+		},
+	)
+
+	t.Run(
+		"MirrorsStreams", func(t *testing.T) {
+			stream, err := router.RouteStream(ctx, models.RouteRequest{Prompt: "streamed"})
+			require.NoError(t, err)
+			for range stream {
+			}
+
+			var mirrored *models.ShadowResult
+			require.Eventually(
+				t, func() bool {
+					results, err := shadows.List(models.ShadowFilter{Rule: "rollout"})
+					if err != nil {
+						return false
+					}
+					for _, result := range results {
+						if result.Prompt == "streamed" {
+							mirrored = &result
+							return true
+						}
+					}
+					return false
+				}, time.Second, time.Millisecond,
+			)
+			shadows.Wait()
+			assert.Equal(t, "primary", mirrored.PrimaryResult)
+			assert.Equal(t, "candidate", mirrored.ShadowResult)
+		},
+	)
+
+	t.Run(
+		"AssignsAPIKeys", func(t *testing.T) {
+			sampled, err := NewRuleEngine(
+				config.RoutingConfig{
+					Rules: []config.RoutingRule{
+						{
+							Name:   "half",
+							Model:  "primary-model",
+							Shadow: config.ShadowTarget{Model: "candidate-model", Percent: 50},
+						},
+					},
+				},
+			)
+			require.NoError(t, err)
+
+			// An API key gets the same decision on every request, the share is spread over the keys
+			rule := sampled.Match(models.RouteRequest{Prompt: "hello"}, Caller{APIKey: "key-1"})
+			require.NotNil(t, rule)
+			mirrored := 0
+			for i := 0; i < 200; i++ {
+				caller := Caller{APIKey: fmt.Sprintf("key-%d", i)}
+				shadow := rule.shadowModel(caller)
+				for j := 0; j < 5; j++ {
+					assert.Equal(t, shadow, rule.shadowModel(caller))
+				}
+				if shadow != "" {
+					mirrored++
+				}
+			}
+			assert.Greater(t, mirrored, 0)
+			assert.Less(t, mirrored, 200)
+		},
+	)
+
+	t.Run(
+		"DryRunShowsShadow", func(t *testing.T) {
+			plan, err := router.DryRun(ctx, models.RouteRequest{Prompt: "hello"})
+			require.NoError(t, err)
+			assert.Equal(t, "primary-model", plan.Model)
+			assert.Equal(t, "candidate-model", plan.Shadow)
+		},
+	)
+
+	t.Run(
+		"DropsWhenFull", func(t *testing.T) {
+			full := NewShadowService(db, config.ShadowConfig{Concurrency: 1})
+			release := make(chan struct{})
+			require.True(t, full.Go(ctx, func(context.Context) { <-release }))
+			assert.False(t, full.Go(ctx, func(context.Context) {}))
+			close(release)
+			full.Wait()
+		},
+	)
+
+	t.Run(
+		"Redacts", func(t *testing.T) {
+			redacted := NewShadowService(db, config.ShadowConfig{Redact: true})
+			require.NoError(
+				t, redacted.Record(
+					&store.ShadowRecord{
+						Rule:          "redacted",
+						Prompt:        "secret",
+						PrimaryResult: "primary",
+						ShadowResult:  "candidate",
+						ShadowTokens:  7,
+					},
+				),
+			)
+
+			results, err := redacted.List(models.ShadowFilter{Rule: "redacted"})
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			assert.Empty(t, results[0].Prompt)
+			assert.Empty(t, results[0].PrimaryResult)
+			assert.Empty(t, results[0].ShadowResult)
+			assert.Equal(t, 7, results[0].ShadowTokens)
+		},
+	)
+
+	t.Run(
+		"DeletesExpiredResults", func(t *testing.T) {
+			expiring := NewShadowService(db, config.ShadowConfig{Retention: time.Hour})
+			require.NoError(t, expiring.Record(&store.ShadowRecord{Rule: "expiring"}))
+
+			require.NoError(t, expiring.Prune(time.Now()))
+			results, err := expiring.List(models.ShadowFilter{Rule: "expiring"})
+			require.NoError(t, err)
+			assert.Len(t, results, 1)
+
+			require.NoError(t, expiring.Prune(time.Now().Add(2*time.Hour)))
+			results, err = expiring.List(models.ShadowFilter{Rule: "expiring"})
+			require.NoError(t, err)
+			assert.Empty(t, results)
+		},
+	)
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service/llm"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+const (
+	defaultStructuredRetries = 2
+	maxStructuredRetries     = 5
+)
+
+// generateStructured asks the provider for JSON output, validates it against the requested schema
+// and asks the model to repair invalid output until it validates or the retries run out
+func (s *RouterService) generateStructured(
+	ctx context.Context, provider llm.Provider, req models.RouteRequest,
+) (*models.RouteResponse, error) {
+	format := req.ResponseFormat
+	schema, err := compileSchema(format)
+	if err != nil {
+		return nil, err
+	}
+
+	retries := format.MaxRetries
+	if retries <= 0 {
+		retries = defaultStructuredRetries
+	}
+	if retries > maxStructuredRetries {
+		retries = maxStructuredRetries
+	}
+
+	params := providerParams(req)
+
+	// Use the native JSON mode where the provider has one. A schema is only enforced natively by
+	// json_schema mode, so it is spelled out in the prompt for everything else.
+	prompt := req.Prompt
+	mode := ""
+	if p, ok := provider.(llm.StructuredOutputProvider); ok {
+		mode = p.StructuredOutputMode()
+	}
+	if mode != "" {
+		params["responseFormat"] = format
+	}
+	if mode != models.ResponseFormatJSONSchema || len(format.Schema) == 0 {
+		prompt = structuredPrompt(prompt, format)
+	}
+
+	var usage models.Usage
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		resp, err := provider.Generate(ctx, prompt, params)
+		if err != nil {
+			return nil, err
+		}
+
+		usage.PromptTokens += resp.Usage.PromptTokens
+		usage.CompletionTokens += resp.Usage.CompletionTokens
+		usage.TotalTokens += resp.Usage.TotalTokens
+
+		parsed, err := parseStructured(resp.Result, schema)
+		if err == nil {
+			resp.Parsed = parsed
+			resp.Usage = usage
+			if resp.Metadata == nil {
+				resp.Metadata = map[string]interface{}{}
+			}
+			resp.Metadata["structured_attempts"] = attempt + 1
+			return resp, nil
+		}
+
+		lastErr = err
+		prompt = repairPrompt(req.Prompt, format, resp.Result, err)
+	}
+
+	return nil, fmt.Errorf("model output did not match the response format after %d attempts: %w", retries+1, lastErr)
+}
+
+func compileSchema(format *models.ResponseFormat) (*jsonschema.Schema, error) {
+	switch format.Type {
+	case models.ResponseFormatJSONObject:
+		if len(format.Schema) == 0 {
+			return nil, nil
+		}
+	case models.ResponseFormatJSONSchema:
+		if len(format.Schema) == 0 {
+			return nil, errors.New("responseFormat.schema is required for json_schema")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported response format: %s", format.Type)
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(format.Schema))
+	if err != nil {
+		return nil, fmt.Errorf("invalid response schema: %w", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("response.json", doc); err != nil {
+		return nil, fmt.Errorf("invalid response schema: %w", err)
+	}
+	schema, err := compiler.Compile("response.json")
+	if err != nil {
+		return nil, fmt.Errorf("invalid response schema: %w", err)
+	}
+	return schema, nil
+}
+
+// parseStructured decodes the model output, tolerating markdown code fences and text around the
+// JSON value, and validates it against the schema when there is one
+func parseStructured(output string, schema *jsonschema.Schema) (interface{}, error) {
+	raw := extractJSON(output)
+
+	value, err := jsonschema.UnmarshalJSON(strings.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("output is not valid JSON: %w", err)
+	}
+
+	if schema != nil {
+		if err := schema.Validate(value); err != nil {
+			return nil, fmt.Errorf("output does not match the schema: %w", err)
+		}
+	}
+
+	// Decode again with encoding/json so callers get plain float64 numbers
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+		return nil, fmt.Errorf("output is not valid JSON: %w", err)
+	}
+	return parsed, nil
+}
+
+func extractJSON(output string) string {
+	text := strings.TrimSpace(output)
+
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(text, "```")
+		text = strings.TrimSpace(text)
+	}
+
+	if json.Valid([]byte(text)) {
+		return text
+	}
+
+	// Fall back to the outermost object or array in the text
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return text
+	}
+	closing := "}"
+	if text[start] == '[' {
+		closing = "]"
+	}
+	end := strings.LastIndex(text, closing)
+	if end <= start {
+		return text
+	}
+	return text[start : end+1]
+}
+
+func structuredPrompt(prompt string, format *models.ResponseFormat) string {
+	var sb strings.Builder
+	sb.WriteString(prompt)
+	sb.WriteString("\n\nRespond only with a valid JSON value and no other text.")
+	if len(format.Schema) > 0 {
+		sb.WriteString(" The JSON must match this JSON Schema:\n")
+		sb.Write(format.Schema)
+	}
+	return sb.String()
+}
+
+func repairPrompt(prompt string, format *models.ResponseFormat, output string, validationErr error) string {
+	var sb strings.Builder
+	sb.WriteString(structuredPrompt(prompt, format))
+	sb.WriteString("\n\nYour previous response was rejected:\n")
+	sb.WriteString(validationErr.Error())
+	sb.WriteString("\n\nPrevious response:\n")
+	sb.WriteString(output)
+	sb.WriteString("\n\nReturn the corrected JSON only.")
+	return sb.String()
+}
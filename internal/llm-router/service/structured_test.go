@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"workspace-engine/internal/llm-router/config"
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/service/llm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedProvider returns the scripted results in order and records the prompts it received
+type scriptedProvider struct {
+	mu      sync.Mutex
+	model   string
+	results []string
+	prompts []string
+	params  []map[string]interface{}
+}
+
+func (p *scriptedProvider) Generate(
+	_ context.Context, prompt string, params map[string]interface{},
+) (*models.RouteResponse, error) {
+	return &models.RouteResponse{
+		ID:     "test",
+		Result: p.next(prompt, params),
+		Model:  "scripted",
+		Usage:  models.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}, nil
+}
+
+// GenerateStream streams the next result as a single chunk
+func (p *scriptedProvider) GenerateStream(
+	_ context.Context, prompt string, params map[string]interface{},
+) (<-chan models.StreamResponse, error) {
+	stream := make(chan models.StreamResponse, 1)
+	stream <- models.StreamResponse{ID: "test", Content: p.next(prompt, params), Done: true}
+	close(stream)
+	return stream, nil
+}
+
+// next records the call and returns the next result, the last one is repeated
+func (p *scriptedProvider) next(prompt string, params map[string]interface{}) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.prompts = append(p.prompts, prompt)
+	p.params = append(p.params, params)
+
+	result := p.results[0]
+	if len(p.results) > 1 {
+		p.results = p.results[1:]
+	}
+	return result
+}
+
+func (p *scriptedProvider) GetModelInfo() models.ModelInfo {
+	if p.model != "" {
+		return models.ModelInfo{ID: p.model, Capabilities: []string{"chat"}, MaxTokens: 4096}
+	}
+	return models.ModelInfo{ID: "scripted"}
+}
+
+func (p *scriptedProvider) IsHealthy() bool {
+	return true
+}
+
+// newTestRouter returns a router service that routes everything to the given provider
+func newTestRouter(provider llm.Provider) *RouterService {
+	providers := map[string]llm.Provider{"scripted": provider}
+	catalog := NewCatalogService(providers, nil, &config.Config{})
+	return NewRouterService(providers, catalog, nil, nil)
+}
+
+func TestRouteStructured(t *testing.T) {
+	schema := json.RawMessage(`{
+		"type": "object",
+		"required": ["name", "age"],
+		"properties": {"name": {"type": "string"}, "age": {"type": "integer"}}
+	}`)
+
+	t.Run(
+		"RepairsInvalidOutput", func(t *testing.T) {
+			provider := &scriptedProvider{
+				results: []string{
+					`{"name": "Ann"}`,
+					"```json\n{\"name\": \"Ann\", \"age\": 31}\n```",
+				},
+			}
+			router := newTestRouter(provider)
+
+			resp, err := router.Route(
+				context.Background(), models.RouteRequest{
+					Prompt: "Who is Ann?",
+					ResponseFormat: &models.ResponseFormat{
+						Type:   models.ResponseFormatJSONSchema,
+						Schema: schema,
+					},
+				},
+			)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{"name": "Ann", "age": float64(31)}, resp.Parsed)
+			assert.Equal(t, 2, resp.Metadata["structured_attempts"])
+			assert.Equal(t, 30, resp.Usage.TotalTokens)
+
+			require.Len(t, provider.prompts, 2)
+			assert.Contains(t, provider.prompts[0], "JSON Schema")
+			assert.Contains(t, provider.prompts[1], "Previous response")
+			assert.NotContains(t, provider.params[0], "responseFormat", "provider has no native JSON mode")
+		},
+	)
+
+	t.Run(
+		"GivesUpAfterRetries", func(t *testing.T) {
+			provider := &scriptedProvider{results: []string{"not json"}}
+			router := newTestRouter(provider)
+
+			_, err := router.Route(
+				context.Background(), models.RouteRequest{
+					Prompt: "Who is Ann?",
+					ResponseFormat: &models.ResponseFormat{
+						Type:       models.ResponseFormatJSONObject,
+						MaxRetries: 1,
+					},
+				},
+			)
+			assert.Error(t, err)
+			assert.Len(t, provider.prompts, 2)
+		},
+	)
+}
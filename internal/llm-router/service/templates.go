@@ -0,0 +1,276 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"text/template"
+	"time"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/store"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("template not found")
+	errTemplateExists   = errors.New("template already exists")
+)
+
+type TemplateService struct {
+	db *gorm.DB
+}
+
+func NewTemplateService(db *gorm.DB) *TemplateService {
+	return &TemplateService{
+		db: db,
+	}
+}
+
+// Create stores the first version of a new template
+func (s *TemplateService) Create(req models.TemplateRequest) (*models.PromptTemplate, error) {
+	if req.Name == "" {
+		return nil, errors.New("template name is required")
+	}
+
+	return s.save(req, false)
+}
+
+// Update stores a new version of an existing template. Previous versions are kept so that
+// callers pinned to them keep working.
+func (s *TemplateService) Update(name string, req models.TemplateRequest) (*models.PromptTemplate, error) {
+	req.Name = name
+	return s.save(req, true)
+}
+
+// Get returns the requested version of a template or the latest one when version is 0
+func (s *TemplateService) Get(name string, version int) (*models.PromptTemplate, error) {
+	var entity store.PromptTemplate
+
+	query := s.db.Where("name = ?", name)
+	if version > 0 {
+		query = query.Where("version = ?", version)
+	}
+
+	err := query.Order("version desc").First(&entity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load template: %w", err)
+	}
+
+	return toTemplateModel(entity), nil
+}
+
+// List returns the latest version of every template
+func (s *TemplateService) List() ([]models.PromptTemplate, error) {
+	var entities []store.PromptTemplate
+	err := s.db.Where(
+		"version = (SELECT MAX(t.version) FROM prompt_templates t WHERE t.name = prompt_templates.name)",
+	).Order("name").Find(&entities).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+
+	templates := make([]models.PromptTemplate, 0, len(entities))
+	for _, entity := range entities {
+		templates = append(templates, *toTemplateModel(entity))
+	}
+	return templates, nil
+}
+
+// Versions returns all versions of a template, oldest first
+func (s *TemplateService) Versions(name string) ([]models.PromptTemplate, error) {
+	var entities []store.PromptTemplate
+	if err := s.db.Where("name = ?", name).Order("version").Find(&entities).Error; err != nil {
+		return nil, fmt.Errorf("failed to list template versions: %w", err)
+	}
+	if len(entities) == 0 {
+		return nil, ErrTemplateNotFound
+	}
+
+	templates := make([]models.PromptTemplate, 0, len(entities))
+	for _, entity := range entities {
+		templates = append(templates, *toTemplateModel(entity))
+	}
+	return templates, nil
+}
+
+// Delete removes a single version of a template, or all of its versions when version is 0
+func (s *TemplateService) Delete(name string, version int) error {
+	query := s.db.Where("name = ?", name)
+	if version > 0 {
+		query = query.Where("version = ?", version)
+	}
+
+	result := query.Delete(&store.PromptTemplate{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete template: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+// Apply renders the template referenced by the request and merges the template defaults into it.
+// The request prompt is available to the template as the "prompt" variable unless the caller
+// supplies a variable with the same name.
+func (s *TemplateService) Apply(req models.RouteRequest) (models.RouteRequest, *models.PromptTemplate, error) {
+	ref := req.Template
+	if ref == nil {
+		return req, nil, nil
+	}
+
+	tmpl, err := s.Get(ref.Name, ref.Version)
+	if err != nil {
+		return req, nil, err
+	}
+
+	variables := map[string]interface{}{
+		"prompt": req.Prompt,
+	}
+	for k, v := range ref.Variables {
+		variables[k] = v
+	}
+
+	prompt, err := renderTemplate(tmpl, variables)
+	if err != nil {
+		return req, nil, err
+	}
+	req.Prompt = prompt
+
+	if req.PreferredModel == "" {
+		req.PreferredModel = tmpl.Model
+	}
+
+	if len(tmpl.Parameters) > 0 {
+		params := make(map[string]interface{}, len(tmpl.Parameters)+len(req.Parameters))
+		for k, v := range tmpl.Parameters {
+			params[k] = v
+		}
+		for k, v := range req.Parameters {
+			params[k] = v
+		}
+		req.Parameters = params
+	}
+
+	return req, tmpl, nil
+}
+
+// save stores the request as the next version of its template. The version is issued and the
+// template stored in one transaction, so concurrent saves of the same name cannot both create the
+// first version or issue the same version twice.
+func (s *TemplateService) save(req models.TemplateRequest, update bool) (*models.PromptTemplate, error) {
+	if req.Template == "" {
+		return nil, errors.New("template body is required")
+	}
+
+	// Make sure the template can be parsed before storing it
+	if _, err := template.New(req.Name).Parse(req.Template); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	var params []byte
+	if len(req.Parameters) > 0 {
+		var err error
+		if params, err = json.Marshal(req.Parameters); err != nil {
+			return nil, fmt.Errorf("failed to marshal template parameters: %w", err)
+		}
+	}
+
+	entity := store.PromptTemplate{
+		Name:        req.Name,
+		Description: req.Description,
+		Template:    req.Template,
+		Model:       req.Model,
+		Parameters:  string(params),
+	}
+	err := s.db.Transaction(
+		func(tx *gorm.DB) error {
+			version, stored, err := nextTemplateVersion(tx, req.Name)
+			if err != nil {
+				return err
+			}
+			if update && stored == 0 {
+				return ErrTemplateNotFound
+			}
+			if !update && stored > 0 {
+				return errTemplateExists
+			}
+			entity.Version = version
+			return tx.Create(&entity).Error
+		},
+	)
+	if errors.Is(err, ErrTemplateNotFound) {
+		return nil, err
+	}
+	if errors.Is(err, errTemplateExists) {
+		return nil, fmt.Errorf("%w: %s", err, req.Name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save template: %w", err)
+	}
+
+	return toTemplateModel(entity), nil
+}
+
+// nextTemplateVersion issues the next version of a template and returns it together with the
+// highest version currently stored. Templates stored before the counter existed continue after
+// their highest stored version. The counter is written before anything is read, so the transaction
+// holds the write lock and the stored versions cannot change until it commits.
+func nextTemplateVersion(tx *gorm.DB, name string) (int, int, error) {
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&store.TemplateCounter{Name: name}).Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	storedVersion := tx.Model(&store.PromptTemplate{}).Where("name = ?", name).Select("COALESCE(MAX(version), 0)")
+	err = tx.Model(&store.TemplateCounter{}).Where("name = ?", name).
+		Update("last_version", gorm.Expr("MAX(last_version, (?)) + 1", storedVersion)).Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var counter store.TemplateCounter
+	if err := tx.Where("name = ?", name).First(&counter).Error; err != nil {
+		return 0, 0, err
+	}
+	var stored int
+	if err := storedVersion.Scan(&stored).Error; err != nil {
+		return 0, 0, err
+	}
+	return counter.LastVersion, stored, nil
+}
+
+func renderTemplate(tmpl *models.PromptTemplate, variables map[string]interface{}) (string, error) {
+	t, err := template.New(tmpl.Name).Option("missingkey=error").Parse(tmpl.Template)
+	if err != nil {
+		return "", fmt.Errorf("invalid template %s: %w", tmpl.Name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, variables); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", tmpl.Name, err)
+	}
+	return buf.String(), nil
+}
+
+func toTemplateModel(entity store.PromptTemplate) *models.PromptTemplate {
+	tmpl := &models.PromptTemplate{
+		Name:        entity.Name,
+		Version:     entity.Version,
+		Description: entity.Description,
+		Template:    entity.Template,
+		Model:       entity.Model,
+		CreatedAt:   entity.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if entity.Parameters != "" {
+		_ = json.Unmarshal([]byte(entity.Parameters), &tmpl.Parameters)
+	}
+	return tmpl
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateService(t *testing.T) {
+	db, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+
+	templates := NewTemplateService(db)
+
+	t.Run(
+		"Versioning", func(t *testing.T) {
+			created, err := templates.Create(
+				models.TemplateRequest{
+					Name:     "summary",
+					Template: "Summarize: {{.prompt}}",
+					Model:    "openai_gpt-4",
+				},
+			)
+			require.NoError(t, err)
+			assert.Equal(t, 1, created.Version)
+
+			_, err = templates.Create(models.TemplateRequest{Name: "summary", Template: "duplicate"})
+			assert.Error(t, err)
+
+			updated, err := templates.Update(
+				"summary", models.TemplateRequest{
+					Template:   "Summarize in {{.words}} words: {{.prompt}}",
+					Parameters: map[string]interface{}{"temperature": 0.2},
+				},
+			)
+			require.NoError(t, err)
+			assert.Equal(t, 2, updated.Version)
+
+			latest, err := templates.Get("summary", 0)
+			require.NoError(t, err)
+			assert.Equal(t, 2, latest.Version)
+
+			versions, err := templates.Versions("summary")
+			require.NoError(t, err)
+			assert.Len(t, versions, 2)
+
+			list, err := templates.List()
+			require.NoError(t, err)
+			require.Len(t, list, 1)
+			assert.Equal(t, 2, list[0].Version)
+		},
+	)
+
+	t.Run(
+		"Apply", func(t *testing.T) {
+			req, tmpl, err := templates.Apply(
+				models.RouteRequest{
+					Prompt:     "the text",
+					Parameters: map[string]interface{}{"maxTokens": 100},
+					Template: &models.TemplateRef{
+						Name:      "summary",
+						Variables: map[string]interface{}{"words": 10},
+					},
+				},
+			)
+			require.NoError(t, err)
+			assert.Equal(t, 2, tmpl.Version)
+			assert.Equal(t, "Summarize in 10 words: the text", req.Prompt)
+			assert.Equal(t, 0.2, req.Parameters["temperature"])
+			assert.Equal(t, 100, req.Parameters["maxTokens"])
+
+			req, tmpl, err = templates.Apply(
+				models.RouteRequest{
+					Prompt:   "the text",
+					Template: &models.TemplateRef{Name: "summary", Version: 1},
+				},
+			)
+			require.NoError(t, err)
+			assert.Equal(t, 1, tmpl.Version)
+			assert.Equal(t, "Summarize: the text", req.Prompt)
+			assert.Equal(t, "openai_gpt-4", req.PreferredModel)
+
+			_, _, err = templates.Apply(
+				models.RouteRequest{Template: &models.TemplateRef{Name: "summary"}},
+			)
+			assert.Error(t, err, "missing variables must fail rendering")
+		},
+	)
+
+	t.Run(
+		"Delete", func(t *testing.T) {
+			require.NoError(t, templates.Delete("summary", 1))
+			_, err := templates.Get("summary", 1)
+			assert.ErrorIs(t, err, ErrTemplateNotFound)
+
+			require.NoError(t, templates.Delete("summary", 0))
+			assert.ErrorIs(t, templates.Delete("summary", 0), ErrTemplateNotFound)
+		},
+	)
+
+	t.Run(
+		"VersionsAreNotReissued", func(t *testing.T) {
+			_, err := templates.Create(models.TemplateRequest{Name: "greeting", Template: "Hello {{.prompt}}"})
+			require.NoError(t, err)
+			_, err = templates.Update("greeting", models.TemplateRequest{Template: "Hi {{.prompt}}"})
+			require.NoError(t, err)
+
+			// Usage records of the deleted version keep referring to it alone
+			require.NoError(t, templates.Delete("greeting", 2))
+			updated, err := templates.Update("greeting", models.TemplateRequest{Template: "Hey {{.prompt}}"})
+			require.NoError(t, err)
+			assert.Equal(t, 3, updated.Version)
+
+			require.NoError(t, templates.Delete("greeting", 0))
+			created, err := templates.Create(models.TemplateRequest{Name: "greeting", Template: "Hello {{.prompt}}"})
+			require.NoError(t, err)
+			assert.Equal(t, 4, created.Version)
+		},
+	)
+
+	t.Run(
+		"ConcurrentSaves", func(t *testing.T) {
+			const saves = 8
+
+			var wg sync.WaitGroup
+			var created atomic.Int32
+			for i := 0; i < saves; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := templates.Create(models.TemplateRequest{Name: "race", Template: "Hello {{.prompt}}"})
+					if err == nil {
+						created.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+			assert.Equal(t, int32(1), created.Load(), "only one create of a name may succeed")
+
+			for i := 0; i < saves; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := templates.Update("race", models.TemplateRequest{Template: "Hi {{.prompt}}"})
+					assert.NoError(t, err)
+				}()
+			}
+			wg.Wait()
+
+			versions, err := templates.Versions("race")
+			require.NoError(t, err)
+			require.Len(t, versions, saves+1)
+			for i, version := range versions {
+				assert.Equal(t, i+1, version.Version)
+			}
+		},
+	)
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/store"
+
+	"gorm.io/gorm"
+)
+
+// usageHistorySize is the number of recent requests used for usage averages
+const usageHistorySize = 100
+
+type UsageService struct {
+	db *gorm.DB
+}
+
+func NewUsageService(db *gorm.DB) *UsageService {
+	return &UsageService{
+		db: db,
+	}
+}
+
+// Record stores the usage of a request routed for a caller together with the template it was
+// rendered from
+func (s *UsageService) Record(caller Caller, resp *models.RouteResponse, tmpl *models.PromptTemplate) error {
+	record := store.UsageRecord{
+		RequestID:        resp.ID,
+		Owner:            caller.owner(),
+		Model:            resp.Model,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		TotalTokens:      resp.Usage.TotalTokens,
+	}
+	if provider, ok := resp.Metadata["provider"].(string); ok {
+		record.Provider = provider
+	}
+	if tmpl != nil {
+		record.TemplateName = tmpl.Name
+		record.TemplateVersion = tmpl.Version
+	}
+
+	if err := s.db.Create(&record).Error; err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	return nil
+}
+
+// List returns the usage records of the requests of an API key matching the filter, newest first
+func (s *UsageService) List(apiKey string, filter models.UsageFilter) ([]models.UsageRecord, error) {
+	query := s.db.Model(&store.UsageRecord{}).Where("owner = ?", hashAPIKey(apiKey))
+	if filter.Model != "" {
+		query = query.Where("model = ?", filter.Model)
+	}
+	if filter.TemplateName != "" {
+		query = query.Where("template_name = ?", filter.TemplateName)
+	}
+	if filter.TemplateVersion > 0 {
+		query = query.Where("template_version = ?", filter.TemplateVersion)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var entities []store.UsageRecord
+	if err := query.Order("created_at desc").Find(&entities).Error; err != nil {
+		return nil, fmt.Errorf("failed to list usage: %w", err)
+	}
+
+	records := make([]models.UsageRecord, 0, len(entities))
+	for _, entity := range entities {
+		records = append(records, toUsageModel(entity))
+	}
+	return records, nil
+}
+
+// AverageCompletion returns the average completion tokens of the most recent requests for a model,
+// optionally restricted to a template, and the number of requests the average is based on
+func (s *UsageService) AverageCompletion(model, templateName string) (float64, int, error) {
+	recent := s.db.Model(&store.UsageRecord{}).Select("completion_tokens").Where("model = ?", model)
+	if templateName != "" {
+		recent = recent.Where("template_name = ?", templateName)
+	}
+	recent = recent.Order("created_at desc").Limit(usageHistorySize)
+
+	var result struct {
+		Average float64
+		Samples int
+	}
+	err := s.db.Table("(?) AS recent", recent).
+		Select("COALESCE(AVG(completion_tokens), 0) AS average, COUNT(*) AS samples").
+		Scan(&result).Error
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to load usage history: %w", err)
+	}
+	return result.Average, result.Samples, nil
+}
+
+func toUsageModel(entity store.UsageRecord) models.UsageRecord {
+	return models.UsageRecord{
+		RequestID:       entity.RequestID,
+		Model:           entity.Model,
+		Provider:        entity.Provider,
+		TemplateName:    entity.TemplateName,
+		TemplateVersion: entity.TemplateVersion,
+		Usage: models.Usage{
+			PromptTokens:     entity.PromptTokens,
+			CompletionTokens: entity.CompletionTokens,
+			TotalTokens:      entity.TotalTokens,
+		},
+		CreatedAt: entity.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
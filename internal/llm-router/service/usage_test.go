@@ -0,0 +1,36 @@
+package service
+
+import (
+	"testing"
+
+	"workspace-engine/internal/llm-router/models"
+	"workspace-engine/internal/llm-router/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageService(t *testing.T) {
+	db, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+
+	usage := NewUsageService(db)
+
+	t.Run(
+		"ScopedToTheAPIKey", func(t *testing.T) {
+			for _, apiKey := range []string{"key-a", "key-b"} {
+				resp := &models.RouteResponse{ID: apiKey, Model: "gpt-4"}
+				require.NoError(t, usage.Record(Caller{APIKey: apiKey}, resp, nil))
+			}
+
+			records, err := usage.List("key-a", models.UsageFilter{})
+			require.NoError(t, err)
+			require.Len(t, records, 1)
+			assert.Equal(t, "key-a", records[0].RequestID)
+
+			records, err = usage.List("key-c", models.UsageFilter{})
+			require.NoError(t, err)
+			assert.Empty(t, records)
+		},
+	)
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ErrInvalidCallback = errors.New("invalid callback URL")
+
+// callbackPolicy decides which URLs batch callbacks may be sent to. Callbacks are chosen by the
+// client, so they must not reach the network of the router.
+type callbackPolicy struct {
+	hosts []string
+}
+
+// check accepts https URLs of an allowed host. *.example.com allows the subdomains of example.com.
+func (p callbackPolicy) check(raw string) error {
+	callback, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCallback, err)
+	}
+	if callback.Scheme != "https" {
+		return fmt.Errorf("%w: the scheme must be https", ErrInvalidCallback)
+	}
+	if callback.User != nil {
+		return fmt.Errorf("%w: credentials are not allowed in the URL", ErrInvalidCallback)
+	}
+
+	host := strings.ToLower(callback.Hostname())
+	for _, allowed := range p.hosts {
+		allowed = strings.ToLower(allowed)
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return nil
+			}
+			continue
+		}
+		if host == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: host %s is not allowed", ErrInvalidCallback, host)
+}
+
+// newWebhookClient returns a client that connects to public addresses only. The address is
+// checked after the host name has been resolved, so a host resolving to an internal address is
+// refused as well. Redirects are not followed, they could lead to a host that is not allowed.
+func newWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: refuseInternalAddress}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// refuseInternalAddress is the control function of the webhook dialer, it runs for every
+// resolved address before connecting
+func refuseInternalAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("invalid address %s", address)
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: %s is not a public address", ErrInvalidCallback, ip)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const databaseFile = "llm-router.db"
+
+// Open opens (or creates) the router database in the given directory and makes sure
+// all relevant tables exist.
+func Open(path string) (*gorm.DB, error) {
+	if path == "" {
+		path = "."
+	}
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	// Background jobs write concurrently with request handlers, so wait for locks instead of failing
+	dsn := filepath.Join(path, databaseFile) + "?_busy_timeout=5000&_journal_mode=WAL"
+	db, err := gorm.Open(
+		sqlite.Open(dsn), &gorm.Config{
+			Logger: logger.Default.LogMode(logger.Silent),
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.AutoMigrate(
+		&PromptTemplate{}, &TemplateCounter{}, &UsageRecord{}, &BatchJob{}, &BatchItem{}, &AuditRecord{}, &ShadowRecord{},
+		&Session{}, &SessionMessage{}, &CredentialPolicy{}, &APIKey{},
+	); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return db, nil
+}
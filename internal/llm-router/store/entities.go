@@ -0,0 +1,151 @@
+package store
+
+import (
+	"time"
+)
+
+// PromptTemplate is a single immutable version of a named prompt template.
+type PromptTemplate struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"uniqueIndex:idx_template_name_version;not null"`
+	Version     int    `gorm:"uniqueIndex:idx_template_name_version;not null"`
+	Description string
+	Template    string `gorm:"not null"`
+	Model       string
+	// Parameters holds the JSON encoded default parameters of the template.
+	Parameters string
+	CreatedAt  time.Time
+}
+
+// TemplateCounter holds the last version issued for a template name. Versions are never reissued,
+// not even after the versions or the whole template were deleted.
+type TemplateCounter struct {
+	Name        string `gorm:"primaryKey"`
+	LastVersion int
+}
+
+// UsageRecord captures token usage of a single routed request.
+type UsageRecord struct {
+	ID        uint   `gorm:"primaryKey"`
+	RequestID string `gorm:"index"`
+	// Owner is the hash of the API key the request was sent with
+	Owner            string `gorm:"index"`
+	Model            string `gorm:"index"`
+	Provider         string
+	TemplateName     string `gorm:"index"`
+	TemplateVersion  int
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	CreatedAt        time.Time `gorm:"index"`
+}
+
+// BatchJob is an asynchronous batch of route requests
+type BatchJob struct {
+	ID string `gorm:"primaryKey"`
+	// Owner is the hash of the API key that created the job
+	Owner string `gorm:"index"`
+	// Tenant is the tenant the job was submitted on behalf of, its lines are routed for it
+	Tenant      string
+	Status      string `gorm:"index"`
+	CallbackURL string
+	Total       int
+	Completed   int
+	Failed      int
+	Error       string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	FinishedAt  *time.Time
+}
+
+// BatchItem is a single line of a batch job. Its result is stored as soon as it is processed so
+// that an interrupted job resumes from the first unprocessed line.
+type BatchItem struct {
+	ID       uint   `gorm:"primaryKey"`
+	JobID    string `gorm:"index;not null"`
+	Line     int
+	CustomID string
+	Request  string
+	Status   string `gorm:"index"`
+	Response string
+	Error    string
+}
+
+// AuditRecord is a change made through the admin API.
+type AuditRecord struct {
+	ID     uint   `gorm:"primaryKey"`
+	Actor  string `gorm:"index"`
+	Action string
+	Target string `gorm:"index"`
+	// Details holds the JSON encoded request of the change.
+	Details   string
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// ShadowRecord is a request mirrored to a shadow model, stored with the response the caller got.
+// RequestID is the id of the primary response, which is also the request id of its usage record.
+type ShadowRecord struct {
+	ID               uint   `gorm:"primaryKey"`
+	RequestID        string `gorm:"index"`
+	Rule             string `gorm:"index"`
+	Prompt           string
+	PrimaryModel     string
+	PrimaryResult    string
+	PrimaryLatencyMs int64
+	PrimaryTokens    int
+	ShadowModel      string `gorm:"index"`
+	ShadowResult     string
+	ShadowError      string
+	ShadowLatencyMs  int64
+	ShadowTokens     int
+	CreatedAt        time.Time `gorm:"index"`
+}
+
+// Session is a conversation kept by the router. Owner is the SHA-256 hash of the API key that
+// created it, sessions are only visible to that key.
+type Session struct {
+	ID     string `gorm:"primaryKey"`
+	Owner  string `gorm:"index;not null"`
+	Title  string
+	Model  string
+	System string
+	// Parameters holds the JSON encoded parameters used for every turn.
+	Parameters string
+	// Turns counts the stored turns. A turn is only stored when no other turn was stored since it
+	// read the transcript.
+	Turns     int `gorm:"not null;default:0"`
+	CreatedAt time.Time
+	UpdatedAt time.Time `gorm:"index"`
+}
+
+// SessionMessage is a message of the transcript of a session.
+type SessionMessage struct {
+	ID        uint   `gorm:"primaryKey"`
+	SessionID string `gorm:"index;not null"`
+	Role      string `gorm:"not null"`
+	Content   string
+	// Model is the model that wrote an assistant message.
+	Model     string
+	CreatedAt time.Time
+}
+
+// APIKey is an API key issued by the router. KeyHash is the SHA-256 hash of the key, which itself
+// is not stored.
+type APIKey struct {
+	ID      string `gorm:"primaryKey"`
+	Name    string
+	KeyHash string `gorm:"uniqueIndex"`
+	// Prefix is the start of the key, shown to tell keys apart
+	Prefix    string
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+// CredentialPolicy decides whether requests made with an API key may carry their own provider API
+// keys. KeyHash is the SHA-256 hash of the API key.
+type CredentialPolicy struct {
+	KeyHash   string `gorm:"primaryKey"`
+	Allowed   bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
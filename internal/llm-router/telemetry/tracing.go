@@ -0,0 +1,116 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"workspace-engine/internal/llm-router/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const defaultServiceName = "llm-router"
+
+// Span attribute keys shared by the router and the providers
+const (
+	AttrProvider         = attribute.Key("gen_ai.system")
+	AttrRequestModel     = attribute.Key("gen_ai.request.model")
+	AttrResponseModel    = attribute.Key("gen_ai.response.model")
+	AttrInputTokens      = attribute.Key("gen_ai.usage.input_tokens")
+	AttrOutputTokens     = attribute.Key("gen_ai.usage.output_tokens")
+	AttrAttempt          = attribute.Key("llm_router.attempt")
+	AttrAPIKeyName       = attribute.Key("llm_router.api_key")
+	AttrProviderKey      = attribute.Key("llm_router.provider_key")
+	AttrPreferredModel   = attribute.Key("llm_router.preferred_model")
+	AttrRoutingRule      = attribute.Key("llm_router.routing_rule")
+	AttrRouteStrategy    = attribute.Key("llm_router.route_strategy")
+	AttrStreamChunkCount = attribute.Key("llm_router.stream.chunks")
+)
+
+// InitTracing installs the global tracer provider and W3C trace context propagation. The returned
+// function flushes and stops the exporter.
+func InitTracing(cfg config.TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(
+		propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	)
+
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, closer, err := newExporter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if cfg.SampleRatio > 0 && cfg.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(
+			resource.NewSchemaless(attribute.String("service.name", serviceName)),
+		),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			if cerr := closer.Close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}, nil
+}
+
+func newExporter(cfg config.TracingConfig) (sdktrace.SpanExporter, io.Closer, error) {
+	switch cfg.Exporter {
+	case "", "stdout":
+		exporter, err := stdouttrace.New()
+		return exporter, nil, err
+	case "file":
+		if cfg.FilePath == "" {
+			return nil, nil, fmt.Errorf("tracing file_path is required for the file exporter")
+		}
+		file, err := os.OpenFile(cfg.FilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return exporter, file, nil
+	case "otlp":
+		opts := []otlptracehttp.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(context.Background(), opts...)
+		return exporter, nil, err
+	default:
+		return nil, nil, fmt.Errorf("unknown tracing exporter: %s", cfg.Exporter)
+	}
+}
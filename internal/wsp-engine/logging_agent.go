@@ -1,10 +1,7 @@
 package workspaceEngine
 
 import (
-	"io"
-	"log"
-	"os"
-	"gopkg.in/natefinch/lumberjack.v2"
+	"workspace-engine/pkg/logger"
 )
 
 // Interface
@@ -25,27 +22,17 @@ func InitBaseLoggingAgent() *error {
 }
 
 func (la *BaseLoggingAgent) Init() (*error) {
-	// 1. Set log output to a file in current directory
-    // logFile, err := os.OpenFile("enginge.log", os.O_APPEND|os.O_RDWR|os.O_CREATE, 0644)
-    // if err != nil {
-    //     return &err
-    // }
-	// Set log rotation
-	logFile := lumberjack.Logger{
-		Filename:   "enginge.log", 
-		MaxSize:    50, // MB
-		MaxBackups: 3,   
-		MaxAge:     28,   //days
+	// 1. Log to the console and to a rotated file in current directory
+	err := logger.NewLogger(logger.Config{
+		Level:  "info",
+		Format: "console",
+		Outputs: []logger.OutputConfig{
+			{Type: "stdout"},
+			{Type: "file", Path: "enginge.log", MaxSize: 50, MaxBackups: 3, MaxAge: 28},
+		},
+	})
+	if err != nil {
+		return &err
 	}
-    //defer logFile.Close()
-
-    // Set log output to the file and console
-	mw := io.MultiWriter(os.Stdout, &logFile)
-    log.SetOutput(mw)
-
-    // log date-time, filename, and line number
-    log.SetFlags(log.Lshortfile | log.LstdFlags)
-
-    log.Println("Logging has been initialized...")
 	return nil
-}
\ No newline at end of file
+}
@@ -1,31 +1,187 @@
 package logger
 
 import (
+	"context"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
+	"strings"
 
+	"go.opentelemetry.io/otel/trace"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-func NewLogger() {
-	logFile := lumberjack.Logger{
-		Filename:   "router.log",
-		MaxSize:    50, // MB
-		MaxBackups: 3,
-		MaxAge:     28, //days
+// Config describes the log level, encoding and sinks of a logger
+type Config struct {
+	// Level is one of debug, info, warn or error
+	Level string `mapstructure:"level"`
+	// Format is either json or console
+	Format  string         `mapstructure:"format"`
+	Outputs []OutputConfig `mapstructure:"outputs"`
+}
+
+// OutputConfig describes a single log sink
+type OutputConfig struct {
+	// Type is one of stdout, stderr or file
+	Type       string `mapstructure:"type"`
+	Path       string `mapstructure:"path"`
+	MaxSize    int    `mapstructure:"max_size"` // MB
+	MaxBackups int    `mapstructure:"max_backups"`
+	MaxAge     int    `mapstructure:"max_age"` // days
+}
+
+type contextKey struct{}
+
+var requestIDKey = contextKey{}
+
+var current = slog.Default()
+
+// NewLogger initializes the default logger from the given configuration. Output of the standard
+// library log package is routed through it at info level.
+func NewLogger(cfg Config) error {
+	level, err := parseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+
+	outputs := cfg.Outputs
+	if len(outputs) == 0 {
+		outputs = []OutputConfig{{Type: "stdout"}}
+	}
+
+	writers := make([]io.Writer, 0, len(outputs))
+	for _, output := range outputs {
+		w, err := newWriter(output)
+		if err != nil {
+			return err
+		}
+		writers = append(writers, w)
+	}
+
+	opts := &slog.HandlerOptions{Level: level, AddSource: level == slog.LevelDebug}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "json":
+		handler = slog.NewJSONHandler(io.MultiWriter(writers...), opts)
+	case "", "console", "text":
+		handler = slog.NewTextHandler(io.MultiWriter(writers...), opts)
+	default:
+		return fmt.Errorf("unknown log format: %s", cfg.Format)
+	}
+
+	current = slog.New(&contextHandler{Handler: handler})
+	slog.SetDefault(current)
+
+	current.Info("Logging has been initialized", "level", level.String(), "format", cfg.Format)
+	return nil
+}
+
+// WithRequestID returns a context whose log records carry the given request id
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID returns the request id stored in the context, if any
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+func Debug(msg string, args ...any) {
+	current.Debug(msg, args...)
+}
+
+func Info(msg string, args ...any) {
+	current.Info(msg, args...)
+}
+
+func Warn(msg string, args ...any) {
+	current.Warn(msg, args...)
+}
+
+func Error(msg string, args ...any) {
+	current.Error(msg, args...)
+}
+
+func DebugContext(ctx context.Context, msg string, args ...any) {
+	current.DebugContext(ctx, msg, args...)
+}
+
+func InfoContext(ctx context.Context, msg string, args ...any) {
+	current.InfoContext(ctx, msg, args...)
+}
+
+func WarnContext(ctx context.Context, msg string, args ...any) {
+	current.WarnContext(ctx, msg, args...)
+}
+
+func ErrorContext(ctx context.Context, msg string, args ...any) {
+	current.ErrorContext(ctx, msg, args...)
+}
+
+// contextHandler adds the request id and trace id found in the record context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
 	}
+	return h.Handler.Handle(ctx, record)
+}
 
-	// Set log output to the file and console
-	mw := io.MultiWriter(os.Stdout, &logFile)
-	log.SetOutput(mw)
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
 
-	// log date-time, filename, and line number
-	log.SetFlags(log.Lshortfile | log.LstdFlags)
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
 
-	log.Println("Logging has been initialized...")
+func parseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("unknown log level: %s", level)
+	}
 }
 
-func Info(args ...any) {
-	log.Println(args...)
+func newWriter(output OutputConfig) (io.Writer, error) {
+	switch strings.ToLower(output.Type) {
+	case "", "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	case "file":
+		if output.Path == "" {
+			return nil, fmt.Errorf("log file path is required")
+		}
+		maxSize := output.MaxSize
+		if maxSize == 0 {
+			maxSize = 50
+		}
+		return &lumberjack.Logger{
+			Filename:   output.Path,
+			MaxSize:    maxSize,
+			MaxBackups: output.MaxBackups,
+			MaxAge:     output.MaxAge,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown log output: %s", output.Type)
+	}
 }
@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	err := NewLogger(
+		Config{
+			Level:   "warn",
+			Format:  "json",
+			Outputs: []OutputConfig{{Type: "file", Path: path}},
+		},
+	)
+	require.NoError(t, err)
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	Info("filtered out")
+	WarnContext(ctx, "Slow request", "duration_ms", 1200)
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+
+	require.Len(t, records, 1)
+	assert.Equal(t, "WARN", records[0]["level"])
+	assert.Equal(t, "Slow request", records[0]["msg"])
+	assert.Equal(t, "req-1", records[0]["request_id"])
+	assert.Equal(t, float64(1200), records[0]["duration_ms"])
+}
+
+func TestNewLoggerInvalidConfig(t *testing.T) {
+	assert.Error(t, NewLogger(Config{Level: "verbose"}))
+	assert.Error(t, NewLogger(Config{Format: "xml"}))
+	assert.Error(t, NewLogger(Config{Outputs: []OutputConfig{{Type: "file"}}}))
+}